@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+	scopeAdmin = "admin"
+)
+
+var allScopes = map[string]bool{scopeRead: true, scopeWrite: true, scopeAdmin: true}
+
+type adminToken struct {
+	Label  string // 空ならラベルなし（ADMIN_TOKEN 単体）
+	Token  string
+	Scopes map[string]bool
+}
+
+func (t adminToken) has(scope string) bool {
+	return t.Scopes[scope]
+}
+
+// ADMIN_TOKEN / ADMIN_TOKEN_FILE。mainで読み込む
+var adminTokenSecret *secretValue
+
+// ADMIN_TOKENS=alice:tok1:read|write,bob:tok2 でラベル付きトークンを複数設定できる。
+// スコープ省略時は全スコープ。ADMIN_TOKEN（ラベルなし・全スコープ）も併用可。
+// どちらも未設定なら dev-admin-token（全スコープ）。
+func loadAdminTokens() []adminToken {
+	var tokens []adminToken
+	for _, entry := range strings.Split(os.Getenv("ADMIN_TOKENS"), ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+			continue
+		}
+		t := adminToken{Label: fields[0], Token: fields[1], Scopes: allScopes}
+		if len(fields) == 3 {
+			t.Scopes = map[string]bool{}
+			for _, sc := range strings.Split(fields[2], "|") {
+				if allScopes[sc] {
+					t.Scopes[sc] = true
+				}
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	if v := adminTokenSecret.Get(); v != "" {
+		tokens = append(tokens, adminToken{Token: v, Scopes: allScopes})
+	}
+	if len(tokens) == 0 {
+		tokens = append(tokens, adminToken{Token: "dev-admin-token", Scopes: allScopes})
+	}
+	return tokens
+}
+
+func adminFromRequest(r *http.Request) (adminToken, bool) {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		return adminToken{}, false
+	}
+	for _, t := range loadAdminTokens() {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(t.Token)) == 1 {
+			return t, true
+		}
+	}
+	return adminToken{}, false
+}
+
+// Authorizationヘッダが付いているのに "Bearer <token68>" の形をしていなければ false。
+// 未指定は401の扱いなのでここでは true
+func wellFormedAuthorization(r *http.Request) bool {
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(h, "Bearer ")
+	if !ok || got == "" || len(got) > 512 {
+		return false
+	}
+	got = strings.TrimRight(got, "=")
+	for _, c := range got {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-._~+/", c):
+		default:
+			return false
+		}
+	}
+	return got != ""
+}
+
+// ヘッダ形式不正は400、トークン不正は401、トークンは正しいがスコープ不足なら403を書いてfalseを返す
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) (adminToken, bool) {
+	if !wellFormedAuthorization(r) {
+		logRejection(r, rejectAuthMalformed)
+		httpError(w, r, "malformed authorization header", http.StatusBadRequest)
+		return adminToken{}, false
+	}
+	t, ok := adminFromRequest(r)
+	if !ok {
+		logRejection(r, rejectAdminUnauthorized)
+		httpError(w, r, "unauthorized", http.StatusUnauthorized)
+		return adminToken{}, false
+	}
+	if !t.has(scope) {
+		logRejection(r, rejectAdminForbidden)
+		httpError(w, r, "forbidden: "+scope+" scope required", http.StatusForbidden)
+		return adminToken{}, false
+	}
+	return t, true
+}
+
+// /admin/requests/{id}/...
+func (s *server) handleAdminRequest(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/requests/"), "/")
+	parts := strings.Split(rest, "/")
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "replay" && r.Method == http.MethodPost {
+		s.handleReplay(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "timeline" && r.Method == http.MethodGet {
+		s.handleTimeline(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "raw" && r.Method == http.MethodGet {
+		s.handleRawItem(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "rebuild-history" && r.Method == http.MethodPost {
+		s.handleRebuildHistory(w, r, parts[0])
+		return
+	}
+	if len(parts) != 2 || parts[0] == "" {
+		notFound(w, r)
+		return
+	}
+	subrouteFallback(w, r, adminSubrouteMethods, parts[1])
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       string
+		scope      string
+		wantStatus int // 0 なら通る
+	}{
+		{name: "valid token", auth: "Bearer reader-tok", scope: scopeRead},
+		{name: "missing header", scope: scopeRead, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer other-tok", scope: scopeRead, wantStatus: http.StatusUnauthorized},
+		{name: "missing scope", auth: "Bearer reader-tok", scope: scopeWrite, wantStatus: http.StatusForbidden},
+		{name: "not bearer", auth: "Basic dXNlcjpwYXNz", scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "empty bearer", auth: "Bearer ", scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "only padding", auth: "Bearer ==", scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "space inside token", auth: "Bearer reader tok", scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "non-token68 character", auth: "Bearer reader-tok;", scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "too long", auth: "Bearer " + strings.Repeat("a", 513), scope: scopeRead, wantStatus: http.StatusBadRequest},
+		{name: "token68 characters and padding", auth: "Bearer aZ09-._~+/==", scope: scopeRead, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKENS", "reader:reader-tok:read")
+			t.Setenv("REJECTION_LOG", "false")
+			r := httptest.NewRequest(http.MethodGet, "/admin/requests", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			_, ok := requireScope(rec, r, tt.scope)
+			if ok != (tt.wantStatus == 0) {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantStatus == 0)
+			}
+			if tt.wantStatus != 0 && rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+type AdminConfigOutput struct {
+	RequestsTable string         `json:"requestsTable"`
+	QueueURL      string         `json:"queueUrl"`
+	Config        map[string]any `json:"config"`
+}
+
+// Config を 環境変数名 → 解決済みの値 にする。secret:"true" のフィールドは設定されていれば伏せる
+func configValues(c Config) map[string]any {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	out := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Tag.Get("secret") == "true" && !fv.IsZero() {
+			out[name] = redactedValue
+			continue
+		}
+		out[name] = configValue(fv)
+	}
+	return out
+}
+
+// time.Duration は "5s" の形で出す（そのままだとナノ秒の整数になる）。nilのスライス・マップは空で出す
+func configValue(v reflect.Value) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = configValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = configValue(iter.Value())
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// GET /admin/config (admin scope)
+// 起動時に解決した設定（テーブル名・キューURL・Config）を返す。秘密情報は伏せる
+func (s *server) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeAdmin); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, AdminConfigOutput{
+		RequestsTable: requestsTable,
+		QueueURL:      s.queueURL,
+		Config:        configValues(s.config),
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// リフレクションで出すので、フィールドには必ず env タグが要る（付け忘れると /admin/config に出ない）
+func TestConfigFieldsHaveEnvTags(t *testing.T) {
+	seen := map[string]string{}
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := f.Tag.Get("env")
+		if name == "" {
+			t.Errorf("Config.%s has no env tag", f.Name)
+			continue
+		}
+		if prev, ok := seen[name]; ok {
+			t.Errorf("Config.%s and Config.%s both use %s", prev, f.Name, name)
+		}
+		seen[name] = f.Name
+	}
+}
+
+func TestAdminConfigShowsResolvedValues(t *testing.T) {
+	for k, v := range map[string]string{
+		"ADMIN_TOKENS":      "",
+		"AWS_SESSION_TOKEN": "",
+		"CURSOR_SECRET":     "c0ffee",
+		"BULK_MAX_ITEMS":    "lots", // 不正値 → 既定
+		"HANDLER_TIMEOUT":   "45s",
+		"HANDLER_TIMEOUTS":  "/requests/batch-get=5s",
+		"DISABLED_ROUTES":   " replay , export",
+		"GET_RATE_LIMIT":    "60",
+		"GET_RATE_BURST":    "",
+		"TIME_FORMAT":       "EPOCH",
+		"HISTORY_STORAGE":   "",
+		"DEFAULT_STATUS":    " in_progress ",
+		"GZIP_ENABLED":      "maybe", // 不正値 → 既定 true
+	} {
+		t.Setenv(k, v)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &server{queueURL: "http://fake/queue", config: config}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	r.Header.Set("Authorization", "Bearer dev-admin-token")
+	rec := httptest.NewRecorder()
+	srv.handleAdminConfig(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		QueueURL string         `json:"queueUrl"`
+		Config   map[string]any `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(out.Config), reflect.TypeOf(Config{}).NumField(); got != want {
+		t.Errorf("config has %d entries, want one per Config field (%d)", got, want)
+	}
+	want := map[string]any{
+		"CURSOR_SECRET":            redactedValue,
+		"ADMIN_TOKENS":             "",
+		"AWS_SESSION_TOKEN":        "",
+		"BULK_MAX_ITEMS":           float64(defaultBulkMaxItems),
+		"HANDLER_TIMEOUT":          "45s",
+		"HANDLER_TIMEOUTS":         map[string]any{"/requests/batch-get": "5s"},
+		"DISABLED_ROUTES":          []any{"export", "replay"},
+		"GET_RATE_BURST":           float64(60),
+		"TIME_FORMAT":              "epoch",
+		"HISTORY_STORAGE":          "inline",
+		"DEFAULT_STATUS":           "IN_PROGRESS",
+		"GZIP_ENABLED":             true,
+		"QUEUE_STATS_CACHE_TTL":    "5s",
+		"REASON_REQUIRED_STATUSES": []any{"REJECTED"},
+		"TITLE_DENYLIST":           "",
+	}
+	for k, w := range want {
+		if got := out.Config[k]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %#v, want %#v", k, got, w)
+		}
+	}
+	if out.QueueURL != "http://fake/queue" {
+		t.Errorf("queueUrl = %q", out.QueueURL)
+	}
+}
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	for _, kv := range [][2]string{
+		{"DEFAULT_STATUS", "DONE"},
+		{"DISABLED_ROUTES", "nope"},
+		{"HANDLER_TIMEOUT", "soon"},
+		{"LISTEN_ADDR", "8080"},
+	} {
+		t.Run(kv[0], func(t *testing.T) {
+			t.Setenv(kv[0], kv[1])
+			if _, err := loadConfig(); err == nil {
+				t.Errorf("%s=%q accepted", kv[0], kv[1])
+			}
+		})
+	}
+}
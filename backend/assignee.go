@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	assigneeIndex     = "assignee-index"
+	maxAssigneeLength = 100
+)
+
+type PatchAssigneeInput struct {
+	Assignee string `json:"assignee"`
+}
+
+type AdminRequestSummary struct {
+	RequestID string   `json:"requestId"`
+	DisplayID string   `json:"displayId,omitempty"`
+	Title     string   `json:"title"`
+	Status    string   `json:"status"`
+	Assignee  string   `json:"assignee,omitempty"`
+	Priority  *int     `json:"priority,omitempty"`
+	DueAt     string   `json:"dueAt,omitempty"`
+	Overdue   bool     `json:"overdue,omitempty"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+func summaryFromItem(item map[string]types.AttributeValue) AdminRequestSummary {
+	return summaryFromItemAt(item, time.Now().UTC(), overdueGrace())
+}
+
+func summaryFromItemAt(item map[string]types.AttributeValue, now time.Time, grace time.Duration) AdminRequestSummary {
+	it := decodeRequestItem(item)
+	return AdminRequestSummary{
+		RequestID: it.requestID(),
+		DisplayID: it.DisplayID,
+		Title:     it.Title,
+		Status:    it.Status,
+		Assignee:  it.Assignee,
+		Priority:  it.Priority,
+		DueAt:     it.DueAt,
+		Overdue:   isOverdue(it.DueAt, it.Status, now, grace),
+		Tags:      it.sortedTags(),
+		CreatedAt: it.CreatedAt,
+	}
+}
+
+// PATCH /requests/{id}/assignee (admin only)。空文字で担当解除
+func (s *server) handlePatchAssignee(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := requireScope(w, r, scopeWrite); !ok {
+		return
+	}
+	var in PatchAssigneeInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(in.Assignee) > maxAssigneeLength {
+		httpError(w, r, "assignee too long", http.StatusBadRequest)
+		return
+	}
+
+	upd := &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + id},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	}
+	if in.Assignee == "" {
+		// GSIのキー属性は空文字にできないので属性ごと消す
+		upd.UpdateExpression = aws.String("REMOVE assignee ADD version :one")
+	} else {
+		upd.UpdateExpression = aws.String("SET assignee = :a ADD version :one")
+		upd.ExpressionAttributeValues[":a"] = &types.AttributeValueMemberS{Value: in.Assignee}
+	}
+	_, err := s.ddb.UpdateItem(r.Context(), upd)
+	requestCache.invalidate(id)
+	if err != nil {
+		writeStoreError(w, r, translateDynamoErr(err), "failed to update")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, map[string]string{
+		"requestId": id,
+		"assignee":  in.Assignee,
+	})
+}
+
+// GET /admin/requests/mine
+// トークンのラベルを担当者として扱う。ラベルなしトークンの場合は ?assignee= が必須。
+func (s *server) handleMyRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	admin, ok := requireScope(w, r, scopeRead)
+	if !ok {
+		return
+	}
+	assignee := admin.Label
+	if assignee == "" {
+		assignee = r.URL.Query().Get("assignee")
+	}
+	if assignee == "" {
+		httpError(w, r, "assignee required (token has no label)", http.StatusBadRequest)
+		return
+	}
+
+	p := dynamodb.NewQueryPaginator(s.ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(requestsTable),
+		IndexName:              aws.String(assigneeIndex),
+		KeyConditionExpression: aws.String("assignee = :a"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":a": &types.AttributeValueMemberS{Value: assignee},
+		},
+	})
+	out := []AdminRequestSummary{}
+	for p.HasMorePages() {
+		page, err := p.NextPage(r.Context())
+		if err != nil {
+			httpError(w, r, "failed to query", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range page.Items {
+			out = append(out, summaryFromItem(item))
+		}
+	}
+	sortByPriorityThenDue(out)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, out)
+}
+
+// priority昇順 → dueAt昇順 → createdAt昇順。未設定のものは後ろ
+func sortByPriorityThenDue(list []AdminRequestSummary) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if (a.Priority == nil) != (b.Priority == nil) {
+			return a.Priority != nil
+		}
+		if a.Priority != nil && *a.Priority != *b.Priority {
+			return *a.Priority < *b.Priority
+		}
+		if (a.DueAt == "") != (b.DueAt == "") {
+			return a.DueAt != ""
+		}
+		if a.DueAt != b.DueAt {
+			return a.DueAt < b.DueAt
+		}
+		return a.CreatedAt < b.CreatedAt
+	})
+}
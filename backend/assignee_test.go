@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortByPriorityThenDue(t *testing.T) {
+	p := func(n int) *int { return &n }
+	list := []AdminRequestSummary{
+		{RequestID: "no-priority", CreatedAt: "2024-01-01T00:00:00Z"},
+		{RequestID: "p2", Priority: p(2), CreatedAt: "2024-01-01T00:00:00Z"},
+		{RequestID: "p1-no-due", Priority: p(1), CreatedAt: "2024-01-01T00:00:00Z"},
+		{RequestID: "p1-due-late", Priority: p(1), DueAt: "2024-03-01T00:00:00Z", CreatedAt: "2024-01-01T00:00:00Z"},
+		{RequestID: "p1-due-early", Priority: p(1), DueAt: "2024-02-01T00:00:00Z", CreatedAt: "2024-01-02T00:00:00Z"},
+		{RequestID: "p1-due-early-older", Priority: p(1), DueAt: "2024-02-01T00:00:00Z", CreatedAt: "2024-01-01T00:00:00Z"},
+		{RequestID: "no-priority-due", DueAt: "2024-01-15T00:00:00Z", CreatedAt: "2024-01-05T00:00:00Z"},
+	}
+	sortByPriorityThenDue(list)
+
+	var got []string
+	for _, s := range list {
+		got = append(got, s.RequestID)
+	}
+	want := []string{"p1-due-early-older", "p1-due-early", "p1-due-late", "p1-no-due", "p2", "no-priority-due", "no-priority"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestParseDueAt(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "2024-05-01T09:00:00Z", want: "2024-05-01T09:00:00Z"},
+		{in: "2024-05-01T18:00:00+09:00", want: "2024-05-01T09:00:00Z"},
+		{in: " 2024-05-01T09:00:00Z ", want: "2024-05-01T09:00:00Z"},
+		{in: "2024-05-01", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDueAt(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDueAt(%q) = %q, %v; want %q, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
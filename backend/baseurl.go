@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultPublicBaseURL = "http://localhost:8080"
+
+// trackingUrlのベースURL。
+// TRUST_PROXY_HEADERS=true のときだけ X-Forwarded-Proto/Host を使い、
+// 次に APP_PUBLIC_BASE_URL、最後に localhost にフォールバックする。
+func publicBaseURL(r *http.Request) string {
+	if envBool("TRUST_PROXY_HEADERS") {
+		if base, ok := forwardedBaseURL(r); ok {
+			return base
+		}
+	}
+	if base := os.Getenv("APP_PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return defaultPublicBaseURL
+}
+
+func forwardedBaseURL(r *http.Request) (string, bool) {
+	proto := strings.ToLower(firstForwarded(r.Header.Get("X-Forwarded-Proto")))
+	host := firstForwarded(r.Header.Get("X-Forwarded-Host"))
+	if host == "" {
+		return "", false
+	}
+	if proto == "" {
+		proto = "http"
+	}
+	if proto != "http" && proto != "https" {
+		return "", false
+	}
+
+	// ヘッダ経由の値でリンクが壊れないよう、host部分だけで構成されたURLかを検証する
+	u, err := url.Parse(proto + "://" + host)
+	if err != nil || u.Host != host || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+	if strings.ContainsAny(host, " \t\r\n\"'<>\\") {
+		return "", false
+	}
+	return u.Scheme + "://" + u.Host, true
+}
+
+// プロキシが多段の場合はカンマ区切りになるので先頭（クライアントに最も近い値）を使う
+func firstForwarded(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
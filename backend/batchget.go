@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+const defaultBatchGetMaxItems = 50
+
+type BatchGetInput struct {
+	Items []BatchGetItem `json:"items"`
+}
+
+type BatchGetItem struct {
+	ID    string `json:"id"`
+	Token string `json:"t"`
+}
+
+type BatchGetResult struct {
+	ID      string            `json:"id"`
+	Result  string            `json:"result"` // found / forbidden / not_found / invalid_token
+	Request *GetRequestOutput `json:"request,omitempty"`
+}
+
+func getRequestOutputFromItem(id string, item map[string]types.AttributeValue) GetRequestOutput {
+	it := decodeRequestItem(item)
+	return GetRequestOutput{
+		RequestID:    id,
+		DisplayID:    it.DisplayID,
+		Title:        it.Title,
+		Status:       it.Status,
+		Tags:         it.sortedTags(),
+		CreatedAt:    it.CreatedAt,
+		StatusReason: it.StatusReason,
+	}
+}
+
+// POST /requests/batch-get
+// 追跡リンクを複数持つクライアント向け。トークンが一致したものだけ中身を返す（不一致はforbiddenとだけ返す）
+func (s *server) handleBatchGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	var in BatchGetInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(in.Items) == 0 {
+		httpError(w, r, "items required", http.StatusBadRequest)
+		return
+	}
+	maxItems := envInt("BATCH_GET_MAX_ITEMS", defaultBatchGetMaxItems)
+	if len(in.Items) > maxItems {
+		httpError(w, r, "too many items (max "+strconv.Itoa(maxItems)+")", http.StatusBadRequest)
+		return
+	}
+
+	// 形式不正なトークンはDBを引かない（requireRequesterToken と同じ扱い）
+	results := make([]BatchGetResult, len(in.Items))
+	seen := map[string]bool{}
+	var ids []string
+	for i, it := range in.Items {
+		results[i].ID = it.ID
+		if it.ID == "" || !validRequestID(it.ID) {
+			results[i].Result = "not_found"
+			continue
+		}
+		if _, err := uuid.Parse(it.Token); err != nil || len(it.Token) != 36 {
+			logRejection(r, rejectTokenMalformed)
+			results[i].Result = "invalid_token"
+			continue
+		}
+		if !seen[it.ID] {
+			seen[it.ID] = true
+			ids = append(ids, it.ID)
+		}
+	}
+
+	items := map[string]map[string]types.AttributeValue{}
+	for start := 0; start < len(ids); start += batchGetMaxKeys {
+		got, err := batchGetRequests(r.Context(), s.ddb, ids[start:min(start+batchGetMaxKeys, len(ids))],
+			[]string{"PK", "title", "status", "tags", "createdAt", "requesterToken"})
+		if err != nil {
+			writeStoreError(w, r, err, "failed to read")
+			return
+		}
+		for _, item := range got {
+			items[decodeRequestItem(item).PK] = item
+		}
+	}
+
+	for i, it := range in.Items {
+		if results[i].Result != "" {
+			continue
+		}
+		item, ok := items[requestPKPrefix+it.ID]
+		if !ok {
+			results[i].Result = "not_found"
+			continue
+		}
+		stored := decodeRequestItem(item).RequesterToken
+		if stored == "" || subtle.ConstantTimeCompare([]byte(stored), []byte(it.Token)) != 1 {
+			logRejection(r, rejectTokenMismatch)
+			results[i].Result = "forbidden"
+			continue
+		}
+		out := getRequestOutputFromItem(it.ID, item)
+		results[i].Result = "found"
+		results[i].Request = &out
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, results)
+}
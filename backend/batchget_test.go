@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchGetMixedTokens(t *testing.T) {
+	const (
+		tok1  = "11111111-1111-4111-8111-111111111111"
+		tok2  = "22222222-2222-4222-8222-222222222222"
+		wrong = "99999999-9999-4999-8999-999999999999"
+	)
+	stored := map[string]string{"REQ#r1": tok1, "REQ#r2": tok2}
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		if op != "BatchGetItem" {
+			t.Errorf("unexpected op %s", op)
+			return nil, nil
+		}
+		var items []any
+		for _, k := range in["RequestItems"].(map[string]any)[requestsTable].(map[string]any)["Keys"].([]any) {
+			pk := k.(map[string]any)["PK"].(map[string]any)["S"].(string)
+			if tok, ok := stored[pk]; ok {
+				items = append(items, map[string]any{
+					"PK":             map[string]any{"S": pk},
+					"title":          map[string]any{"S": "title of " + pk},
+					"status":         map[string]any{"S": "PENDING"},
+					"requesterToken": map[string]any{"S": tok},
+				})
+			}
+		}
+		return map[string]any{"Responses": map[string]any{requestsTable: items}}, nil
+	}}
+	srv := &server{ddb: fake.dynamoClient()}
+	t.Setenv("REJECTION_LOG", "false")
+	t.Setenv("BATCH_GET_MAX_ITEMS", "")
+
+	body := `{"items":[
+		{"id":"r1","t":"` + tok1 + `"},
+		{"id":"r2","t":"` + wrong + `"},
+		{"id":"r3","t":"` + tok1 + `"},
+		{"id":"r1","t":"not-a-uuid"},
+		{"id":"../r1","t":"` + tok1 + `"},
+		{"id":"r2","t":"` + tok2 + `"},
+		{"id":"r1","t":"` + tok2 + `"}
+	]}`
+	rec := httptest.NewRecorder()
+	srv.handleBatchGet(rec, httptest.NewRequest(http.MethodPost, "/requests/batch-get", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var got []BatchGetResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ id, result string }{
+		{"r1", "found"},
+		{"r2", "forbidden"},
+		{"r3", "not_found"},
+		{"r1", "invalid_token"},
+		{"../r1", "not_found"},
+		{"r2", "found"},
+		{"r1", "forbidden"}, // 同じIDでも別の依頼者のトークンなら中身は返さない
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].ID != w.id || got[i].Result != w.result {
+			t.Errorf("result[%d] = %s %s, want %s %s", i, got[i].ID, got[i].Result, w.id, w.result)
+		}
+		if (got[i].Request != nil) != (w.result == "found") {
+			t.Errorf("result[%d] %s: request included = %v", i, w.result, got[i].Request != nil)
+		}
+	}
+	if got[0].Request.Title != "title of REQ#r1" {
+		t.Errorf("found request title = %q", got[0].Request.Title)
+	}
+	if strings.Contains(rec.Body.String(), "requesterToken") || strings.Contains(rec.Body.String(), tok1) {
+		t.Error("response leaks stored tokens")
+	}
+	// 重複と不正なものを除いた r1, r2, r3 を1回で引く
+	calls := fake.callsOf("BatchGetItem")
+	if len(calls) != 1 {
+		t.Fatalf("BatchGetItem calls = %d, want 1", len(calls))
+	}
+	if keys := calls[0].Input["RequestItems"].(map[string]any)[requestsTable].(map[string]any)["Keys"].([]any); len(keys) != 3 {
+		t.Errorf("looked up %d keys, want 3", len(keys))
+	}
+}
+
+func TestBatchGetRejectsBadBatches(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty", body: `{"items":[]}`},
+		{name: "too many", body: `{"items":[{"id":"a","t":"x"},{"id":"b","t":"x"},{"id":"c","t":"x"}]}`},
+		{name: "bad json", body: `{"items":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BATCH_GET_MAX_ITEMS", "2")
+			fake := &fakeAWS{t: t}
+			srv := &server{ddb: fake.dynamoClient()}
+			rec := httptest.NewRecorder()
+			srv.handleBatchGet(rec, httptest.NewRequest(http.MethodPost, "/requests/batch-get", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			if len(fake.calls) != 0 {
+				t.Error("DynamoDB was called for a rejected batch")
+			}
+		})
+	}
+}
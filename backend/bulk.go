@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBulkMaxItems    = 100
+	defaultBulkConcurrency = 8
+)
+
+type BulkStatusInput struct {
+	RequestIDs []string `json:"requestIds"`
+	Status     string   `json:"status"`
+	Reason     string   `json:"reason,omitempty"` // 全件に同じ理由を付ける
+}
+
+type BulkStatusResult struct {
+	RequestID string `json:"requestId"`
+	Result    string `json:"result"` // success / event_pending / not_found / invalid_transition / error
+	EventID   string `json:"eventId,omitempty"`
+	ChangedAt string `json:"changedAt,omitempty"`
+}
+
+type BulkStatusOutput struct {
+	Status  string             `json:"status"`
+	Results []BulkStatusResult `json:"results"`
+}
+
+// POST /admin/requests/bulk-status (admin only)
+func (s *server) handleBulkStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeWrite); !ok {
+		return
+	}
+
+	var in BulkStatusInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	in.Status = normalizeStatus(in.Status)
+	if !isValidStatus(in.Status) {
+		httpError(w, r, "invalid status", http.StatusBadRequest)
+		return
+	}
+	in.Reason = strings.TrimSpace(in.Reason)
+	if err := validateStatusReason(in.Status, in.Reason); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(in.RequestIDs) == 0 {
+		httpError(w, r, "requestIds required", http.StatusBadRequest)
+		return
+	}
+	if len(in.RequestIDs) > s.bulkMaxItems {
+		httpError(w, r, "too many requestIds (max "+strconv.Itoa(s.bulkMaxItems)+")", http.StatusBadRequest)
+		return
+	}
+	for _, id := range in.RequestIDs {
+		if id == "" {
+			httpError(w, r, "empty requestId", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 固定数のworkerで並列処理（結果はインデックスで書き込むので順序は入力と同じ）
+	results := make([]BulkStatusResult, len(in.RequestIDs))
+	events := make([]*StatusChangedEvent, len(in.RequestIDs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(s.bulkConcurrency, len(in.RequestIDs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx], events[idx] = s.applyBulkStatus(r, in.RequestIDs[idx], in.Status, in.Reason)
+			}
+		}()
+	}
+	for i := range in.RequestIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	s.enqueueBulkEvents(r, results, events)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, BulkStatusOutput{
+		Status:  in.Status,
+		Results: results,
+	})
+}
+
+// DynamoDBの更新だけ行う。更新できたらイベントを返す（送信はまとめて enqueueBulkEvents で）
+func (s *server) applyBulkStatus(r *http.Request, id, status, reason string) (BulkStatusResult, *StatusChangedEvent) {
+	res := BulkStatusResult{RequestID: id}
+	changedAt := time.Now().UTC().Format(time.RFC3339)
+	ev := StatusChangedEvent{
+		EventID:   uuid.NewString(),
+		RequestID: id,
+		NewStatus: status,
+		ChangedAt: changedAt,
+		ChangedBy: changedByAdmin,
+		Reason:    reason,
+	}
+
+	err := transitionStatus(r.Context(), s.ddb, ev)
+	switch {
+	case errors.Is(err, errRequestNotFound):
+		res.Result = "not_found"
+		return res, nil
+	case errors.Is(err, errInvalidTransition):
+		res.Result = "invalid_transition"
+		return res, nil
+	case err != nil:
+		log.Printf("bulk update error: %v requestId=%s", err, id)
+		res.Result = "error"
+		return res, nil
+	}
+	res.EventID = ev.EventID
+	res.ChangedAt = changedAt
+	return res, &ev
+}
+
+// 更新できた分のイベントを SendMessageBatch でまとめて送る。
+// 送れなかったものは1件ずつのときと同じく pendingEvents に残して event_pending（それもできなければ error）
+func (s *server) enqueueBulkEvents(r *http.Request, results []BulkStatusResult, events []*StatusChangedEvent) {
+	var evs []StatusChangedEvent
+	for _, ev := range events {
+		if ev != nil {
+			evs = append(evs, *ev)
+		}
+	}
+	if len(evs) == 0 {
+		return
+	}
+	failed := enqueueStatusChangedBatch(r.Context(), s.sqs, s.queueURL, evs)
+	for i, ev := range events {
+		if ev == nil {
+			continue
+		}
+		qerr, ok := failed[ev.EventID]
+		if !ok {
+			results[i].Result = "success"
+			continue
+		}
+		// event_pending: ステータスは更新済み、イベントは後で再送される
+		if _, err := s.markPendingAfterEnqueueError(r.Context(), *ev, qerr); err != nil {
+			log.Printf("bulk enqueue error: %v requestId=%s", err, ev.RequestID)
+			results[i].Result = "error"
+			continue
+		}
+		results[i].Result = "event_pending"
+	}
+}
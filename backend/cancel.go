@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CancelOutput struct {
+	RequestID string `json:"requestId"`
+	NewStatus string `json:"newStatus"`
+	ChangedAt string `json:"changedAt"`
+	EventID   string `json:"eventId"`
+	Note      string `json:"note,omitempty"`
+}
+
+// POST /requests/{id}/cancel?t=...
+// 依頼者による取り下げ。TRIAGE/PENDING/IN_PROGRESS → CANCELLED のみ（終端済みなら409）
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request, id string) {
+	t, ok := requireRequesterToken(w, r)
+	if !ok {
+		return
+	}
+	if _, err := getRequesterItem(r.Context(), s.ddb, id, t, true); err != nil {
+		writeRequesterItemError(w, r, err)
+		return
+	}
+
+	changedAt := time.Now().UTC().Format(time.RFC3339)
+	ev := StatusChangedEvent{
+		EventID:   uuid.NewString(),
+		RequestID: id,
+		NewStatus: "CANCELLED",
+		ChangedAt: changedAt,
+		ChangedBy: changedByRequester,
+	}
+	err := transitionStatus(r.Context(), s.ddb, ev)
+	if errors.Is(err, errInvalidTransition) {
+		httpError(w, r, "request already closed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "failed to update")
+		return
+	}
+
+	pending, err := s.enqueueOrMarkPending(r.Context(), ev)
+	if err != nil {
+		httpError(w, r, "failed to enqueue", http.StatusInternalServerError)
+		return
+	}
+
+	out := CancelOutput{
+		RequestID: id,
+		NewStatus: ev.NewStatus,
+		ChangedAt: changedAt,
+		EventID:   ev.EventID,
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if pending {
+		out.Note = eventDelayedNote
+		w.WriteHeader(http.StatusAccepted)
+	}
+	writeJSON(w, r, out)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/joho/godotenv"
+)
+
+const (
+	queueName = "request-events"
+	dlqName   = "request-events-dlq"
+
+	sqsMaxBatch = 10 // ReceiveMessage / SendMessageBatch の1回あたりの上限
+)
+
+// 最後に1行JSONで出す
+type summary struct {
+	DryRun   bool `json:"dryRun"`
+	Received int  `json:"received"`
+	Moved    int  `json:"moved"`
+	Failed   int  `json:"failed"`
+}
+
+func newSQSClient(ctx context.Context) (*sqs.Client, error) {
+	endpoint := os.Getenv("SQS_ENDPOINT")
+	cfg, err := loadAWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// envの値（SQS_QUEUE_URL / SQS_DLQ_URL）があればそれ、なければキュー名から引く
+func resolveURL(ctx context.Context, c *sqs.Client, env, name string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+	out, err := c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.QueueUrl), nil
+}
+
+type replayer struct {
+	sqs      *sqs.Client
+	dlqURL   string
+	queueURL string
+	dryRun   bool
+	sum      summary
+}
+
+// DLQのメッセージを本来のキューへ戻す。本文とメッセージ属性（eventType等）はそのまま。
+// 送れたものだけDLQから消すので、失敗分はDLQに残る（visibility timeout後にまた見える）。
+// -dry-run は受信して数えるだけ（消さない。受信したものはvisibility timeoutの間見えなくなる）。
+// 失敗が1件でもあれば終了コード1
+func main() {
+	dryRun := flag.Bool("dry-run", false, "count DLQ messages without moving them")
+	maxMessages := flag.Int("max", 100, "stop after this many messages (0 = until the DLQ is empty)")
+	flag.Parse()
+
+	if os.Getenv("APP_ENV") != "production" {
+		_ = godotenv.Load(".env")
+	}
+	ctx := context.Background()
+
+	client, err := newSQSClient(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rp := &replayer{sqs: client, dryRun: *dryRun}
+	rp.sum.DryRun = *dryRun
+	if rp.dlqURL, err = resolveURL(ctx, client, "SQS_DLQ_URL", dlqName); err != nil {
+		log.Fatal(err)
+	}
+	if rp.queueURL, err = resolveURL(ctx, client, "SQS_QUEUE_URL", queueName); err != nil {
+		log.Fatal(err)
+	}
+
+	for *maxMessages == 0 || rp.sum.Received < *maxMessages {
+		n := sqsMaxBatch
+		if *maxMessages > 0 {
+			n = min(n, *maxMessages-rp.sum.Received)
+		}
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(rp.dlqURL),
+			MaxNumberOfMessages:   int32(n),
+			WaitTimeSeconds:       1,
+			VisibilityTimeout:     int32(envInt("DLQ_REPLAY_VISIBILITY_TIMEOUT", 30)),
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+		rp.sum.Received += len(out.Messages)
+		if !rp.dryRun {
+			rp.move(ctx, out.Messages)
+		}
+	}
+
+	b, _ := json.Marshal(rp.sum)
+	os.Stdout.Write(append(b, '\n'))
+	if rp.sum.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// SendMessageBatchで戻し、成功したエントリだけDeleteMessageBatchでDLQから消す
+func (rp *replayer) move(ctx context.Context, msgs []sqstypes.Message) {
+	entries := make([]sqstypes.SendMessageBatchRequestEntry, 0, len(msgs))
+	for i, m := range msgs {
+		entries = append(entries, sqstypes.SendMessageBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			MessageBody:       m.Body,
+			MessageAttributes: m.MessageAttributes,
+		})
+	}
+	sent, err := rp.sqs.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(rp.queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		rp.sum.Failed += len(msgs)
+		log.Printf("send batch error: %v", err)
+		return
+	}
+	for _, f := range sent.Failed {
+		rp.sum.Failed++
+		i, _ := strconv.Atoi(aws.ToString(f.Id))
+		log.Printf("send failed messageId=%s code=%s: %s", aws.ToString(msgs[i].MessageId), aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+	if len(sent.Successful) == 0 {
+		return
+	}
+
+	dels := make([]sqstypes.DeleteMessageBatchRequestEntry, 0, len(sent.Successful))
+	for _, s := range sent.Successful {
+		i, _ := strconv.Atoi(aws.ToString(s.Id))
+		dels = append(dels, sqstypes.DeleteMessageBatchRequestEntry{
+			Id:            s.Id,
+			ReceiptHandle: msgs[i].ReceiptHandle,
+		})
+	}
+	// 送信済みなので、消せなかったものは本キューとDLQの両方に残る（workerの重複チェックで二重適用はされない）
+	del, err := rp.sqs.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(rp.dlqURL),
+		Entries:  dels,
+	})
+	if err != nil {
+		rp.sum.Failed += len(dels)
+		log.Printf("delete batch error: %v", err)
+		return
+	}
+	for _, f := range del.Failed {
+		i, _ := strconv.Atoi(aws.ToString(f.Id))
+		log.Printf("delete failed messageId=%s code=%s: %s", aws.ToString(msgs[i].MessageId), aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+	rp.sum.Moved += len(del.Successful)
+	rp.sum.Failed += len(del.Failed)
+}
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
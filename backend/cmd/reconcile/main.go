@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+)
+
+const (
+	requestsTable = "Requests"
+	queueName     = "request-events"
+
+	eventSchemaVersion = 1 // APIの eventSchemaVersion と合わせる
+)
+
+type StatusChangedEvent struct {
+	EventID       string `json:"eventId"`
+	RequestID     string `json:"requestId"`
+	NewStatus     string `json:"newStatus"`
+	ChangedAt     string `json:"changedAt"`
+	ChangedBy     string `json:"changedBy,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+}
+
+// 定期ジョブ向けに最後に1行JSONで出す
+type summary struct {
+	Scanned           int `json:"scanned"`
+	StatusMismatch    int `json:"statusMismatch"`
+	OrphanLastEventID int `json:"orphanLastEventId"`
+	PendingEvents     int `json:"pendingEvents"`
+	Repaired          int `json:"repaired"`
+	RepairFailed      int `json:"repairFailed"`
+	Unresolved        int `json:"unresolved"`
+}
+
+func newDynamoClient(ctx context.Context) (*dynamodb.Client, error) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	cfg, err := loadAWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+func newSQSClient(ctx context.Context) (*sqs.Client, error) {
+	endpoint := os.Getenv("SQS_ENDPOINT")
+	cfg, err := loadAWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+func resolveQueueURL(ctx context.Context, c *sqs.Client) (string, error) {
+	if v := os.Getenv("SQS_QUEUE_URL"); v != "" {
+		return v, nil
+	}
+	out, err := c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.QueueUrl), nil
+}
+
+type reconciler struct {
+	ddb      *dynamodb.Client
+	sqs      *sqs.Client
+	queueURL string
+	repair   bool
+	sum      summary
+}
+
+// status と statusHistory の最新エントリ、lastEventId と履歴のeventIdを突き合わせる。
+// -repair を付けると、ずれているものは現在のstatusでイベントを再投入し、pendingEvents は再送する。
+// 未解決の不整合が残れば終了コード1
+func main() {
+	repair := flag.Bool("repair", false, "re-enqueue missing events and republish pendingEvents")
+	flag.Parse()
+
+	if os.Getenv("APP_ENV") != "production" {
+		_ = godotenv.Load(".env")
+	}
+	ctx := context.Background()
+
+	ddb, err := newDynamoClient(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rc := &reconciler{ddb: ddb, repair: *repair}
+	if rc.repair {
+		rc.sqs, err = newSQSClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rc.queueURL, err = resolveQueueURL(ctx, rc.sqs)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	p := dynamodb.NewScanPaginator(ddb, &dynamodb.ScanInput{
+		TableName:        aws.String(requestsTable),
+		FilterExpression: aws.String("begins_with(PK, :reqPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":reqPrefix": &types.AttributeValueMemberS{Value: "REQ#"},
+		},
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, item := range page.Items {
+			rc.check(ctx, item)
+		}
+	}
+
+	b, _ := json.Marshal(rc.sum)
+	os.Stdout.Write(append(b, '\n'))
+	if rc.sum.Unresolved > 0 {
+		os.Exit(1)
+	}
+}
+
+func (rc *reconciler) check(ctx context.Context, item map[string]types.AttributeValue) {
+	rc.sum.Scanned++
+	pk := stringAttr(item, "PK")
+	id := strings.TrimPrefix(pk, "REQ#")
+	status := stringAttr(item, "status")
+
+	var lastHistStatus string
+	var histEventIDs []string
+	if l, ok := item["statusHistory"].(*types.AttributeValueMemberL); ok {
+		for _, v := range l.Value {
+			m, ok := v.(*types.AttributeValueMemberM)
+			if !ok {
+				continue
+			}
+			lastHistStatus = stringAttr(m.Value, "newStatus")
+			histEventIDs = append(histEventIDs, stringAttr(m.Value, "eventId"))
+		}
+	}
+
+	var pending []string
+	if ss, ok := item["pendingEvents"].(*types.AttributeValueMemberSS); ok {
+		pending = ss.Value
+	}
+
+	// 作成直後（PENDINGで履歴なし）は正常。
+	// pendingEventsがあればそれが未送信のイベントなので、再送で揃うはず（二重に投入しない）
+	if (len(histEventIDs) == 0 && status != "PENDING") || (len(histEventIDs) > 0 && lastHistStatus != status) {
+		rc.sum.StatusMismatch++
+		log.Printf("status mismatch requestId=%s status=%s lastHistory=%q", id, status, lastHistStatus)
+		switch {
+		case len(pending) > 0:
+		case rc.repair:
+			rc.result(rc.reenqueue(ctx, id, item), id)
+		default:
+			rc.sum.Unresolved++
+		}
+	}
+
+	// lastEventIdは最新の履歴エントリと同時に書かれるので、履歴にないのはworker側の書き込み異常（自動修復しない）
+	if last := stringAttr(item, "lastEventId"); last != "" && !slices.Contains(histEventIDs, last) {
+		rc.sum.OrphanLastEventID++
+		rc.sum.Unresolved++
+		log.Printf("lastEventId not in history requestId=%s lastEventId=%s", id, last)
+	}
+
+	for _, body := range pending {
+		rc.sum.PendingEvents++
+		log.Printf("pending event requestId=%s event=%s", id, body)
+		if rc.repair {
+			rc.result(rc.republish(ctx, pk, body), id)
+		} else {
+			rc.sum.Unresolved++
+		}
+	}
+}
+
+func (rc *reconciler) result(err error, id string) {
+	if err != nil {
+		rc.sum.RepairFailed++
+		rc.sum.Unresolved++
+		log.Printf("repair failed requestId=%s: %v", id, err)
+		return
+	}
+	rc.sum.Repaired++
+}
+
+// 現在のstatusを新しいeventIdで投入し直す（replayと同じ）
+func (rc *reconciler) reenqueue(ctx context.Context, id string, item map[string]types.AttributeValue) error {
+	changedAt := stringAttr(item, "statusUpdatedAt")
+	if changedAt == "" {
+		changedAt = stringAttr(item, "createdAt")
+	}
+	if changedAt == "" {
+		changedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	body, err := json.Marshal(StatusChangedEvent{
+		EventID:       uuid.NewString(),
+		RequestID:     id,
+		NewStatus:     stringAttr(item, "status"),
+		ChangedAt:     changedAt,
+		ChangedBy:     stringAttr(item, "statusChangedBy"),
+		Reason:        stringAttr(item, "statusReason"),
+		SchemaVersion: eventSchemaVersion,
+	})
+	if err != nil {
+		return err
+	}
+	return rc.send(ctx, string(body))
+}
+
+// pendingEventsのJSONをそのまま送り、送れたら集合から外す
+func (rc *reconciler) republish(ctx context.Context, pk, body string) error {
+	if err := rc.send(ctx, body); err != nil {
+		return err
+	}
+	_, err := rc.ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+		},
+		UpdateExpression: aws.String("DELETE pendingEvents :ev"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ev": &types.AttributeValueMemberSS{Value: []string{body}},
+		},
+	})
+	return err
+}
+
+func (rc *reconciler) send(ctx context.Context, body string) error {
+	_, err := rc.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(rc.queueURL),
+		MessageBody: aws.String(body),
+	})
+	return err
+}
+
+func stringAttr(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// 処理済みイベントの生JSONをS3に残す（DynamoDBの履歴とは別の長期保存用）
+type eventArchiver struct {
+	s3     *s3.Client
+	bucket string
+}
+
+// EVENT_ARCHIVE_BUCKET が空ならアーカイブしない（nil を返す）
+func newEventArchiverFromEnv(ctx context.Context) (*eventArchiver, error) {
+	bucket := os.Getenv("EVENT_ARCHIVE_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+	endpoint := os.Getenv("S3_ENDPOINT") // 空なら実AWS
+	cfg, err := loadAWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	c := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true // LocalStackはバケットのサブドメインを解決できない
+		}
+	})
+	return &eventArchiver{s3: c, bucket: bucket}, nil
+}
+
+// events/YYYY/MM/DD/<requestId>/<eventId>.json
+// 日付はchangedAt基準（再配信で日付がずれないように）。同じeventIdは同じキーに上書きされるだけ
+func archiveKey(ev StatusChangedEvent) string {
+	t, err := time.Parse(time.RFC3339, ev.ChangedAt)
+	if err != nil {
+		t = time.Now()
+	}
+	return fmt.Sprintf("events/%s/%s/%s.json", t.UTC().Format("2006/01/02"), ev.RequestID, ev.EventID)
+}
+
+func (a *eventArchiver) Archive(ctx context.Context, ev StatusChangedEvent, raw string) error {
+	_, err := a.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(archiveKey(ev)),
+		Body:        strings.NewReader(raw),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
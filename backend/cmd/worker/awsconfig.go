@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// AWS_PROFILE があればそのプロファイル、AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY があれば静的クレデンシャルを使う。
+// endpoint が空（= 実AWS）のときは、起動時にクレデンシャルが取れることを確認しておく
+func loadAWSConfig(ctx context.Context, endpoint string) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(os.Getenv("AWS_REGION")),
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		opts = append(opts, config.WithSharedConfigProfile(p))
+	} else if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(id, secret, os.Getenv("AWS_SESSION_TOKEN")),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if endpoint == "" {
+		if cfg.Region == "" {
+			return aws.Config{}, fmt.Errorf("AWS_REGION is required when no endpoint is set")
+		}
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			return aws.Config{}, fmt.Errorf("no endpoint set and no AWS credentials found (set AWS_PROFILE or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY): %w", err)
+		}
+	}
+	return cfg, nil
+}
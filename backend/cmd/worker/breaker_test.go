@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// now を差し替えて cooldown を待たずに状態遷移を確かめる。threshold=3, cooldown=30s
+func newTestBreaker(t *testing.T) (*circuitBreaker, func(time.Duration)) {
+	t.Helper()
+	clock := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(3, 30*time.Second)
+	b.now = func() time.Time { return clock }
+	return b, func(d time.Duration) { clock = clock.Add(d) }
+}
+
+// threshold 回続けて失敗させて open にする
+func tripBreaker(t *testing.T, b *circuitBreaker) {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("call %d rejected while closed", i)
+		}
+		if s := b.State(); s != breakerClosed {
+			t.Fatalf("state after %d failure(s) = %s, want closed", i, s)
+		}
+		b.Failure()
+	}
+	if s := b.State(); s != breakerOpen {
+		t.Fatalf("state after threshold = %s, want open", s)
+	}
+}
+
+func TestCircuitBreakerOpensAtThreshold(t *testing.T) {
+	b, _ := newTestBreaker(t)
+	tripBreaker(t, b)
+}
+
+func TestCircuitBreakerCountsConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(t)
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if s := b.State(); s != breakerClosed {
+		t.Errorf("state = %s, want closed", s)
+	}
+}
+
+func TestCircuitBreakerOpenRejectsUntilCooldown(t *testing.T) {
+	b, advance := newTestBreaker(t)
+	tripBreaker(t, b)
+	for _, d := range []time.Duration{0, time.Second, 28 * time.Second} {
+		advance(d)
+		if b.Allow() {
+			t.Fatalf("call allowed while open")
+		}
+	}
+	advance(time.Second)
+	if s := b.State(); s != breakerHalfOpen {
+		t.Errorf("state after cooldown = %s, want half-open", s)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsSingleTrial(t *testing.T) {
+	b, advance := newTestBreaker(t)
+	tripBreaker(t, b)
+	advance(30 * time.Second)
+	if !b.Allow() {
+		t.Fatal("trial call rejected after cooldown")
+	}
+	for i := 0; i < 3; i++ {
+		if b.Allow() {
+			t.Fatalf("extra call %d allowed while the trial is in flight", i)
+		}
+	}
+}
+
+func TestCircuitBreakerTrialSuccessCloses(t *testing.T) {
+	b, advance := newTestBreaker(t)
+	tripBreaker(t, b)
+	advance(30 * time.Second)
+	b.Allow()
+	b.Success()
+	if s := b.State(); s != breakerClosed {
+		t.Fatalf("state after trial success = %s, want closed", s)
+	}
+	// 失敗回数も0からやり直し
+	tripBreaker(t, b)
+}
+
+func TestCircuitBreakerTrialFailureReopens(t *testing.T) {
+	b, advance := newTestBreaker(t)
+	tripBreaker(t, b)
+	advance(30 * time.Second)
+	b.Allow()
+	b.Failure()
+	if s := b.State(); s != breakerOpen {
+		t.Fatalf("state after trial failure = %s, want open", s)
+	}
+	// cooldown は再度openした時点から数える
+	advance(29 * time.Second)
+	if b.Allow() {
+		t.Fatal("call allowed before the new cooldown elapsed")
+	}
+	advance(time.Second)
+	if !b.Allow() {
+		t.Error("trial rejected after the new cooldown")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// メッセージ属性 eventType（APIと合わせる）。属性がなければ StatusChanged
+const (
+	eventTypeAttr           = "eventType"
+	eventTypeStatusChanged  = "StatusChanged"
+	eventTypeRequestCreated = "RequestCreated"
+)
+
+// EMIT_CREATED_EVENTS=true のときAPIが作成時に送るイベント
+type RequestCreatedEvent struct {
+	EventID       string `json:"eventId"`
+	RequestID     string `json:"requestId"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"createdAt"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+}
+
+func messageEventType(m sqstypes.Message) string {
+	if v, ok := m.MessageAttributes[eventTypeAttr]; ok && aws.ToString(v.StringValue) != "" {
+		return aws.ToString(v.StringValue)
+	}
+	return eventTypeStatusChanged
+}
+
+// 作成イベントは初期ステータスへの遷移として履歴の先頭に1件入れる。
+// 冪等性・順序の扱いはステータス変更と同じ（先にステータス変更が適用済みなら古いイベントとして捨てる）。通知はしない
+func (wk *worker) processRequestCreated(ctx context.Context, queueURL string, m sqstypes.Message) bool {
+	var ce RequestCreatedEvent
+	if err := json.Unmarshal([]byte(*m.Body), &ce); err != nil || ce.EventID == "" || ce.RequestID == "" {
+		log.Printf("bad created event: %v body=%q", err, *m.Body)
+		_ = wk.deleteMessage(ctx, queueURL, m)
+		return false
+	}
+	if ce.SchemaVersion > currentEventSchemaVersion {
+		log.Printf("%v: %d eventId=%s requestId=%s, moving to dlq", errUnknownSchemaVersion, ce.SchemaVersion, ce.EventID, ce.RequestID)
+		wk.deadLetter(ctx, queueURL, m)
+		return false
+	}
+	if !knownStatuses[ce.Status] {
+		log.Printf("unknown status in created event: %q eventId=%s requestId=%s", ce.Status, ce.EventID, ce.RequestID)
+		_ = wk.deleteMessage(ctx, queueURL, m)
+		return false
+	}
+	return wk.applyEvent(ctx, queueURL, m, StatusChangedEvent{
+		EventID:   ce.EventID,
+		RequestID: ce.RequestID,
+		NewStatus: ce.Status,
+		ChangedAt: ce.CreatedAt,
+		ChangedBy: "created",
+	}, false)
+}
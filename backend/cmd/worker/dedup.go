@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const defaultProcessedEventIDsMax = 100
+
+// processedEventIds(SS)は順序を持たないので、記録順は processedEventOrder(L) に並べて持つ（APIとworkerが集合と同時に追記）。
+// statusHistory は HISTORY_STORAGE=items では無いので古さの基準にできない。
+// 並びに無いID（processedEventOrder 導入前に記録されたもの）が最も古く、その次が並びの先頭側。
+func trimProcessedEventIDs(ctx context.Context, ddb *dynamodb.Client, requestID string, attrs map[string]types.AttributeValue) error {
+	maxIDs := envInt("PROCESSED_EVENT_IDS_MAX", defaultProcessedEventIDsMax)
+
+	set, ok := attrs["processedEventIds"].(*types.AttributeValueMemberSS)
+	if !ok || len(set.Value) <= maxIDs {
+		return nil
+	}
+
+	var ordered []string
+	if l, ok := attrs["processedEventOrder"].(*types.AttributeValueMemberL); ok {
+		for _, v := range l.Value {
+			if id, ok := v.(*types.AttributeValueMemberS); ok {
+				ordered = append(ordered, id.Value)
+			}
+		}
+	}
+
+	inOrder := make(map[string]bool, len(ordered))
+	for _, id := range ordered {
+		inOrder[id] = true
+	}
+	inSet := make(map[string]bool, len(set.Value))
+	var oldestFirst []string
+	for _, id := range set.Value {
+		inSet[id] = true
+		if !inOrder[id] {
+			oldestFirst = append(oldestFirst, id)
+		}
+	}
+	// 並びの先頭から削る分（集合から消えているIDも一緒に詰める）
+	prefix := 0
+	for _, id := range ordered {
+		if len(oldestFirst) >= len(set.Value)-maxIDs {
+			break
+		}
+		if inSet[id] {
+			oldestFirst = append(oldestFirst, id)
+			inSet[id] = false
+		}
+		prefix++
+	}
+
+	drop := oldestFirst[:len(set.Value)-maxIDs]
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + requestID},
+		},
+		UpdateExpression: aws.String("DELETE processedEventIds :old"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":old": &types.AttributeValueMemberSS{Value: drop},
+		},
+	}
+	if prefix > 0 {
+		removes := make([]string, 0, prefix)
+		for i := 0; i < prefix; i++ {
+			removes = append(removes, fmt.Sprintf("processedEventOrder[%d]", i))
+		}
+		input.UpdateExpression = aws.String(*input.UpdateExpression + " REMOVE " + strings.Join(removes, ", "))
+		// 読んでから書くまでに他のworkerがトリムしていたら失敗させる（次の追記で再びトリムされる）
+		input.ConditionExpression = aws.String("size(processedEventOrder) = :n")
+		input.ExpressionAttributeValues[":n"] = &types.AttributeValueMemberN{Value: strconv.Itoa(len(ordered))}
+	}
+	_, err := ddb.UpdateItem(ctx, input)
+	return err
+}
+
+// 同じバッチ内で同じeventIdが重複していたら2件目以降はその場で削除し、処理対象から外す。
+// バッチをまたぐ重複はprocessedEventIdsの条件で防ぐ（こちらは無駄なUpdateItemを減らすだけ）。
+func dedupBatch(msgs []sqstypes.Message, drop func(sqstypes.Message)) []sqstypes.Message {
+	seen := make(map[string]bool, len(msgs))
+	out := msgs[:0:0]
+	for _, m := range msgs {
+		if m.Body == nil || m.ReceiptHandle == nil {
+			out = append(out, m)
+			continue
+		}
+		var head struct {
+			EventID string `json:"eventId"`
+		}
+		// 壊れたJSONやeventIdなしは判定できないのでそのまま通す（handleMessage側で処理）
+		if json.Unmarshal([]byte(*m.Body), &head) != nil || head.EventID == "" {
+			out = append(out, m)
+			continue
+		}
+		if seen[head.EventID] {
+			log.Printf("duplicate in batch, deleting eventId=%s", head.EventID)
+			drop(m)
+			continue
+		}
+		seen[head.EventID] = true
+		out = append(out, m)
+	}
+	return out
+}
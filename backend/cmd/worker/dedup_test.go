@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Requestsテーブルの1件だけを持つフェイク。appendStatusHistory の条件式と更新、
+// trimProcessedEventIDs の DELETE / REMOVE、enforceHistoryRetention の REMOVE を本物と同じ意味で評価する
+type fakeRequestItem struct {
+	mu          sync.Mutex
+	exists      bool
+	lastEventID string
+	lastApplied string
+	processed   []string
+	order       []string // processedEventOrder
+	history     []any // statusHistory の要素（DynamoDB JSONの {"M":{...}}）
+}
+
+func (it *fakeRequestItem) wire() map[string]any {
+	item := map[string]any{"PK": map[string]any{"S": "REQ#r1"}}
+	if it.lastEventID != "" {
+		item["lastEventId"] = map[string]any{"S": it.lastEventID}
+	}
+	if it.lastApplied != "" {
+		item["lastAppliedChangedAt"] = map[string]any{"S": it.lastApplied}
+	}
+	if len(it.processed) > 0 {
+		// 集合は順序を持たない（記録順では返らない）
+		item["processedEventIds"] = map[string]any{"SS": slices.Sorted(slices.Values(it.processed))}
+	}
+	if it.order != nil {
+		l := make([]any, 0, len(it.order))
+		for _, id := range it.order {
+			l = append(l, map[string]any{"S": id})
+		}
+		item["processedEventOrder"] = map[string]any{"L": l}
+	}
+	if it.history != nil {
+		item["statusHistory"] = map[string]any{"L": it.history}
+	}
+	return item
+}
+
+func (it *fakeRequestItem) historyEventIDs() []string {
+	var ids []string
+	for _, h := range it.history {
+		ids = append(ids, h.(map[string]any)["M"].(map[string]any)["eventId"].(map[string]any)["S"].(string))
+	}
+	return ids
+}
+
+func (it *fakeRequestItem) handle(op string, in map[string]any) (any, error) {
+	if op != "UpdateItem" {
+		return nil, nil
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	expr := in["UpdateExpression"].(string)
+	vals := in["ExpressionAttributeValues"].(map[string]any)
+	str := func(k string) string { return vals[k].(map[string]any)["S"].(string) }
+
+	if strings.HasPrefix(expr, "DELETE processedEventIds") {
+		if n := strings.Count(expr, "processedEventOrder["); n > 0 {
+			if vals[":n"].(map[string]any)["N"] != strconv.Itoa(len(it.order)) {
+				return nil, awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException"}
+			}
+			it.order = it.order[n:]
+		}
+		for _, id := range vals[":old"].(map[string]any)["SS"].([]any) {
+			it.processed = slices.DeleteFunc(it.processed, func(v string) bool { return v == id })
+		}
+		return nil, nil
+	}
+	if strings.HasPrefix(expr, "REMOVE statusHistory[") {
+		if vals[":n"].(map[string]any)["N"] != strconv.Itoa(len(it.history)) {
+			return nil, awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException"}
+		}
+		it.history = it.history[strings.Count(expr, "statusHistory["):]
+		return nil, nil
+	}
+
+	eid, ca := str(":eid"), str(":ca")
+	if !it.exists || it.lastEventID == eid || slices.Contains(it.processed, eid) || (it.lastApplied != "" && it.lastApplied > ca) {
+		var old map[string]any
+		if it.exists {
+			old = it.wire()
+		}
+		return nil, awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException", Item: old}
+	}
+	it.lastEventID, it.lastApplied = eid, ca
+	it.processed = append(it.processed, eid)
+	it.order = append(it.order, eid)
+	if h, ok := vals[":h"]; ok {
+		it.history = append(it.history, h.(map[string]any)["L"].([]any)...)
+	}
+	return map[string]any{"Attributes": it.wire()}, nil
+}
+
+func TestApplyStatusEventDedup(t *testing.T) {
+	ev := func(id, at string) StatusChangedEvent {
+		return StatusChangedEvent{EventID: id, RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: at}
+	}
+	e1 := ev("e1", "2024-05-01T09:00:00Z")
+	e2 := ev("e2", "2024-05-01T09:01:00Z")
+	e3 := ev("e3", "2024-05-01T09:02:00Z")
+
+	tests := []struct {
+		name        string
+		missing     bool
+		events      []StatusChangedEvent
+		want        []error
+		wantHistory []string
+	}{
+		{name: "in order", events: []StatusChangedEvent{e1, e2}, want: []error{nil, nil}, wantHistory: []string{"e1", "e2"}},
+		{name: "redelivered duplicate", events: []StatusChangedEvent{e1, e1}, want: []error{nil, errDuplicateEvent}, wantHistory: []string{"e1"}},
+		{name: "reordered: older after newer", events: []StatusChangedEvent{e2, e1}, want: []error{nil, errStaleEvent}, wantHistory: []string{"e2"}},
+		// lastEventId だけでは e1 を見逃すが、processedEventIds の集合で弾ける
+		{name: "older duplicate after newer", events: []StatusChangedEvent{e1, e2, e1}, want: []error{nil, nil, errDuplicateEvent}, wantHistory: []string{"e1", "e2"}},
+		{name: "duplicate interleaved", events: []StatusChangedEvent{e1, e2, e2, e3}, want: []error{nil, nil, errDuplicateEvent, nil}, wantHistory: []string{"e1", "e2", "e3"}},
+		{name: "missing request", missing: true, events: []StatusChangedEvent{e1}, want: []error{errRequestMissing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &fakeRequestItem{exists: !tt.missing}
+			fake := &fakeAWS{t: t, handle: item.handle}
+			ddb := fake.dynamoClient()
+			for i, e := range tt.events {
+				if err := applyStatusEvent(context.Background(), ddb, e); !errors.Is(err, tt.want[i]) || (err == nil) != (tt.want[i] == nil) {
+					t.Fatalf("event %d (%s): err = %v, want %v", i, e.EventID, err, tt.want[i])
+				}
+			}
+			if got := item.historyEventIDs(); !reflect.DeepEqual(got, tt.wantHistory) {
+				t.Errorf("statusHistory = %v, want %v", got, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestProcessedEventIDsAreBounded(t *testing.T) {
+	t.Setenv("PROCESSED_EVENT_IDS_MAX", "2")
+	item := &fakeRequestItem{exists: true}
+	fake := &fakeAWS{t: t, handle: item.handle}
+	ddb := fake.dynamoClient()
+	for _, e := range []StatusChangedEvent{
+		{EventID: "e1", RequestID: "r1", NewStatus: "PENDING", ChangedAt: "2024-05-01T09:00:00Z"},
+		{EventID: "e2", RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: "2024-05-01T09:01:00Z"},
+		{EventID: "e3", RequestID: "r1", NewStatus: "DONE", ChangedAt: "2024-05-01T09:02:00Z"},
+	} {
+		if err := applyStatusEvent(context.Background(), ddb, e); err != nil {
+			t.Fatalf("apply %s: %v", e.EventID, err)
+		}
+	}
+	// 最も古い e1 から削られる
+	if want := []string{"e2", "e3"}; !reflect.DeepEqual(item.processed, want) {
+		t.Errorf("processedEventIds = %v, want %v", item.processed, want)
+	}
+}
+
+// HISTORY_STORAGE=items では statusHistory が無いので、記録順（processedEventOrder）で古いものから削る。
+// 導入前に記録された並びに無いIDはそれより古い扱い
+func TestProcessedEventIDsAreBoundedInItemsMode(t *testing.T) {
+	t.Setenv("HISTORY_STORAGE", "items")
+	t.Setenv("PROCESSED_EVENT_IDS_MAX", "2")
+	item := &fakeRequestItem{exists: true, processed: []string{"legacy"}}
+	fake := &fakeAWS{t: t, handle: item.handle}
+	ddb := fake.dynamoClient()
+	for _, e := range []StatusChangedEvent{
+		{EventID: "e9", RequestID: "r1", NewStatus: "PENDING", ChangedAt: "2024-05-01T09:00:00Z"},
+		{EventID: "e1", RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: "2024-05-01T09:01:00Z"},
+		{EventID: "e5", RequestID: "r1", NewStatus: "DONE", ChangedAt: "2024-05-01T09:02:00Z"},
+	} {
+		if err := applyStatusEvent(context.Background(), ddb, e); err != nil {
+			t.Fatalf("apply %s: %v", e.EventID, err)
+		}
+		if len(item.history) != 0 {
+			t.Fatalf("statusHistory written in items mode: %v", item.history)
+		}
+	}
+	// legacy → e9 の順に削られる（IDの文字列順ではなく記録順）
+	if want := []string{"e1", "e5"}; !reflect.DeepEqual(item.processed, want) {
+		t.Errorf("processedEventIds = %v, want %v", item.processed, want)
+	}
+	if want := []string{"e1", "e5"}; !reflect.DeepEqual(item.order, want) {
+		t.Errorf("processedEventOrder = %v, want %v", item.order, want)
+	}
+	for _, c := range fake.callsOf("UpdateItem") {
+		if strings.Contains(c.Input["UpdateExpression"].(string), "statusHistory") {
+			t.Errorf("UpdateItem touches statusHistory in items mode: %s", c.Input["UpdateExpression"])
+		}
+	}
+}
+
+func TestDedupBatch(t *testing.T) {
+	msg := func(id, body string) sqstypes.Message {
+		return sqstypes.Message{MessageId: aws.String(id), ReceiptHandle: aws.String("rh-" + id), Body: aws.String(body)}
+	}
+	msgs := []sqstypes.Message{
+		msg("m1", `{"eventId":"e1"}`),
+		msg("m2", `{"eventId":"e2"}`),
+		msg("m3", `{"eventId":"e1"}`),
+		msg("m4", `not json`),
+		msg("m5", `{"eventId":""}`),
+	}
+	var dropped []string
+	out := dedupBatch(msgs, func(m sqstypes.Message) { dropped = append(dropped, *m.MessageId) })
+
+	var kept []string
+	for _, m := range out {
+		kept = append(kept, *m.MessageId)
+	}
+	if want := []string{"m1", "m2", "m4", "m5"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept = %v, want %v", kept, want)
+	}
+	if want := []string{"m3"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped = %v, want %v", dropped, want)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestDeleteMessageRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32 // 先頭から何回失敗させるか
+		wantErr      bool
+		wantCalls    int
+		wantRetries  float64
+		wantFailures float64
+	}{
+		{name: "succeeds first time", failures: 0, wantCalls: 1},
+		{name: "fails once then succeeds", failures: 1, wantCalls: 2, wantRetries: 1},
+		{name: "fails every attempt", failures: 100, wantErr: true, wantCalls: 3, wantRetries: 2, wantFailures: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DELETE_RETRIES", "3")
+			t.Setenv("DELETE_RETRY_BACKOFF", "1ms")
+			var n atomic.Int32
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+				if op == "DeleteMessage" && n.Add(1) <= tt.failures {
+					return nil, awsError{Status: http.StatusInternalServerError, Code: "InternalError"}
+				}
+				return nil, nil
+			}}
+			wk := &worker{sqs: fake.sqsClient()}
+			retries, failures := counterValue(deleteRetries), counterValue(deleteFailures)
+
+			m := sqstypes.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("rh-1")}
+			err := wk.deleteMessage(context.Background(), "http://fake/queue", m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			calls := fake.callsOf("DeleteMessage")
+			if len(calls) != tt.wantCalls {
+				t.Errorf("DeleteMessage calls = %d, want %d", len(calls), tt.wantCalls)
+			}
+			for _, c := range calls {
+				if c.Input["ReceiptHandle"] != "rh-1" {
+					t.Errorf("ReceiptHandle = %v", c.Input["ReceiptHandle"])
+				}
+			}
+			if got := counterValue(deleteRetries) - retries; got != tt.wantRetries {
+				t.Errorf("worker_delete_retries_total += %v, want %v", got, tt.wantRetries)
+			}
+			if got := counterValue(deleteFailures) - failures; got != tt.wantFailures {
+				t.Errorf("worker_delete_failures_total += %v, want %v", got, tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	sestypes "github.com/aws/aws-sdk-go-v2/service/ses/types"
+)
+
+var (
+	emailSubjectTemplate = template.Must(template.New("subject").Parse(
+		`[{{.NewStatus}}] {{.Title}}`))
+	emailBodyTemplate = template.Must(template.New("body").Parse(
+		`Your request "{{.Title}}" is now {{.NewStatus}}.
+{{if .Reason}}
+Reason: {{.Reason}}
+{{end}}
+Request ID: {{.RequestID}}
+Changed at: {{.ChangedAt}}
+`))
+)
+
+// テンプレートに渡す値
+type statusEmail struct {
+	RequestID string
+	Title     string
+	NewStatus string
+	ChangedAt string
+	Reason    string
+}
+
+// 作成時に requesterEmail があった依頼へ、ステータス変更をSESでメールする
+type emailNotifier struct {
+	ses    *ses.Client
+	ddb    *dynamodb.Client
+	sender string
+}
+
+// SES_SENDER が空ならメールしない（nil を返す）。SES_ENDPOINT が空なら実AWS
+func newEmailNotifierFromEnv(ctx context.Context, ddb *dynamodb.Client) (*emailNotifier, error) {
+	sender := os.Getenv("SES_SENDER")
+	if sender == "" {
+		return nil, nil
+	}
+	endpoint := os.Getenv("SES_ENDPOINT")
+	cfg, err := loadAWSConfig(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	c := ses.NewFromConfig(cfg, func(o *ses.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &emailNotifier{ses: c, ddb: ddb, sender: sender}, nil
+}
+
+// アドレスが登録されていなければログだけで何もしない
+func (n *emailNotifier) Notify(ctx context.Context, ev StatusChangedEvent) error {
+	out, err := n.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(requestsTable),
+		Key:                  map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + ev.RequestID}},
+		ProjectionExpression: aws.String("requesterEmail, title"),
+	})
+	if err != nil {
+		return err
+	}
+	to := stringAttr(out.Item, "requesterEmail")
+	if to == "" {
+		log.Printf("email skipped: no requester email on file requestId=%s", ev.RequestID)
+		return nil
+	}
+
+	data := statusEmail{
+		RequestID: ev.RequestID,
+		Title:     stringAttr(out.Item, "title"),
+		NewStatus: ev.NewStatus,
+		ChangedAt: ev.ChangedAt,
+		Reason:    ev.Reason,
+	}
+	var subject, body bytes.Buffer
+	if err := emailSubjectTemplate.Execute(&subject, data); err != nil {
+		return err
+	}
+	if err := emailBodyTemplate.Execute(&body, data); err != nil {
+		return err
+	}
+	_, err = n.ses.SendEmail(ctx, &ses.SendEmailInput{
+		Source:      aws.String(n.sender),
+		Destination: &sestypes.Destination{ToAddresses: []string{to}},
+		Message: &sestypes.Message{
+			Subject: &sestypes.Content{Data: aws.String(subject.String()), Charset: aws.String("UTF-8")},
+			Body: &sestypes.Body{
+				Text: &sestypes.Content{Data: aws.String(body.String()), Charset: aws.String("UTF-8")},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// HISTORY_STORAGE=items: 履歴を statusHistory ではなく1イベント1itemで RequestEvents に書く（APIと合わせる）
+const eventsTable = "RequestEvents"
+
+func historyItemsMode() bool {
+	return os.Getenv("HISTORY_STORAGE") == "items"
+}
+
+// キーにeventIdを含むので同じイベントの再配信は条件失敗になるだけ（成功扱い）。
+// 順序が入れ替わって届いた古いイベントもSK（changedAt順）の正しい位置に入る
+func putEventItem(ctx context.Context, ddb *dynamodb.Client, ev StatusChangedEvent, handledAt string) error {
+	item := map[string]types.AttributeValue{
+		"PK":        &types.AttributeValueMemberS{Value: "REQ#" + ev.RequestID},
+		"SK":        &types.AttributeValueMemberS{Value: "EVT#" + ev.ChangedAt + "#" + ev.EventID},
+		"eventId":   &types.AttributeValueMemberS{Value: ev.EventID},
+		"requestId": &types.AttributeValueMemberS{Value: ev.RequestID},
+		"newStatus": &types.AttributeValueMemberS{Value: ev.NewStatus},
+		"changedAt": &types.AttributeValueMemberS{Value: ev.ChangedAt},
+		"handledAt": &types.AttributeValueMemberS{Value: handledAt},
+	}
+	if ev.ChangedBy != "" {
+		item["changedBy"] = &types.AttributeValueMemberS{Value: ev.ChangedBy}
+	}
+	if ev.Reason != "" {
+		item["reason"] = &types.AttributeValueMemberS{Value: ev.Reason}
+	}
+	_, err := ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(eventsTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
+	})
+	var cfe *types.ConditionalCheckFailedException
+	if errors.As(err, &cfe) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// このworkerが扱えるStatusChangedEventの最新バージョン（APIの eventSchemaVersion と合わせる）
+const currentEventSchemaVersion = 1
+
+const dlqName = "request-events-dlq"
+
+var errUnknownSchemaVersion = errors.New("unknown event schema version")
+
+// 受信したイベントを現行の形にそろえる。古いバージョンは1段ずつ上げる。
+// デプロイ途中にAPIだけ新しくなった場合など、知らないバージョンはエラー
+func upgradeEvent(ev *StatusChangedEvent) error {
+	switch ev.SchemaVersion {
+	case 0:
+		// schemaVersion導入前のイベント。形はv1と同じでフィールドがないだけ
+		ev.SchemaVersion = 1
+		fallthrough
+	case currentEventSchemaVersion:
+		return nil
+	default:
+		return fmt.Errorf("%w: %d", errUnknownSchemaVersion, ev.SchemaVersion)
+	}
+}
+
+// SQS_DLQ_URL、なければ request-events-dlq を引く。見つからなければ空（DLQへは送らない）
+func resolveDLQURL(ctx context.Context, c *sqs.Client) string {
+	if v := os.Getenv("SQS_DLQ_URL"); v != "" {
+		return v
+	}
+	out, err := c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(dlqName)})
+	if err != nil {
+		log.Printf("dlq not found, unprocessable events are left for the redrive policy: %v", err)
+		return ""
+	}
+	return aws.ToString(out.QueueUrl)
+}
+
+// APIが入れた deadline を過ぎたか。deadlineのないイベント（古いAPI、作成イベント、reconcileの再送）は
+// workerの EVENT_MAX_AGE（既定0=期限なし）とSQSの送信時刻（SentTimestamp）で判定する
+func eventExpired(ev StatusChangedEvent, m sqstypes.Message, now time.Time) bool {
+	if ev.Deadline != "" {
+		t, err := time.Parse(time.RFC3339, ev.Deadline)
+		return err == nil && now.After(t)
+	}
+	maxAge := envDuration("EVENT_MAX_AGE", 0)
+	if maxAge == 0 {
+		return false
+	}
+	ms, err := strconv.ParseInt(m.Attributes[string(sqstypes.MessageSystemAttributeNameSentTimestamp)], 10, 64)
+	return err == nil && now.Sub(time.UnixMilli(ms)) > maxAge
+}
+
+// 処理できないメッセージをDLQへ移す。DLQがなければ消さずに残し、redrive policyに任せる
+func (wk *worker) deadLetter(ctx context.Context, queueURL string, m sqstypes.Message) {
+	if wk.dlqURL == "" {
+		return
+	}
+	_, err := wk.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(wk.dlqURL),
+		MessageBody:       m.Body,
+		MessageAttributes: m.MessageAttributes,
+	})
+	if err != nil {
+		log.Printf("dlq send error: %v", err)
+		return
+	}
+	if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+		log.Printf("delete error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestUpgradeEvent(t *testing.T) {
+	tests := []struct {
+		version int
+		want    int
+		wantErr bool
+	}{
+		{version: 0, want: 1}, // schemaVersion導入前
+		{version: 1, want: 1},
+		{version: 2, wantErr: true},
+		{version: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.version), func(t *testing.T) {
+			ev := StatusChangedEvent{EventID: "e1", NewStatus: "DONE", SchemaVersion: tt.version}
+			err := upgradeEvent(&ev)
+			if tt.wantErr {
+				if !errors.Is(err, errUnknownSchemaVersion) {
+					t.Errorf("err = %v, want %v", err, errUnknownSchemaVersion)
+				}
+				return
+			}
+			if err != nil || ev.SchemaVersion != tt.want {
+				t.Errorf("upgradeEvent() = %v, version %d, want %d", err, ev.SchemaVersion, tt.want)
+			}
+		})
+	}
+}
+
+// バージョンごとに、反映するかDLQへ送るかを確かめる
+func TestProcessStatusChangedSchemaVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantDLQ bool
+	}{
+		{name: "no schemaVersion", body: `{"eventId":"e1","requestId":"r1","newStatus":"DONE","changedAt":"2024-05-01T09:00:00Z"}`},
+		{name: "current version", body: `{"eventId":"e1","requestId":"r1","newStatus":"DONE","changedAt":"2024-05-01T09:00:00Z","schemaVersion":1}`},
+		{name: "newer version", body: `{"eventId":"e1","requestId":"r1","newStatus":"DONE","changedAt":"2024-05-01T09:00:00Z","schemaVersion":2}`, wantDLQ: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runStatusChanged(t, tt.body)
+			sent, updates := fake.callsOf("SendMessage"), fake.callsOf("UpdateItem")
+			if !tt.wantDLQ {
+				if len(updates) == 0 || len(sent) != 0 {
+					t.Errorf("UpdateItem=%d SendMessage=%d, want the event applied", len(updates), len(sent))
+				}
+				return
+			}
+			if len(updates) != 0 {
+				t.Error("unknown version was applied")
+			}
+			if len(sent) != 1 || sent[0].Input["QueueUrl"] != "http://fake/dlq" || sent[0].Input["MessageBody"] != tt.body {
+				t.Fatalf("SendMessage calls = %v, want the original body sent to the dlq", sent)
+			}
+			if len(fake.callsOf("DeleteMessage")) != 1 {
+				t.Error("dead-lettered message was not deleted from the queue")
+			}
+		})
+	}
+}
+
+func TestEventExpired(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	sent := func(d time.Duration) sqstypes.Message {
+		return sqstypes.Message{Attributes: map[string]string{
+			string(sqstypes.MessageSystemAttributeNameSentTimestamp): strconv.FormatInt(now.Add(-d).UnixMilli(), 10),
+		}}
+	}
+	tests := []struct {
+		name     string
+		deadline string
+		maxAge   string
+		m        sqstypes.Message
+		want     bool
+	}{
+		{name: "deadline passed", deadline: "2024-05-01T08:59:59Z", m: sent(0), want: true},
+		{name: "deadline now", deadline: "2024-05-01T09:00:00Z", m: sent(0), want: false},
+		{name: "deadline ahead", deadline: "2024-05-01T09:05:00Z", m: sent(time.Hour), want: false},
+		{name: "deadline wins over max age", deadline: "2024-05-01T09:05:00Z", maxAge: "1m", m: sent(time.Hour), want: false},
+		{name: "unparsable deadline", deadline: "soon", m: sent(time.Hour), want: false},
+		{name: "no deadline, no max age", m: sent(time.Hour), want: false},
+		{name: "older than max age", maxAge: "10m", m: sent(11 * time.Minute), want: true},
+		{name: "within max age", maxAge: "10m", m: sent(9 * time.Minute), want: false},
+		{name: "no sent timestamp", maxAge: "10m", m: sqstypes.Message{}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EVENT_MAX_AGE", tt.maxAge)
+			ev := StatusChangedEvent{EventID: "e1", Deadline: tt.deadline}
+			if got := eventExpired(ev, tt.m, now); got != tt.want {
+				t.Errorf("eventExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 期限切れのイベントは反映せずに消し、eventsExpired に数える
+func TestProcessStatusChangedDropsExpiredEvent(t *testing.T) {
+	t.Setenv("EVENT_MAX_AGE", "")
+	before := counterValue(eventsExpired)
+	fake := runStatusChanged(t, `{"eventId":"e1","requestId":"r1","newStatus":"DONE","changedAt":"2024-05-01T09:00:00Z","schemaVersion":1,"deadline":"2024-05-01T09:05:00Z"}`)
+	if n := len(fake.callsOf("UpdateItem")); n != 0 {
+		t.Errorf("expired event applied (%d UpdateItem calls)", n)
+	}
+	if n := len(fake.callsOf("DeleteMessage")); n != 1 {
+		t.Errorf("DeleteMessage calls = %d, want 1", n)
+	}
+	if got := counterValue(eventsExpired) - before; got != 1 {
+		t.Errorf("eventsExpired increased by %v, want 1", got)
+	}
+}
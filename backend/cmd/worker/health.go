@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultHealthWindow = 60 * time.Second
+
+// 受信ループから更新される稼働状況（複数ループから触るのでatomic）
+type workerStats struct {
+	lastReceiveAt   atomic.Int64 // UnixNano。ReceiveMessage成功時（空でも）
+	lastProcessedAt atomic.Int64 // UnixNano。メッセージ処理成功時
+	lastMessageAt   atomic.Int64 // UnixNano。1件以上受信したとき（idle shutdown用）
+	inFlight        atomic.Int64
+	lastHeartbeatAt atomic.Int64 // UnixNano。SYS#worker を書いた時刻
+}
+
+func (st *workerStats) markReceived()  { st.lastReceiveAt.Store(time.Now().UnixNano()) }
+func (st *workerStats) markProcessed() { st.lastProcessedAt.Store(time.Now().UnixNano()) }
+func (st *workerStats) markMessages()  { st.lastMessageAt.Store(time.Now().UnixNano()) }
+
+type workerHealth struct {
+	Healthy          bool   `json:"healthy"`
+	LastReceiveAt    string `json:"lastReceiveAt,omitempty"`
+	LastProcessedAt  string `json:"lastProcessedAt,omitempty"`
+	MessagesInFlight int64  `json:"messagesInFlight"`
+}
+
+func formatUnixNano(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return time.Unix(0, n).UTC().Format(time.RFC3339)
+}
+
+// GET /healthz
+// WORKER_HEALTH_WINDOW（既定60s）以内にReceiveMessageが成功していなければ503
+func (st *workerStats) handleHealth(w http.ResponseWriter, r *http.Request) {
+	window := envDuration("WORKER_HEALTH_WINDOW", defaultHealthWindow)
+	last := st.lastReceiveAt.Load()
+	h := workerHealth{
+		Healthy:          last != 0 && time.Since(time.Unix(0, last)) <= window,
+		LastReceiveAt:    formatUnixNano(last),
+		LastProcessedAt:  formatUnixNano(st.lastProcessedAt.Load()),
+		MessagesInFlight: st.inFlight.Load(),
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if !h.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(h)
+}
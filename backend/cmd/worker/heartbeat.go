@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	heartbeatPK              = "SYS#worker"
+	defaultHeartbeatInterval = 10 * time.Second
+)
+
+// APIの GET /status 用に、受信ループの稼働状況を Requests テーブルの PK=SYS#worker に書く。
+// 受信のたびに呼ばれるが、書くのは WORKER_HEARTBEAT_INTERVAL（既定10s）に1回（複数ループでも1回）。
+// 複数プロセスのworkerは同じitemを上書きする（最後に書いたものが見える）
+func (wk *worker) heartbeat(ctx context.Context) {
+	now := time.Now()
+	last := wk.stats.lastHeartbeatAt.Load()
+	if now.Sub(time.Unix(0, last)) < envDuration("WORKER_HEARTBEAT_INTERVAL", defaultHeartbeatInterval) ||
+		!wk.stats.lastHeartbeatAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	host, _ := os.Hostname()
+	item := map[string]types.AttributeValue{
+		"PK":               &types.AttributeValueMemberS{Value: heartbeatPK},
+		"updatedAt":        &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		"host":             &types.AttributeValueMemberS{Value: host},
+		"messagesInFlight": &types.AttributeValueMemberN{Value: strconv.FormatInt(wk.stats.inFlight.Load(), 10)},
+	}
+	if t := formatUnixNano(wk.stats.lastProcessedAt.Load()); t != "" {
+		item["lastProcessedAt"] = &types.AttributeValueMemberS{Value: t}
+	}
+	_, err := wk.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(requestsTable),
+		Item:      item,
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("heartbeat error: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// DynamoDBはitemサイズ超過をValidationExceptionで返す（専用の例外型はない）
+func isItemSizeError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "ValidationException" &&
+		strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "item size")
+}
+
+// statusHistoryの古い方から半分を削除する
+func trimStatusHistory(ctx context.Context, ddb *dynamodb.Client, requestID string) error {
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "REQ#" + requestID},
+	}
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(requestsTable),
+		Key:                  key,
+		ProjectionExpression: aws.String("statusHistory"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	l, ok := out.Item["statusHistory"].(*types.AttributeValueMemberL)
+	if !ok || len(l.Value) == 0 {
+		return nil
+	}
+
+	n := len(l.Value)
+	drop := max(1, n/2)
+	removes := make([]string, 0, drop)
+	for i := 0; i < drop; i++ {
+		removes = append(removes, fmt.Sprintf("statusHistory[%d]", i))
+	}
+
+	_, err = ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(requestsTable),
+		Key:              key,
+		UpdateExpression: aws.String("REMOVE " + strings.Join(removes, ", ")),
+		// 読んでから書くまでに他のworkerが追記していたら失敗させて再試行に回す
+		ConditionExpression: aws.String("size(statusHistory) = :n"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":n": &types.AttributeValueMemberN{Value: strconv.Itoa(n)},
+		},
+	})
+	return err
+}
+
+const defaultHistoryRetention = 50
+
+// HISTORY_RETENTION（既定50、0で無制限）件を超えた古い履歴を削る。attrsは追記後のitem（statusHistoryを含む）。
+// HISTORY_RETENTION_ARCHIVE=true なら削る前に RequestEvents（HISTORY_STORAGE=items と同じ形）へ書き写す。
+// 読んでから削るまでに他のworkerが追記していたら何もしない（次の追記で削られる）
+func enforceHistoryRetention(ctx context.Context, ddb *dynamodb.Client, requestID string, attrs map[string]types.AttributeValue) error {
+	keep := envIntAllowZero("HISTORY_RETENTION", defaultHistoryRetention)
+	l, ok := attrs["statusHistory"].(*types.AttributeValueMemberL)
+	if keep == 0 || !ok || len(l.Value) <= keep {
+		return nil
+	}
+
+	n := len(l.Value)
+	drop := n - keep
+	if envBool("HISTORY_RETENTION_ARCHIVE") {
+		for _, v := range l.Value[:drop] {
+			m, ok := v.(*types.AttributeValueMemberM)
+			if !ok {
+				continue
+			}
+			if err := putEventItem(ctx, ddb, historyEntryEvent(requestID, m.Value), stringAttr(m.Value, "handledAt")); err != nil {
+				return err
+			}
+		}
+	}
+
+	removes := make([]string, 0, drop)
+	for i := 0; i < drop; i++ {
+		removes = append(removes, fmt.Sprintf("statusHistory[%d]", i))
+	}
+	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + requestID},
+		},
+		UpdateExpression:    aws.String("REMOVE " + strings.Join(removes, ", ")),
+		ConditionExpression: aws.String("size(statusHistory) = :n"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":n": &types.AttributeValueMemberN{Value: strconv.Itoa(n)},
+		},
+	})
+	var cfe *types.ConditionalCheckFailedException
+	if errors.As(err, &cfe) {
+		return nil
+	}
+	return err
+}
+
+func historyEntryEvent(requestID string, m map[string]types.AttributeValue) StatusChangedEvent {
+	return StatusChangedEvent{
+		EventID:   stringAttr(m, "eventId"),
+		RequestID: requestID,
+		NewStatus: stringAttr(m, "newStatus"),
+		ChangedAt: stringAttr(m, "changedAt"),
+		ChangedBy: stringAttr(m, "changedBy"),
+		Reason:    stringAttr(m, "reason"),
+	}
+}
+
+func stringAttr(item map[string]types.AttributeValue, key string) string {
+	if v, ok := item[key].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
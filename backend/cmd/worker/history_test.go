@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 追記のたびに HISTORY_RETENTION を超えた古い履歴が削られ、リストが上限を超えないこと
+func TestHistoryRetentionBoundsStatusHistory(t *testing.T) {
+	tests := []struct {
+		name        string
+		retention   string
+		archive     bool
+		events      int
+		wantHistory []string
+		wantArchive int
+	}{
+		{name: "under the limit", retention: "3", events: 2, wantHistory: []string{"e0", "e1"}},
+		{name: "trims the oldest", retention: "3", events: 7, wantHistory: []string{"e4", "e5", "e6"}},
+		{name: "archives trimmed entries", retention: "2", archive: true, events: 5, wantHistory: []string{"e3", "e4"}, wantArchive: 3},
+		{name: "zero keeps everything", retention: "0", events: 4, wantHistory: []string{"e0", "e1", "e2", "e3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HISTORY_STORAGE", "")
+			t.Setenv("HISTORY_RETENTION", tt.retention)
+			t.Setenv("HISTORY_RETENTION_ARCHIVE", fmt.Sprint(tt.archive))
+			t.Setenv("PROCESSED_EVENT_IDS_MAX", "")
+			item := &fakeRequestItem{exists: true}
+			fake := &fakeAWS{t: t, handle: item.handle}
+			ddb := fake.dynamoClient()
+			limit := envIntAllowZero("HISTORY_RETENTION", defaultHistoryRetention)
+
+			for i := range tt.events {
+				ev := StatusChangedEvent{EventID: fmt.Sprintf("e%d", i), RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: fmt.Sprintf("2024-05-01T09:%02d:00Z", i)}
+				if err := applyStatusEvent(context.Background(), ddb, ev); err != nil {
+					t.Fatalf("apply %s: %v", ev.EventID, err)
+				}
+				if limit > 0 && len(item.history) > limit {
+					t.Fatalf("after %s: %d history entries, limit %d", ev.EventID, len(item.history), limit)
+				}
+			}
+			if got := item.historyEventIDs(); !reflect.DeepEqual(got, tt.wantHistory) {
+				t.Errorf("statusHistory = %v, want %v", got, tt.wantHistory)
+			}
+			if got := len(fake.callsOf("PutItem")); got != tt.wantArchive {
+				t.Errorf("archived %d entries, want %d", got, tt.wantArchive)
+			}
+		})
+	}
+}
+
+// 読んでから削るまでに追記されていたら（size条件の失敗）何もせずに次の追記に任せる
+func TestEnforceHistoryRetentionConcurrentAppend(t *testing.T) {
+	t.Setenv("HISTORY_RETENTION", "1")
+	t.Setenv("HISTORY_RETENTION_ARCHIVE", "")
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		return nil, awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException"}
+	}}
+	entry := func(id string) types.AttributeValue {
+		return &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"eventId": &types.AttributeValueMemberS{Value: id}}}
+	}
+	attrs := map[string]types.AttributeValue{"statusHistory": &types.AttributeValueMemberL{Value: []types.AttributeValue{entry("e1"), entry("e2"), entry("e3")}}}
+	if err := enforceHistoryRetention(context.Background(), fake.dynamoClient(), "r1", attrs); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	calls := fake.callsOf("UpdateItem")
+	if len(calls) != 1 || calls[0].Input["UpdateExpression"] != "REMOVE statusHistory[0], statusHistory[1]" {
+		t.Errorf("UpdateItem calls = %v", calls)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WORKER_IDLE_SHUTDOWN の間メッセージを1件も受信せず、処理中もなければ true を返す（scale-to-zero用）。
+// 受信ループが複数あっても最後にメッセージを受け取った時刻は共有
+func (wk *worker) waitIdle(ctx context.Context, idle time.Duration) bool {
+	wk.stats.markMessages() // 起動時点から数える
+	tick := time.NewTicker(max(idle/10, 100*time.Millisecond))
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-tick.C:
+		}
+		since := time.Since(time.Unix(0, wk.stats.lastMessageAt.Load()))
+		if since >= idle && wk.stats.inFlight.Load() == 0 {
+			log.Printf("idle for %s, shutting down", since.Round(time.Second))
+			return true
+		}
+	}
+}
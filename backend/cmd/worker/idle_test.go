@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// フェイクSQSの受信結果でidle shutdownを駆動する。空の受信が続いたときだけ止まる
+func TestWaitIdleDrivenByReceives(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages bool  // 受信のたびに1件返す
+		inFlight int64 // 処理中のメッセージ数
+		wantIdle bool
+	}{
+		{name: "empty receives", wantIdle: true},
+		{name: "messages keep arriving", messages: true},
+		{name: "message still in flight", inFlight: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seq atomic.Int64
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+				if op != "ReceiveMessage" {
+					return nil, nil
+				}
+				time.Sleep(5 * time.Millisecond) // long pollの代わり
+				if !tt.messages {
+					return map[string]any{"Messages": []any{}}, nil
+				}
+				// Bodyなしのメッセージは処理せずに捨てられる
+				return map[string]any{"Messages": []any{map[string]any{"MessageId": fmt.Sprintf("m%d", seq.Add(1))}}}, nil
+			}}
+			wk := &worker{
+				ddb:   fake.dynamoClient(),
+				sqs:   fake.sqsClient(),
+				stats: &workerStats{},
+				recv:  receiveConfig{maxMessages: 1, visibilityTimeout: 30},
+				slots: newSemaphore(1),
+			}
+			wk.stats.inFlight.Store(tt.inFlight)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			runCtx, stopRun := context.WithCancel(ctx)
+			loopDone := make(chan error, 1)
+			go func() { loopDone <- wk.runLoop(runCtx, "http://fake/queue", 0) }()
+
+			start := time.Now()
+			idle := wk.waitIdle(ctx, 300*time.Millisecond)
+			stopRun()
+			if err := <-loopDone; err != nil {
+				t.Fatalf("runLoop: %v", err)
+			}
+			if idle != tt.wantIdle {
+				t.Fatalf("idle = %v, want %v", idle, tt.wantIdle)
+			}
+			if idle && time.Since(start) < 300*time.Millisecond {
+				t.Errorf("stopped after %v, before the idle period", time.Since(start))
+			}
+			if len(fake.callsOf("ReceiveMessage")) == 0 {
+				t.Error("no ReceiveMessage calls")
+			}
+		})
+	}
+}
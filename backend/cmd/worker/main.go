@@ -4,17 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -22,38 +34,62 @@ const (
 	queueName     = "request-events"
 )
 
+// 適用済みのイベントより古い（順序が入れ替わって届いた）イベント
+var errStaleEvent = errors.New("stale event")
+
+// 同じeventIdが処理済み（冪等性の条件で弾かれた）。成功扱いだがメトリクスでは適用と分ける
+var errDuplicateEvent = errors.New("duplicate event")
+
+// 対象のrequestがない（削除済みなど）。適用も通知もせずに消すが、メトリクスでは適用と分ける
+var errRequestMissing = errors.New("request not found")
+
+// API側の allowedTransitions と同じステータス
+var knownStatuses = map[string]bool{
+	"TRIAGE": true, "PENDING": true, "IN_PROGRESS": true, "DONE": true, "REJECTED": true, "CANCELLED": true,
+}
+
+// API側の normalizeStatus と同じ（大文字小文字・前後の空白を無視）
+func normalizeStatus(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
 type StatusChangedEvent struct {
-	EventID   string `json:"eventId"`
-	RequestID string `json:"requestId"`
-	NewStatus string `json:"newStatus"`
-	ChangedAt string `json:"changedAt"`
+	EventID       string `json:"eventId"`
+	RequestID     string `json:"requestId"`
+	NewStatus     string `json:"newStatus"`
+	ChangedAt     string `json:"changedAt"`
+	ChangedBy     string `json:"changedBy,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+	Deadline      string `json:"deadline,omitempty"`
+	Replay        bool   `json:"replay,omitempty"` // 通知だけ（履歴・itemは更新しない）
 }
 
+// DYNAMODB_ENDPOINT が空なら実AWSのエンドポイントを使う
 func newDynamoClient(ctx context.Context) (*dynamodb.Client, error) {
 	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
-	if endpoint == "" {
-		return nil, fmt.Errorf("DYNAMODB_ENDPOINT is required")
-	}
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	cfg, err := loadAWSConfig(ctx, endpoint)
 	if err != nil {
 		return nil, err
 	}
 	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
 	}), nil
 }
 
+// SQS_ENDPOINT が空なら実AWSのエンドポイントを使う
 func newSQSClient(ctx context.Context) (*sqs.Client, error) {
 	endpoint := os.Getenv("SQS_ENDPOINT")
-	if endpoint == "" {
-		return nil, fmt.Errorf("SQS_ENDPOINT is required")
-	}
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	cfg, err := loadAWSConfig(ctx, endpoint)
 	if err != nil {
 		return nil, err
 	}
 	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
 	}), nil
 }
 
@@ -70,82 +106,518 @@ func resolveQueueURL(ctx context.Context, c *sqs.Client) (string, error) {
 	return aws.ToString(out.QueueUrl), nil
 }
 
+const (
+	maxReceiveMessages = 10 // SQSの1回の受信上限
+	defaultConcurrency = 10
+)
+
+type worker struct {
+	ddb     *dynamodb.Client
+	sqs     *sqs.Client
+	webhook *webhookSender // nil なら通知なし
+	archive *eventArchiver // nil ならアーカイブなし
+	email   *emailNotifier // nil ならメールなし
+	stats   *workerStats
+	dlqURL  string // 空ならDLQへ送らない
+
+	recv             receiveConfig
+	slots            *semaphore // 全ループ合計の処理中メッセージ数の上限
+	drain            bool
+	drainWaitSeconds int
+}
+
 func main() {
 	if os.Getenv("APP_ENV") != "production" {
 		_ = godotenv.Load(".env")
 	}
-	ctx := context.Background()
+	once := flag.Bool("once", false, "receive one batch, process it and exit (non-zero if any message failed)")
+	selftest := flag.Bool("selftest", false, "send a test event for a test request, process it and verify the history (LocalStack only)")
+	peek := flag.Bool("peek", false, "print queued messages with their receive count without deleting them, then exit")
+	peekRequestID := flag.String("peek-request-id", "", "with -peek: only print messages for this requestId")
+	peekMax := flag.Int("peek-max", 100, "with -peek: stop after this many distinct messages")
+	flag.Parse()
+	recv, err := loadReceiveConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if os.Getenv("WORKER_MODE") == "once" {
+		*once = true
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	ddb, err := newDynamoClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := checkTableWithRetry(ctx, ddb); err != nil {
+		log.Fatal(err)
+	}
 	sqsc, err := newSQSClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	queueURL, err := resolveQueueURL(ctx, sqsc)
+	queueURLs, err := resolveQueueURLs(ctx, sqsc)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Printf("worker started. queue=%s", queueURL)
+	// 読み取り専用なので、webhookやアーカイブなど処理側の準備はしない
+	if *peek {
+		for _, queueURL := range queueURLs {
+			if err := peekQueue(ctx, sqsc, os.Stdout, queueURL, *peekRequestID, *peekMax); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
+	shutdownTracing, err := initTracing(ctx, "worker")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	webhook, err := newWebhookSenderFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	archive, err := newEventArchiverFromEnv(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	email, err := newEmailNotifierFromEnv(ctx, ddb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	wk := &worker{
+		ddb:              ddb,
+		sqs:              sqsc,
+		webhook:          webhook,
+		archive:          archive,
+		email:            email,
+		stats:            &workerStats{},
+		dlqURL:           resolveDLQURL(ctx, sqsc),
+		recv:             recv,
+		slots:            newSemaphore(envInt("WORKER_CONCURRENCY", defaultConcurrency)),
+		drain:            envBool("WORKER_DRAIN"),
+		drainWaitSeconds: envIntAllowZero("WORKER_DRAIN_WAIT_SECONDS", 1),
+	}
+	if wk.webhook != nil {
+		for _, t := range wk.webhook.targets() {
+			registerBreakerMetric("webhook:"+t.name, t.breaker)
+		}
+	}
+
+	if *selftest {
+		if err := wk.runSelftest(ctx, queueURLs[0]); err != nil {
+			log.Printf("selftest failed: %v", err)
+			_ = shutdownTracing(context.Background())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *once {
+		failed := 0
+		for _, queueURL := range queueURLs {
+			failed += wk.runOnce(ctx, queueURL)
+		}
+		if failed > 0 {
+			log.Printf("once: %d message(s) failed", failed)
+			_ = shutdownTracing(context.Background())
+			os.Exit(1)
+		}
+		return
+	}
+
+	startMetricsServer(wk.stats)
+
+	// キューごとに受信ループを WORKER_RECEIVERS 本ずつ。どれかが異常終了したら全体を止める。
+	// 処理スロット（WORKER_CONCURRENCY）と統計は全ループで共有
+	receivers := envInt("WORKER_RECEIVERS", 1)
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+	var idleStopped atomic.Bool
+	if idle := envDuration("WORKER_IDLE_SHUTDOWN", 0); idle > 0 {
+		go func() {
+			if wk.waitIdle(runCtx, idle) {
+				idleStopped.Store(true)
+				stopRun()
+			}
+		}()
+	}
+	g, gctx := errgroup.WithContext(runCtx)
+	for _, queueURL := range queueURLs {
+		for i := range receivers {
+			g.Go(func() error {
+				return wk.runLoop(gctx, queueURL, i)
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		log.Fatal(err)
+	}
+	if idleStopped.Load() {
+		log.Printf("worker stopped: idle shutdown (no messages for WORKER_IDLE_SHUTDOWN)")
+		return
+	}
+	log.Printf("worker stopped")
+}
+
+// SQS_QUEUE_URLS（カンマ区切り）があればそれを全部使う。なければ従来通り1本
+func resolveQueueURLs(ctx context.Context, c *sqs.Client) ([]string, error) {
+	var urls []string
+	for _, u := range strings.Split(os.Getenv("SQS_QUEUE_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) > 0 {
+		return urls, nil
+	}
+	u, err := resolveQueueURLWithRetry(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return []string{u}, nil
+}
 
+// -once 用。1バッチだけ受信して順番に処理し、失敗したメッセージ数を返す
+func (wk *worker) runOnce(ctx context.Context, queueURL string) int {
+	resp, err := wk.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(queueURL),
+		MaxNumberOfMessages:   wk.recv.maxMessages,
+		WaitTimeSeconds:       wk.recv.waitSeconds,
+		VisibilityTimeout:     wk.recv.visibilityTimeout,
+		MessageAttributeNames: []string{"All"},
+		MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{
+			sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+			sqstypes.MessageSystemAttributeNameSentTimestamp,
+		},
+	})
+	if err != nil {
+		log.Printf("receive error: %v queue=%s", err, queueURL)
+		return 1
+	}
+	wk.stats.markReceived()
+	log.Printf("once: received %d message(s) queue=%s", len(resp.Messages), queueURL)
+
+	msgs := dedupBatch(resp.Messages, func(m sqstypes.Message) {
+		if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+			log.Printf("delete error: %v", err)
+		}
+	})
+	failed := 0
+	for _, m := range msgs {
+		if !wk.handleMessage(ctx, queueURL, m) {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (wk *worker) runLoop(ctx context.Context, queueURL string, receiver int) error {
+	log.Printf("worker started. queue=%s receiver=%d", queueURL, receiver)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	draining := false
 	for {
-		resp, err := sqsc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-			QueueUrl:            aws.String(queueURL),
-			MaxNumberOfMessages: 10,
-			WaitTimeSeconds:     10, // long polling
-			VisibilityTimeout:   30,
+		// 空きスロット分だけ受信する（処理待ちでvisibility timeoutを過ぎないように）
+		slots, err := wk.slots.acquire(ctx, int(wk.recv.maxMessages))
+		if err != nil {
+			return nil
+		}
+
+		// 滞留時は満杯バッチが続く限り短いwaitで連続受信し、空振りしたらlong pollingに戻る
+		wait := wk.recv.waitSeconds
+		if draining {
+			wait = int32(wk.drainWaitSeconds)
+		}
+		resp, err := wk.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(queueURL),
+			MaxNumberOfMessages:   int32(slots),
+			WaitTimeSeconds:       wait,
+			VisibilityTimeout:     wk.recv.visibilityTimeout,
+			MessageAttributeNames: []string{"All"},
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{
+				sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+				sqstypes.MessageSystemAttributeNameSentTimestamp,
+			},
 		})
+		if ctx.Err() != nil {
+			wk.slots.release(slots)
+			return nil
+		}
 		if err != nil {
-			log.Printf("receive error: %v", err)
-			time.Sleep(1 * time.Second)
+			wk.slots.release(slots)
+			log.Printf("receive error: %v queue=%s receiver=%d", err, queueURL, receiver)
+			// 待っている間にシャットダウンされたらすぐ抜ける
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
 			continue
 		}
-		if len(resp.Messages) == 0 {
-			continue
+		wk.stats.markReceived()
+		wk.heartbeat(ctx)
+		if len(resp.Messages) > 0 {
+			wk.stats.markMessages()
 		}
+		draining = wk.drain && len(resp.Messages) == slots
 
-		for _, m := range resp.Messages {
-			if m.Body == nil || m.ReceiptHandle == nil {
-				continue
+		msgs := dedupBatch(resp.Messages, func(m sqstypes.Message) {
+			if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+				log.Printf("delete error: %v", err)
 			}
+		})
+		wk.slots.release(slots - len(msgs))
 
-			var ev StatusChangedEvent
-			if err := json.Unmarshal([]byte(*m.Body), &ev); err != nil {
-				log.Printf("bad message json: %v body=%q", err, *m.Body)
-				// 破損メッセージは消す（Labなので割り切り）
-				_, _ = sqsc.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-					QueueUrl:      aws.String(queueURL),
-					ReceiptHandle: m.ReceiptHandle,
-				})
-				continue
-			}
+		for _, m := range msgs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer wk.slots.release(1)
+				_ = wk.handleMessage(ctx, queueURL, m)
+			}()
+		}
+	}
+}
 
-			// DynamoDBに「通知処理済み」っぽい記録を追記
-			if err := applyStatusEvent(ctx, ddb, ev); err != nil {
-				log.Printf("apply error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
-				// 失敗時は消さない → visibility timeout後に再試行される
-				continue
-			}
+// 処理に失敗したら false（メッセージは再配信待ち、または破損で削除済み）。
+// API側のtraceを引き継いで1メッセージ1span
+func (wk *worker) handleMessage(ctx context.Context, queueURL string, m sqstypes.Message) bool {
+	if m.Body == nil || m.ReceiptHandle == nil {
+		return true
+	}
+	ctx, span := otel.Tracer(tracerName).Start(extractTraceContext(ctx, m), "process event",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	)
+	defer span.End()
 
-			// 成功したらキューから削除（再処理防止）
-			_, err = sqsc.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-				QueueUrl:      aws.String(queueURL),
-				ReceiptHandle: m.ReceiptHandle,
-			})
-			if err != nil {
-				log.Printf("delete error: %v", err)
-				continue
-			}
+	ok := wk.processMessage(ctx, queueURL, m)
+	if !ok {
+		span.SetStatus(codes.Error, "message not processed")
+	}
+	return ok
+}
+
+func (wk *worker) processMessage(ctx context.Context, queueURL string, m sqstypes.Message) bool {
+	wk.stats.inFlight.Add(1)
+	defer wk.stats.inFlight.Add(-1)
+	defer wk.extendVisibility(ctx, queueURL, m)()
+
+	// eventType属性のないメッセージは従来のStatusChangedEvent
+	switch t := messageEventType(m); t {
+	case eventTypeStatusChanged:
+		return wk.processStatusChanged(ctx, queueURL, m)
+	case eventTypeRequestCreated:
+		return wk.processRequestCreated(ctx, queueURL, m)
+	default:
+		log.Printf("unknown event type %q messageId=%s, moving to dlq", t, aws.ToString(m.MessageId))
+		wk.deadLetter(ctx, queueURL, m)
+		return false
+	}
+}
+
+func (wk *worker) processStatusChanged(ctx context.Context, queueURL string, m sqstypes.Message) bool {
+	var ev StatusChangedEvent
+	if err := json.Unmarshal([]byte(*m.Body), &ev); err != nil {
+		log.Printf("bad message json: %v body=%q", err, *m.Body)
+		// 破損メッセージは消す（Labなので割り切り）
+		_ = wk.deleteMessage(ctx, queueURL, m)
+		return false
+	}
+	if err := upgradeEvent(&ev); err != nil {
+		// 新しいAPIが出したイベントなど。このworkerでは処理できないのでDLQへ
+		log.Printf("%v eventId=%s requestId=%s, moving to dlq", err, ev.EventID, ev.RequestID)
+		wk.deadLetter(ctx, queueURL, m)
+		return false
+	}
+	ev.NewStatus = normalizeStatus(ev.NewStatus)
+	if !knownStatuses[ev.NewStatus] {
+		log.Printf("unknown status in event: %q eventId=%s requestId=%s", ev.NewStatus, ev.EventID, ev.RequestID)
+		// 再配信しても直らないので破損メッセージと同じ扱い
+		_ = wk.deleteMessage(ctx, queueURL, m)
+		return false
+	}
+	return wk.applyEvent(ctx, queueURL, m, ev, true)
+}
+
+// 履歴への反映 → アーカイブ → 通知（notify=trueのとき） → 削除。失敗したら消さずに再配信に任せる
+func (wk *worker) applyEvent(ctx context.Context, queueURL string, m sqstypes.Message, ev StatusChangedEvent, notify bool) bool {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("eventId", ev.EventID),
+		attribute.String("requestId", ev.RequestID),
+		attribute.String("newStatus", ev.NewStatus),
+	)
+
+	if eventExpired(ev, m, time.Now()) {
+		eventsExpired.Inc()
+		log.Printf("expired event skipped eventId=%s requestId=%s changedAt=%s deadline=%s", ev.EventID, ev.RequestID, ev.ChangedAt, ev.Deadline)
+		if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+			log.Printf("delete error: %v", err)
+			return false
+		}
+		return true
+	}
+
+	// DynamoDBに「通知処理済み」っぽい記録を追記。replayは再通知だけなので何も書かない
+	var err error
+	if !ev.Replay {
+		err = applyStatusEvent(ctx, wk.ddb, ev)
+	}
+	duplicate := errors.Is(err, errDuplicateEvent)
+	if duplicate {
+		err = nil
+	}
+	if errors.Is(err, errStaleEvent) {
+		// 新しい状態が適用済みなので、古い通知は送らずに消す
+		if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+			log.Printf("delete error: %v", err)
+			return false
+		}
+		return true
+	}
+	if errors.Is(err, errRequestMissing) {
+		log.Printf("event for missing request skipped eventId=%s requestId=%s", ev.EventID, ev.RequestID)
+		if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+			log.Printf("delete error: %v", err)
+			return false
+		}
+		eventsMissingRequest.Inc()
+		return true
+	}
+	if err != nil {
+		log.Printf("apply error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
+		// 失敗時は消さない → visibility timeout後に再試行される
+		return false
+	}
+
+	// S3へ生イベントを保存。失敗したら消さずに再配信に任せる（webhookより先にやって二重通知を避ける）。
+	// PutObjectはメッセージ処理のgoroutineごとに並列に走るので、まとめ書きはしない
+	if wk.archive != nil {
+		if err := wk.archive.Archive(ctx, ev, *m.Body); err != nil {
+			log.Printf("archive error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
+			return false
+		}
+	}
+
+	// webhook通知。失敗（circuit open含む）なら消さずに再配信に任せる。
+	// 履歴の追記は冪等なので再配信時はスキップされ、通知だけやり直される
+	if notify && wk.webhook != nil {
+		if err := wk.webhook.Deliver(ctx, ev, receiveCount(m)); err != nil {
+			log.Printf("webhook error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
+			return false
+		}
+	}
+	// メールも同じく失敗したら再配信に任せる（webhookも送り直しになるが Idempotency-Key で受け側が弾ける）
+	if notify && wk.email != nil {
+		if err := wk.email.Notify(ctx, ev); err != nil {
+			log.Printf("email error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
+			return false
+		}
+	}
+
+	// 成功したらキューから削除（再処理防止）
+	if err := wk.deleteMessage(ctx, queueURL, m); err != nil {
+		log.Printf("delete error: %v", err)
+		return false
+	}
+
+	wk.stats.markProcessed()
+	switch {
+	case ev.Replay:
+		eventsReplayed.Inc()
+	case duplicate:
+		eventsDuplicate.Inc()
+	default:
+		eventsApplied.WithLabelValues(ev.NewStatus).Inc()
+	}
+	log.Printf("processed eventId=%s requestId=%s newStatus=%s", ev.EventID, ev.RequestID, ev.NewStatus)
+	return true
+}
 
-			log.Printf("processed eventId=%s requestId=%s newStatus=%s", ev.EventID, ev.RequestID, ev.NewStatus)
+// 処理済みメッセージの削除。失敗すると再配信→再処理になるので、バックオフ付きで数回やり直す
+func (wk *worker) deleteMessage(ctx context.Context, queueURL string, m sqstypes.Message) error {
+	attempts := envInt("DELETE_RETRIES", 3)
+	backoff := envDuration("DELETE_RETRY_BACKOFF", 200*time.Millisecond)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		_, err = wk.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(queueURL),
+			ReceiptHandle: m.ReceiptHandle,
+		})
+		if err == nil {
+			return nil
 		}
+		if attempt >= attempts {
+			break
+		}
+		deleteRetries.Inc()
+		select {
+		case <-ctx.Done():
+			deleteFailures.Inc()
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
+	deleteFailures.Inc()
+	return fmt.Errorf("delete failed after %d attempts: %w", attempts, err)
 }
 
 func applyStatusEvent(ctx context.Context, ddb *dynamodb.Client, ev StatusChangedEvent) error {
+	if historyItemsMode() {
+		// 履歴itemを先に書く（requestのitem更新後に落ちても、再配信で書き直せるように）
+		if err := putEventItem(ctx, ddb, ev, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	err := appendStatusHistory(ctx, ddb, ev)
+	if !isItemSizeError(err) {
+		return err
+	}
+
+	// 400KB上限に達した → 古い履歴を削ってから1回だけ再試行
+	log.Printf("item size limit reached, trimming history requestId=%s", ev.RequestID)
+	if err := trimStatusHistory(ctx, ddb, ev.RequestID); err != nil {
+		return err
+	}
+	err = appendStatusHistory(ctx, ddb, ev)
+	if isItemSizeError(err) {
+		// 削っても入らない場合は諦めて削除させる（同じメッセージで無限ループしないように）
+		log.Printf("dropping event: item still too large after trim eventId=%s requestId=%s", ev.EventID, ev.RequestID)
+		return nil
+	}
+	return err
+}
+
+// 条件失敗時のitem（ALL_OLD）から、どの条件で弾かれたかを返す。
+// 処理済みかを古さより先に見る: API_WRITES_HISTORY ではAPIが変更のたびに lastAppliedChangedAt を進めるので、
+// 続けて変更されると前のイベントも「古い」に見える。処理済み（APIが記録済み）なら通知は必要なので重複扱いにする
+func conditionFailureReason(item map[string]types.AttributeValue, ev StatusChangedEvent) error {
+	if len(item) == 0 {
+		return errRequestMissing
+	}
+	if ids, ok := item["processedEventIds"].(*types.AttributeValueMemberSS); ok && slices.Contains(ids.Value, ev.EventID) {
+		return errDuplicateEvent
+	}
+	if stringAttr(item, "lastEventId") == ev.EventID {
+		return errDuplicateEvent
+	}
+	if last := stringAttr(item, "lastAppliedChangedAt"); last > ev.ChangedAt {
+		return errStaleEvent
+	}
+	return errDuplicateEvent
+}
+
+func appendStatusHistory(ctx context.Context, ddb *dynamodb.Client, ev StatusChangedEvent) error {
 	pk := "REQ#" + ev.RequestID
 	now := time.Now().UTC().Format(time.RFC3339)
 
@@ -157,33 +629,76 @@ func applyStatusEvent(ctx context.Context, ddb *dynamodb.Client, ev StatusChange
 			"handledAt": &types.AttributeValueMemberS{Value: now},
 		},
 	}
+	if ev.ChangedBy != "" {
+		historyEntry.Value["changedBy"] = &types.AttributeValueMemberS{Value: ev.ChangedBy}
+	}
+	if ev.Reason != "" {
+		historyEntry.Value["reason"] = &types.AttributeValueMemberS{Value: ev.Reason}
+	}
 
-	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+	// statusHistory に1件append + notifiedAt更新 + lastEventId保存 + 処理済みeventIdを集合に追加。
+	// items モードでは履歴は RequestEvents に書き済みなので append しない
+	// processedEventOrder は集合の記録順（トリムで古いものから削るため）
+	set := "SET notifiedAt = :n, lastEventId = :eid, lastAppliedChangedAt = :ca, " +
+		"processedEventOrder = list_append(if_not_exists(processedEventOrder, :empty), :eidl)"
+	values := map[string]types.AttributeValue{
+		":n":     &types.AttributeValueMemberS{Value: now},
+		":ca":    &types.AttributeValueMemberS{Value: ev.ChangedAt},
+		":eid":   &types.AttributeValueMemberS{Value: ev.EventID},
+		":eids":  &types.AttributeValueMemberSS{Value: []string{ev.EventID}},
+		":eidl":  &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: ev.EventID}}},
+		":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+	}
+	if !historyItemsMode() {
+		set += ", statusHistory = list_append(if_not_exists(statusHistory, :empty), :h)"
+		values[":h"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{historyEntry}}
+	}
+
+	out, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(requestsTable),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{Value: pk},
 		},
-		// statusHistory に1件append + notifiedAt更新 + lastEventId保存
-		UpdateExpression: aws.String(
-			"SET notifiedAt = :n, lastEventId = :eid, " +
-				"statusHistory = list_append(if_not_exists(statusHistory, :empty), :h)",
-		),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":n":     &types.AttributeValueMemberS{Value: now},
-			":eid":   &types.AttributeValueMemberS{Value: ev.EventID},
-			":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
-			":h":     &types.AttributeValueMemberL{Value: []types.AttributeValue{historyEntry}},
-		},
-		// 1) requestが存在すること 2) 同じeventIdを二重処理しない（超簡易）
-		ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(lastEventId) OR lastEventId <> :eid)"),
+		UpdateExpression:          aws.String(set + " ADD processedEventIds :eids"),
+		ExpressionAttributeValues: values,
+		// 1) requestが存在すること 2) 同じeventIdを二重処理しない（到着順に関係なく集合で判定。
+		// lastEventIdの比較は集合導入前のitem向け）
+		// 3) 適用済みのイベントより古いchangedAtのイベントは適用しない（標準キューの順序入れ替わり対策。
+		// RFC3339(UTC)なので文字列比較で時刻順になる。同時刻はreplay等のため許可）
+		ConditionExpression: aws.String("attribute_exists(PK) AND " +
+			"(attribute_not_exists(lastEventId) OR lastEventId <> :eid) AND " +
+			"NOT contains(processedEventIds, :eid) AND " +
+			"(attribute_not_exists(lastAppliedChangedAt) OR lastAppliedChangedAt <= :ca)"),
+		ReturnValues:                        types.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	})
 	if err != nil {
 		var cfe *types.ConditionalCheckFailedException
 		if errors.As(err, &cfe) {
-			// 「存在しない」or「同じeventを再処理」→ Labでは成功扱いにして削除してOK
-			return nil
+			// 「存在しない」「同じeventを再処理」「古いevent」→ Labでは成功扱いにして削除してOK
+			reason := conditionFailureReason(cfe.Item, ev)
+			switch reason {
+			case errStaleEvent:
+				log.Printf("stale event skipped eventId=%s requestId=%s changedAt=%s lastApplied=%s",
+					ev.EventID, ev.RequestID, ev.ChangedAt, stringAttr(cfe.Item, "lastAppliedChangedAt"))
+			case errDuplicateEvent:
+				// API_WRITES_HISTORY では追記はAPI側なので、保持件数はここで揃える
+				if err := enforceHistoryRetention(ctx, ddb, ev.RequestID, cfe.Item); err != nil {
+					log.Printf("history retention error: %v requestId=%s", err, ev.RequestID)
+				}
+			}
+			return reason
 		}
 		return err
 	}
+
+	// 保持件数を超えた分は次回以降の追記でも削れるのでログだけ
+	if err := enforceHistoryRetention(ctx, ddb, ev.RequestID, out.Attributes); err != nil {
+		log.Printf("history retention error: %v requestId=%s", err, ev.RequestID)
+	}
+	if err := trimProcessedEventIDs(ctx, ddb, ev.RequestID, out.Attributes); err != nil {
+		// 集合の上限超過は次回以降のトリムで回収できるのでログだけ
+		log.Printf("trim processedEventIds error: %v requestId=%s", err, ev.RequestID)
+	}
 	return nil
 }
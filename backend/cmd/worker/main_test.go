@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestConditionFailureReasonReorderedEvents(t *testing.T) {
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+	ss := func(v ...string) types.AttributeValue { return &types.AttributeValueMemberSS{Value: v} }
+
+	// 1つ目(e1, 09:00) と 2つ目(e2, 09:01) の変更が続けて入り、e2 が先に届いて適用された状態
+	appliedE2 := map[string]types.AttributeValue{
+		"PK":                   s("REQ#r1"),
+		"lastEventId":          s("e2"),
+		"lastAppliedChangedAt": s("2024-05-01T09:01:00Z"),
+		"processedEventIds":    ss("e2"),
+	}
+	// API_WRITES_HISTORY: APIが e1, e2 の両方を記録済み（workerはまだどちらも通知していない）
+	apiRecordedBoth := map[string]types.AttributeValue{
+		"PK":                   s("REQ#r1"),
+		"lastEventId":          s("e2"),
+		"lastAppliedChangedAt": s("2024-05-01T09:01:00Z"),
+		"processedEventIds":    ss("e1", "e2"),
+	}
+	e1 := StatusChangedEvent{EventID: "e1", RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: "2024-05-01T09:00:00Z"}
+	e2 := StatusChangedEvent{EventID: "e2", RequestID: "r1", NewStatus: "DONE", ChangedAt: "2024-05-01T09:01:00Z"}
+
+	tests := []struct {
+		name string
+		item map[string]types.AttributeValue
+		ev   StatusChangedEvent
+		want error
+	}{
+		{name: "older event arriving after a newer one is stale", item: appliedE2, ev: e1, want: errStaleEvent},
+		{name: "redelivery of the applied event is a duplicate", item: appliedE2, ev: e2, want: errDuplicateEvent},
+		{name: "older event already recorded by the API is a duplicate, not stale", item: apiRecordedBoth, ev: e1, want: errDuplicateEvent},
+		{name: "newer event already recorded by the API is a duplicate", item: apiRecordedBoth, ev: e2, want: errDuplicateEvent},
+		{
+			name: "lastEventId matches on items without processedEventIds",
+			item: map[string]types.AttributeValue{"PK": s("REQ#r1"), "lastEventId": s("e1"), "lastAppliedChangedAt": s("2024-05-01T09:05:00Z")},
+			ev:   e1,
+			want: errDuplicateEvent,
+		},
+		{name: "missing request", item: nil, ev: e1, want: errRequestMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conditionFailureReason(tt.item, tt.ev); got != tt.want {
+				t.Errorf("conditionFailureReason() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// StatusChangedEvent のbodyを processStatusChanged に通す。UpdateItemは500で失敗させ、
+// 反映しようとした内容だけをfakeの呼び出し履歴で確かめる
+func runStatusChanged(t *testing.T, body string) *fakeAWS {
+	t.Helper()
+	t.Setenv("HISTORY_STORAGE", "")
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		if op == "UpdateItem" {
+			return nil, awsError{Status: http.StatusInternalServerError, Code: "InternalServerError"}
+		}
+		return nil, nil
+	}}
+	wk := &worker{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), stats: &workerStats{}, dlqURL: "http://fake/dlq"}
+	m := sqstypes.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String(body)}
+	wk.processStatusChanged(context.Background(), "http://fake/queue", m)
+	return fake
+}
+
+func TestProcessStatusChangedNormalizesStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string // 空なら不明なステータスとして反映せずに消す
+	}{
+		{status: "DONE", want: "DONE"},
+		{status: "done", want: "DONE"},
+		{status: "In_Progress", want: "IN_PROGRESS"},
+		{status: "  rejected\t", want: "REJECTED"},
+		{status: "finished"},
+		{status: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			body, _ := json.Marshal(StatusChangedEvent{EventID: "e1", RequestID: "r1", NewStatus: tt.status, ChangedAt: "2024-05-01T09:00:00Z"})
+			fake := runStatusChanged(t, string(body))
+
+			updates := fake.callsOf("UpdateItem")
+			if tt.want == "" {
+				if len(updates) != 0 {
+					t.Errorf("unknown status %q was applied", tt.status)
+				}
+				if len(fake.callsOf("DeleteMessage")) != 1 {
+					t.Errorf("unknown status %q should delete the message", tt.status)
+				}
+				return
+			}
+			if len(updates) == 0 {
+				t.Fatalf("status %q was not applied", tt.status)
+			}
+			vals, _ := json.Marshal(updates[0].Input["ExpressionAttributeValues"])
+			if !strings.Contains(string(vals), `"`+tt.want+`"`) {
+				t.Errorf("UpdateItem values %s, want status %s", vals, tt.want)
+			}
+		})
+	}
+}
+
+// replay（管理者の再通知）は通知と削除だけ行い、statusHistory やitemには何も書かない
+func TestReplayEventOnlyNotifies(t *testing.T) {
+	for _, replay := range []bool{false, true} {
+		t.Run(fmt.Sprintf("replay=%v", replay), func(t *testing.T) {
+			var mu sync.Mutex
+			var delivered []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				delivered = append(delivered, r.Header.Get("Idempotency-Key"))
+			}))
+			defer srv.Close()
+			t.Setenv("HISTORY_STORAGE", "")
+			t.Setenv("WEBHOOK_ROUTES", "")
+			t.Setenv("WEBHOOK_URL", srv.URL)
+			webhook, err := newWebhookSenderFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item := &fakeRequestItem{exists: true, lastEventID: "e1", lastApplied: "2024-05-01T09:00:00Z", processed: []string{"e1"}}
+			fake := &fakeAWS{t: t, handle: item.handle}
+			wk := &worker{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), stats: &workerStats{}, webhook: webhook}
+			body, _ := json.Marshal(StatusChangedEvent{EventID: "e2", RequestID: "r1", NewStatus: "DONE", ChangedAt: "2024-05-01T09:00:00Z", Replay: replay})
+			m := sqstypes.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String(string(body))}
+			if !wk.processStatusChanged(context.Background(), "http://fake/queue", m) {
+				t.Fatal("message not processed")
+			}
+
+			if want := []string{"e2"}; !reflect.DeepEqual(delivered, want) {
+				t.Errorf("webhook deliveries = %v, want %v", delivered, want)
+			}
+			if n := len(fake.callsOf("DeleteMessage")); n != 1 {
+				t.Errorf("DeleteMessage called %d times, want 1", n)
+			}
+			wantHistory := 1
+			if replay {
+				wantHistory = 0
+				if n := len(fake.callsOf("UpdateItem")) + len(fake.callsOf("PutItem")); n != 0 {
+					t.Errorf("replay wrote to DynamoDB %d times", n)
+				}
+			}
+			if len(item.history) != wantHistory {
+				t.Errorf("statusHistory = %v, want %d entries", item.historyEventIDs(), wantHistory)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultMetricsAddr = ":9091"
+
+var (
+	deleteRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_delete_retries_total",
+		Help: "DeleteMessage calls retried after a failure.",
+	})
+	deleteFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_delete_failures_total",
+		Help: "Messages whose DeleteMessage still failed after all retries (will be redelivered).",
+	})
+	eventsApplied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_events_applied_total",
+		Help: "Events applied to a request and deleted from the queue, by new status.",
+	}, []string{"newStatus"})
+	eventsDuplicate = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_events_duplicate_total",
+		Help: "Events skipped because the eventId was already processed (redelivery).",
+	})
+	eventsReplayed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_events_replayed_total",
+		Help: "Replay events (admin re-notification) delivered without touching history or the request item.",
+	})
+	eventsMissingRequest = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_events_missing_request_total",
+		Help: "Events deleted without being applied because the request item does not exist.",
+	})
+	eventsExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_events_expired_total",
+		Help: "Events deleted without being applied because they were past their deadline / EVENT_MAX_AGE.",
+	})
+	visibilityExtensions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_visibility_extensions_total",
+		Help: "ChangeMessageVisibility calls that extended a slow in-flight message.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(deleteRetries, deleteFailures, eventsApplied, eventsDuplicate, eventsReplayed, eventsMissingRequest, eventsExpired, visibilityExtensions)
+}
+
+// 0=closed, 1=half-open, 2=open
+func registerBreakerMetric(name string, b *circuitBreaker) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "worker_circuit_breaker_state",
+		Help:        "Circuit breaker state (0=closed, 1=half-open, 2=open).",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(b.State())
+	}))
+}
+
+// WORKER_METRICS_ADDR（既定 :9091）で /metrics と /healthz を公開する。"off" で無効
+func startMetricsServer(stats *workerStats) {
+	addr := os.Getenv("WORKER_METRICS_ADDR")
+	if addr == "" {
+		addr = defaultMetricsAddr
+	}
+	if addr == "off" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", stats.handleHealth)
+	if envBool("ENABLE_PPROF") {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	go func() {
+		log.Printf("metrics listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("metrics server error: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// 調査用の読み取り専用モード（-peek）。workerを通さずSQSクライアントだけを受け取り、DeleteMessageを呼ぶ経路を持たない。
+// ReceiveMessageの VisibilityTimeout=0 はSDKが送らない（ゼロ値は省略される）のでキューの既定で隠れてしまう。
+// バッチごとに ChangeMessageVisibilityBatch で0に戻して、通常のworkerからすぐ見えるようにする。
+// ただし受信はしているので ApproximateReceiveCount は1増える（maxReceiveCount に近いメッセージを何度も覗くとDLQへ移りうる）
+type peekedMessage struct {
+	MessageID    string          `json:"messageId"`
+	ReceiveCount string          `json:"approximateReceiveCount"`
+	SentAt       string          `json:"sentTimestamp,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// 新しいメッセージが来ない受信がこの回数続いたら一巡したとみなす。
+// 見えるように戻したメッセージはすぐまた返ってくるので、1回では打ち切らない
+const peekIdleRounds = 3
+
+// outに1メッセージ1行のJSONで出す。requestIDが空でなければbodyのrequestIdが一致するものだけ出す。maxMessages件見たら終わり
+func peekQueue(ctx context.Context, c *sqs.Client, out io.Writer, queueURL, requestID string, maxMessages int) error {
+	seen := map[string]bool{}
+	enc := json.NewEncoder(out)
+	for idle := 0; len(seen) < maxMessages && idle < peekIdleRounds; {
+		resp, err := c.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: maxReceiveMessages,
+			WaitTimeSeconds:     1,
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{
+				sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+				sqstypes.MessageSystemAttributeNameSentTimestamp,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		fresh, err := printPeeked(enc, resp.Messages, seen, requestID)
+		// 出力に失敗しても、中断されても見えるように戻す
+		resetVisibility(context.WithoutCancel(ctx), c, queueURL, resp.Messages)
+		if err != nil {
+			return err
+		}
+		if fresh == 0 {
+			idle++
+		} else {
+			idle = 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "peek: %d message(s) seen, none deleted\n", len(seen))
+	return nil
+}
+
+// まだ出していないメッセージを1行ずつ出し、その件数を返す
+func printPeeked(enc *json.Encoder, msgs []sqstypes.Message, seen map[string]bool, requestID string) (int, error) {
+	fresh := 0
+	for _, m := range msgs {
+		id := aws.ToString(m.MessageId)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		fresh++
+
+		body := aws.ToString(m.Body)
+		if requestID != "" {
+			var ev StatusChangedEvent
+			if json.Unmarshal([]byte(body), &ev) != nil || ev.RequestID != requestID {
+				continue
+			}
+		}
+		if !json.Valid([]byte(body)) {
+			// 壊れたJSONもそのまま見たいので文字列として出す
+			quoted, _ := json.Marshal(body)
+			body = string(quoted)
+		}
+		if err := enc.Encode(peekedMessage{
+			MessageID:    id,
+			ReceiveCount: m.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)],
+			SentAt:       m.Attributes[string(sqstypes.MessageSystemAttributeNameSentTimestamp)],
+			Body:         json.RawMessage(body),
+		}); err != nil {
+			return fresh, err
+		}
+	}
+	return fresh, nil
+}
+
+// 受信したメッセージ（出さなかったものも含む）を VisibilityTimeout=0 にして通常のworkerへ返す。
+// 戻せなくてもキューの既定の時間が過ぎれば見えるので、警告だけ出す
+func resetVisibility(ctx context.Context, c *sqs.Client, queueURL string, msgs []sqstypes.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	entries := make([]sqstypes.ChangeMessageVisibilityBatchRequestEntry, 0, len(msgs))
+	for i, m := range msgs {
+		entries = append(entries, sqstypes.ChangeMessageVisibilityBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			ReceiptHandle:     m.ReceiptHandle,
+			VisibilityTimeout: 0,
+		})
+	}
+	out, err := c.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+		QueueUrl: aws.String(queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "peek: failed to reset visibility of %d message(s): %v\n", len(msgs), err)
+		return
+	}
+	for _, f := range out.Failed {
+		fmt.Fprintf(os.Stderr, "peek: failed to reset visibility: %s %s\n", aws.ToString(f.Code), aws.ToString(f.Message))
+	}
+}
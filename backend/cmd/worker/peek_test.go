@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 受信したメッセージを隠し、ChangeMessageVisibilityBatch の0で戻すフェイクSQS。
+// 実際のSQSと同じく毎回同じ先頭から返すとは限らないよう、開始位置をずらしていく
+type fakePeekQueue struct {
+	mu      sync.Mutex
+	bodies  []string
+	hidden  map[string]bool // receiptHandle → 隠れているか
+	reset   int             // VisibilityTimeout=0 で戻した件数
+	receive int
+}
+
+func (q *fakePeekQueue) handle(op string, in map[string]any) (any, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch op {
+	case "ReceiveMessage":
+		q.receive++
+		var msgs []any
+		for k := range q.bodies {
+			i := (k + q.receive*7) % len(q.bodies)
+			b := q.bodies[i]
+			rh := fmt.Sprintf("rh-%d", i)
+			if q.hidden[rh] || len(msgs) == int(in["MaxNumberOfMessages"].(float64)) {
+				continue
+			}
+			q.hidden[rh] = true
+			msgs = append(msgs, map[string]any{
+				"MessageId":     fmt.Sprintf("m%d", i),
+				"ReceiptHandle": rh,
+				"Body":          b,
+				"Attributes":    map[string]any{"ApproximateReceiveCount": fmt.Sprint(q.receive)},
+			})
+		}
+		return map[string]any{"Messages": msgs}, nil
+	case "ChangeMessageVisibilityBatch":
+		var ok []any
+		for _, raw := range in["Entries"].([]any) {
+			e := raw.(map[string]any)
+			if e["VisibilityTimeout"] == float64(0) {
+				q.hidden[e["ReceiptHandle"].(string)] = false
+				q.reset++
+			}
+			ok = append(ok, map[string]any{"Id": e["Id"]})
+		}
+		return map[string]any{"Successful": ok}, nil
+	}
+	return nil, nil
+}
+
+func TestPeekQueueIsReadOnly(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  int
+		requestID string
+		max       int
+		wantLines int
+	}{
+		{name: "all messages", messages: 3, max: 100, wantLines: 3},
+		{name: "more than one batch", messages: 15, max: 100, wantLines: 15},
+		{name: "filtered by requestId", messages: 4, requestID: "r1", max: 100, wantLines: 2},
+		{name: "max messages", messages: 15, max: 10, wantLines: 10},
+		{name: "empty queue", messages: 0, max: 100, wantLines: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakePeekQueue{hidden: map[string]bool{}}
+			for i := range tt.messages {
+				q.bodies = append(q.bodies, fmt.Sprintf(`{"eventId":"e%d","requestId":"r%d"}`, i, i%2))
+			}
+			fake := &fakeAWS{t: t, handle: q.handle}
+
+			var out bytes.Buffer
+			if err := peekQueue(context.Background(), fake.sqsClient(), &out, "http://fake/queue", tt.requestID, tt.max); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if out.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != tt.wantLines {
+				t.Errorf("printed %d message(s), want %d:\n%s", len(lines), tt.wantLines, out.String())
+			}
+			for _, l := range lines {
+				var m peekedMessage
+				if err := json.Unmarshal([]byte(l), &m); err != nil {
+					t.Errorf("bad line %q: %v", l, err)
+				}
+			}
+			for _, op := range []string{"DeleteMessage", "DeleteMessageBatch"} {
+				if n := len(fake.callsOf(op)); n != 0 {
+					t.Errorf("%s called %d times", op, n)
+				}
+			}
+			// 受信したものは全部（出さなかったものも）見えるように戻っている
+			for rh, hidden := range q.hidden {
+				if hidden {
+					t.Errorf("%s still hidden from the worker", rh)
+				}
+			}
+			for _, c := range fake.callsOf("ReceiveMessage") {
+				if _, ok := c.Input["VisibilityTimeout"]; ok {
+					t.Errorf("ReceiveMessage sent VisibilityTimeout: %v", c.Input)
+				}
+			}
+		})
+	}
+}
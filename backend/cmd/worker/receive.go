@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWaitTimeSeconds   = 10
+	defaultVisibilityTimeout = 30
+	maxWaitTimeSeconds       = 20    // SQSのlong pollingの上限
+	maxVisibilityTimeout     = 43200 // SQSのvisibility timeoutの上限（12時間）
+	// 0だと受信直後にまた見えるようになり、すぐ再配信される（処理中の延長も間に合わない）
+	minVisibilityTimeout = 1
+)
+
+// ReceiveMessage の設定。スループット（大きいバッチ）とレイテンシ（小さいバッチ・短いwait）の調整用
+type receiveConfig struct {
+	maxMessages       int32 // SQS_MAX_MESSAGES（1–10、既定10）
+	waitSeconds       int32 // SQS_WAIT_TIME_SECONDS（0–20、既定10）
+	visibilityTimeout int32 // SQS_VISIBILITY_TIMEOUT（秒、1–43200、既定30）
+	// 処理中のメッセージの延長間隔（VISIBILITY_EXTEND_INTERVAL、既定はvisibility timeoutの半分）と上限
+	extendInterval time.Duration
+	maxHold        time.Duration // VISIBILITY_MAX_HOLD（既定5m）
+}
+
+// 範囲外や数値でない値は起動時にエラー（黙って既定値にすると調整したつもりで効いていないことに気づけない）
+func loadReceiveConfig() (receiveConfig, error) {
+	var rc receiveConfig
+	var err error
+	if rc.maxMessages, err = envInt32InRange("SQS_MAX_MESSAGES", maxReceiveMessages, 1, maxReceiveMessages); err != nil {
+		return rc, err
+	}
+	if rc.waitSeconds, err = envInt32InRange("SQS_WAIT_TIME_SECONDS", defaultWaitTimeSeconds, 0, maxWaitTimeSeconds); err != nil {
+		return rc, err
+	}
+	if rc.visibilityTimeout, err = envInt32InRange("SQS_VISIBILITY_TIMEOUT", defaultVisibilityTimeout, minVisibilityTimeout, maxVisibilityTimeout); err != nil {
+		return rc, err
+	}
+	// 延長はvisibility timeoutが切れる前に届かないと意味がない（切れた後だと再配信されて二重処理になる）
+	timeout := time.Duration(rc.visibilityTimeout) * time.Second
+	rc.extendInterval = timeout / 2
+	if v := os.Getenv("VISIBILITY_EXTEND_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d >= timeout {
+			return rc, fmt.Errorf("VISIBILITY_EXTEND_INTERVAL: must be a positive duration below SQS_VISIBILITY_TIMEOUT (%s), got %q", timeout, v)
+		}
+		rc.extendInterval = d
+	}
+	rc.maxHold = envDuration("VISIBILITY_MAX_HOLD", defaultVisibilityMaxHold)
+	return rc, nil
+}
+
+func envInt32InRange(key string, def, lo, hi int) (int32, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return int32(def), nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("%s: must be an integer between %d and %d, got %q", key, lo, hi, v)
+	}
+	return int32(n), nil
+}
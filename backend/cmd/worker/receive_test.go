@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoadReceiveConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantErr      bool
+		wantTimeout  int32
+		wantInterval time.Duration
+	}{
+		{name: "defaults", wantTimeout: 30, wantInterval: 15 * time.Second},
+		{name: "interval follows a short timeout", env: map[string]string{"SQS_VISIBILITY_TIMEOUT": "10"}, wantTimeout: 10, wantInterval: 5 * time.Second},
+		{name: "explicit interval below timeout", env: map[string]string{"SQS_VISIBILITY_TIMEOUT": "10", "VISIBILITY_EXTEND_INTERVAL": "8s"}, wantTimeout: 10, wantInterval: 8 * time.Second},
+		{name: "interval equal to timeout", env: map[string]string{"SQS_VISIBILITY_TIMEOUT": "10", "VISIBILITY_EXTEND_INTERVAL": "10s"}, wantErr: true},
+		{name: "interval above timeout", env: map[string]string{"VISIBILITY_EXTEND_INTERVAL": "1m"}, wantErr: true},
+		{name: "invalid interval", env: map[string]string{"VISIBILITY_EXTEND_INTERVAL": "soon"}, wantErr: true},
+		{name: "zero timeout", env: map[string]string{"SQS_VISIBILITY_TIMEOUT": "0"}, wantErr: true},
+		{name: "max messages out of range", env: map[string]string{"SQS_MAX_MESSAGES": "11"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"SQS_MAX_MESSAGES", "SQS_WAIT_TIME_SECONDS", "SQS_VISIBILITY_TIMEOUT", "VISIBILITY_EXTEND_INTERVAL"} {
+				t.Setenv(k, tt.env[k])
+			}
+			rc, err := loadReceiveConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if rc.visibilityTimeout != tt.wantTimeout || rc.extendInterval != tt.wantInterval {
+				t.Errorf("timeout=%d interval=%v, want %d %v", rc.visibilityTimeout, rc.extendInterval, tt.wantTimeout, tt.wantInterval)
+			}
+		})
+	}
+}
+
+// 受信エラー後の1秒待ちの間にシャットダウンされたら、待ち切らずにすぐ抜ける
+func TestRunLoopStopsDuringReceiveErrorBackoff(t *testing.T) {
+	failed := make(chan struct{}, 1)
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		select {
+		case failed <- struct{}{}:
+		default:
+		}
+		return nil, awsError{Status: http.StatusBadRequest, Code: "AccessDenied"}
+	}}
+	wk := &worker{
+		sqs:   fake.sqsClient(),
+		stats: &workerStats{},
+		recv:  receiveConfig{maxMessages: 1, visibilityTimeout: 30},
+		slots: newSemaphore(1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- wk.runLoop(ctx, "http://fake/queue", 0) }()
+
+	<-failed
+	time.Sleep(50 * time.Millisecond) // エラーが返って待ちに入るまで
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runLoop: %v", err)
+		}
+		if waited := time.Since(start); waited > 500*time.Millisecond {
+			t.Errorf("runLoop returned %v after cancel, want well under the 1s backoff", waited)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("runLoop did not return after cancel")
+	}
+	if n := len(fake.callsOf("ReceiveMessage")); n != 1 {
+		t.Errorf("ReceiveMessage called %d times, want 1", n)
+	}
+}
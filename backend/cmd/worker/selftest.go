@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/google/uuid"
+)
+
+// 本番のキューにテスト用イベントを流さないためのガード。
+// APP_ENV=production では常に拒否し、SQS_ENDPOINT（LocalStack）未設定なら SELFTEST_ALLOW_REMOTE=true が必要
+func selftestAllowed() error {
+	if os.Getenv("APP_ENV") == "production" {
+		return errors.New("selftest refused: APP_ENV=production")
+	}
+	if os.Getenv("SQS_ENDPOINT") == "" && !envBool("SELFTEST_ALLOW_REMOTE") {
+		return errors.New("selftest refused: SQS_ENDPOINT is not set (set SELFTEST_ALLOW_REMOTE=true to run against real AWS)")
+	}
+	return nil
+}
+
+// -selftest: テスト用requestを作り、イベントを送って自分で処理し、履歴に載ったことを確かめて片付ける。
+// 成功なら nil
+func (wk *worker) runSelftest(ctx context.Context, queueURL string) error {
+	if err := selftestAllowed(); err != nil {
+		return err
+	}
+	id := os.Getenv("SELFTEST_REQUEST_ID")
+	if id == "" {
+		id = "selftest"
+	}
+	ctx, cancel := context.WithTimeout(ctx, envDuration("SELFTEST_TIMEOUT", 60*time.Second))
+	defer cancel()
+
+	key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// GSIのキーは付けない（一覧に出さない）
+	_, err := wk.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(requestsTable),
+		Item: map[string]types.AttributeValue{
+			"PK":             key["PK"],
+			"title":          &types.AttributeValueMemberS{Value: "worker selftest"},
+			"status":         &types.AttributeValueMemberS{Value: "PENDING"},
+			"requesterToken": &types.AttributeValueMemberS{Value: uuid.NewString()},
+			"createdAt":      &types.AttributeValueMemberS{Value: now},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("put test request: %w", err)
+	}
+	defer func() {
+		if _, err := wk.ddb.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName: aws.String(requestsTable),
+			Key:       key,
+		}); err != nil {
+			log.Printf("selftest: cleanup error: %v requestId=%s", err, id)
+		}
+	}()
+
+	ev := StatusChangedEvent{
+		EventID:   uuid.NewString(),
+		RequestID: id,
+		NewStatus: "IN_PROGRESS",
+		ChangedAt: now,
+		ChangedBy: "selftest",
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := wk.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(queueURL),
+		MessageBody: aws.String(string(body)),
+	}); err != nil {
+		return fmt.Errorf("send test event: %w", err)
+	}
+	log.Printf("selftest: sent eventId=%s requestId=%s", ev.EventID, id)
+
+	// キューに他のメッセージがあればそれも普通に処理される
+	for {
+		wk.runOnce(ctx, queueURL)
+		done, err := eventProcessed(ctx, wk.ddb, key, ev.EventID)
+		if err != nil {
+			return fmt.Errorf("read test request: %w", err)
+		}
+		if done {
+			log.Printf("selftest: ok eventId=%s requestId=%s", ev.EventID, id)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("selftest: event %s not processed before timeout", ev.EventID)
+		}
+	}
+}
+
+func eventProcessed(ctx context.Context, ddb *dynamodb.Client, key map[string]types.AttributeValue, eventID string) (bool, error) {
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(requestsTable),
+		Key:                  key,
+		ProjectionExpression: aws.String("processedEventIds"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	ids, _ := out.Item["processedEventIds"].(*types.AttributeValueMemberSS)
+	if ids == nil {
+		return false, nil
+	}
+	for _, v := range ids.Value {
+		if v == eventID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "context"
+
+type semaphore struct {
+	ch chan struct{}
+}
+
+func newSemaphore(n int) *semaphore {
+	return &semaphore{ch: make(chan struct{}, n)}
+}
+
+// 最低1つ空くまで待ち、その後は待たずに取れるだけ（最大max）取る
+func (s *semaphore) acquire(ctx context.Context, max int) (int, error) {
+	select {
+	case s.ch <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	n := 1
+	for n < max {
+		select {
+		case s.ch <- struct{}{}:
+			n++
+		default:
+			return n, nil
+		}
+	}
+	return n, nil
+}
+
+func (s *semaphore) release(n int) {
+	for i := 0; i < n; i++ {
+		<-s.ch
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const (
+	defaultStartupRetries       = 5
+	defaultStartupRetryInterval = 1 * time.Second
+)
+
+// LocalStackの起動直後は依存サービスが応答しないことがあるので、指数バックオフで数回リトライする
+func retryStartup(ctx context.Context, name string, fn func(context.Context) error) error {
+	attempts := envInt("STARTUP_RETRIES", defaultStartupRetries)
+	interval := envDuration("STARTUP_RETRY_INTERVAL", defaultStartupRetryInterval)
+
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if i == attempts {
+			break
+		}
+		log.Printf("%s not ready (attempt %d/%d): %v; retrying in %s", name, i, attempts, err, interval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+	return fmt.Errorf("%s: gave up after %d attempts: %w", name, attempts, err)
+}
+
+func resolveQueueURLWithRetry(ctx context.Context, c *sqs.Client) (string, error) {
+	var url string
+	err := retryStartup(ctx, "sqs queue "+queueName, func(ctx context.Context) error {
+		var err error
+		url, err = resolveQueueURL(ctx, c)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w\n  -> the queue may not exist yet: run `make infra-apply` (or set SQS_QUEUE_URL)", err)
+	}
+	return url, nil
+}
+
+func checkTableWithRetry(ctx context.Context, ddb *dynamodb.Client) error {
+	err := retryStartup(ctx, "dynamodb table "+requestsTable, func(ctx context.Context) error {
+		_, err := ddb.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(requestsTable),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w\n  -> the table may not exist yet: run `make infra-apply`", err)
+	}
+	return nil
+}
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// 0を有効な値として扱う版
+func envIntAllowZero(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const tracerName = "equipment-request/worker"
+
+// backendと同じ。TRACING_ENABLED=true のときだけ OTEL_EXPORTER_OTLP_ENDPOINT へ送る
+func initTracing(ctx context.Context, service string) (func(context.Context) error, error) {
+	if !envBool("TRACING_ENABLED") {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// API側がMessageAttributesに入れたtrace contextを取り出す
+func extractTraceContext(ctx context.Context, m sqstypes.Message) context.Context {
+	carrier := propagation.MapCarrier{}
+	for k, v := range m.MessageAttributes {
+		if v.StringValue != nil {
+			carrier[k] = *v.StringValue
+		}
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
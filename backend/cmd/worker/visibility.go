@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const defaultVisibilityMaxHold = 5 * time.Minute
+
+// 処理が VISIBILITY_EXTEND_INTERVAL（既定はvisibility timeoutの半分）を超えたら、同じ間隔で ChangeMessageVisibility して
+// 見えない時間を延ばす（遅いwebhookで処理中に再配信されて二重処理になるのを防ぐ）。
+// 受信から VISIBILITY_MAX_HOLD（既定5m）を超えたら延長をやめ、通常通り再配信に任せる。
+// 返り値の関数で止める（処理が終わったら必ず呼ぶ）
+func (wk *worker) extendVisibility(ctx context.Context, queueURL string, m sqstypes.Message) (stop func()) {
+	interval, maxHold := wk.recv.extendInterval, wk.recv.maxHold
+	timeout := wk.recv.visibilityTimeout
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		start := time.Now()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			if time.Since(start)+time.Duration(timeout)*time.Second > maxHold {
+				log.Printf("visibility max hold reached messageId=%s, letting it be redelivered", aws.ToString(m.MessageId))
+				return
+			}
+			_, err := wk.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          aws.String(queueURL),
+				ReceiptHandle:     m.ReceiptHandle,
+				VisibilityTimeout: timeout,
+			})
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("change visibility error: %v messageId=%s", err, aws.ToString(m.MessageId))
+				}
+				continue
+			}
+			visibilityExtensions.Inc()
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestExtendVisibility(t *testing.T) {
+	tests := []struct {
+		name    string
+		work    time.Duration
+		maxHold time.Duration
+		wantMin int
+		wantMax int
+	}{
+		{name: "slow processor is extended every interval", work: 110 * time.Millisecond, maxHold: time.Minute, wantMin: 3, wantMax: 5},
+		{name: "fast processor is not extended", work: 5 * time.Millisecond, maxHold: time.Minute, wantMin: 0, wantMax: 0},
+		// 受信からの経過 + visibility timeout(1s) が maxHold を超えたら延長をやめる
+		{name: "extension stops at max hold", work: 150 * time.Millisecond, maxHold: time.Second + 50*time.Millisecond, wantMin: 1, wantMax: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeAWS{t: t}
+			wk := &worker{
+				sqs:  fake.sqsClient(),
+				recv: receiveConfig{visibilityTimeout: 1, extendInterval: 25 * time.Millisecond, maxHold: tt.maxHold},
+			}
+			m := sqstypes.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("rh-1")}
+
+			stop := wk.extendVisibility(context.Background(), "http://fake/queue", m)
+			time.Sleep(tt.work) // 遅いwebhookなどの処理
+			stop()
+
+			calls := fake.callsOf("ChangeMessageVisibility")
+			if len(calls) < tt.wantMin || len(calls) > tt.wantMax {
+				t.Fatalf("ChangeMessageVisibility calls = %d, want %d-%d", len(calls), tt.wantMin, tt.wantMax)
+			}
+			for _, c := range calls {
+				if c.Input["ReceiptHandle"] != "rh-1" || c.Input["VisibilityTimeout"] != float64(1) {
+					t.Errorf("unexpected input %v", c.Input)
+				}
+			}
+			// stop後は延長しない
+			n := len(calls)
+			time.Sleep(60 * time.Millisecond)
+			if got := len(fake.callsOf("ChangeMessageVisibility")); got != n {
+				t.Errorf("extended after stop: %d calls, want %d", got, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var errCircuitOpen = errors.New("webhook circuit open")
+
+// 送信先ごとにsecretとbreakerを持つ（1つの宛先が不調でも他の宛先は止めない）
+type webhookTarget struct {
+	name    string
+	url     string
+	secret  *secretValue
+	breaker *circuitBreaker
+}
+
+type webhookRouteConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type webhookSender struct {
+	client   *http.Client
+	retries  int
+	backoff  time.Duration
+	routes   map[string]*webhookTarget // newStatus → 宛先
+	fallback *webhookTarget            // WEBHOOK_URL（未設定ならnil）
+}
+
+// WEBHOOK_ROUTES='{"REJECTED":{"url":"...","secret":"..."}}' でステータス別の宛先、
+// WEBHOOK_URL / WEBHOOK_SECRET が既定の宛先。どちらもなければ nil（通知しない）
+func newWebhookSenderFromEnv() (*webhookSender, error) {
+	s := &webhookSender{
+		client:  &http.Client{Timeout: envDuration("WEBHOOK_TIMEOUT", 5*time.Second)},
+		retries: envInt("WEBHOOK_RETRIES", 3),
+		backoff: envDuration("WEBHOOK_RETRY_BACKOFF", 500*time.Millisecond),
+		routes:  map[string]*webhookTarget{},
+	}
+	newTarget := func(name, url string, secret *secretValue) *webhookTarget {
+		return &webhookTarget{
+			name:   name,
+			url:    url,
+			secret: secret,
+			breaker: newCircuitBreaker(
+				envInt("WEBHOOK_BREAKER_THRESHOLD", 5),
+				envDuration("WEBHOOK_BREAKER_COOLDOWN", 30*time.Second),
+			),
+		}
+	}
+
+	if v := os.Getenv("WEBHOOK_ROUTES"); v != "" {
+		var cfg map[string]webhookRouteConfig
+		if err := json.Unmarshal([]byte(v), &cfg); err != nil {
+			return nil, fmt.Errorf("WEBHOOK_ROUTES: %w", err)
+		}
+		// キーはイベントのステータスと同じ正規化をして引く。知らないステータスは設定ミスなので起動時にエラー
+		for key, rc := range cfg {
+			status := normalizeStatus(key)
+			if !knownStatuses[status] {
+				return nil, fmt.Errorf("WEBHOOK_ROUTES: unknown status %q (known: %s)", key, strings.Join(slices.Sorted(maps.Keys(knownStatuses)), ", "))
+			}
+			if _, dup := s.routes[status]; dup {
+				return nil, fmt.Errorf("WEBHOOK_ROUTES: %s is configured more than once", status)
+			}
+			if rc.URL == "" {
+				return nil, fmt.Errorf("WEBHOOK_ROUTES: url required for %s", status)
+			}
+			s.routes[status] = newTarget(status, rc.URL, staticSecret(rc.Secret))
+		}
+	}
+	if url := os.Getenv("WEBHOOK_URL"); url != "" {
+		secret, err := newSecret("WEBHOOK_SECRET")
+		if err != nil {
+			return nil, err
+		}
+		reloadSecretsOnSIGHUP(secret)
+		s.fallback = newTarget("default", url, secret)
+	}
+
+	if len(s.routes) == 0 && s.fallback == nil {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *webhookSender) targets() []*webhookTarget {
+	out := make([]*webhookTarget, 0, len(s.routes)+1)
+	for _, t := range s.routes {
+		out = append(out, t)
+	}
+	if s.fallback != nil {
+		out = append(out, s.fallback)
+	}
+	return out
+}
+
+func (s *webhookSender) targetFor(status string) *webhookTarget {
+	if t, ok := s.routes[status]; ok {
+		return t
+	}
+	return s.fallback
+}
+
+// SQSの受信回数（ApproximateReceiveCount）。取れなければ1
+func receiveCount(m sqstypes.Message) int {
+	n, err := strconv.Atoi(m.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// リトライ込みで1回の配送とみなし、その結果を宛先のbreakerに記録する。
+// 受信側の重複排除用に Idempotency-Key（eventId。再配信でも同じ）と、
+// X-Delivery-Attempt（SQSの再配信をまたいで増える試行番号。breakerで送らなかった回の分は飛ぶ）を付ける
+func (s *webhookSender) Deliver(ctx context.Context, ev StatusChangedEvent, receiveCount int) error {
+	t := s.targetFor(ev.NewStatus)
+	if t == nil {
+		return nil
+	}
+	if !t.breaker.Allow() {
+		return fmt.Errorf("%w (%s)", errCircuitOpen, t.name)
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		err = s.post(ctx, t, body, ev.EventID, (receiveCount-1)*s.retries+attempt)
+		if err == nil {
+			t.breaker.Success()
+			return nil
+		}
+		if attempt >= s.retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.breaker.Failure()
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	t.breaker.Failure()
+	return fmt.Errorf("webhook %s failed after %d attempts: %w", t.name, s.retries, err)
+}
+
+func (s *webhookSender) post(ctx context.Context, t *webhookTarget, body []byte, eventID string, attempt int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", eventID)
+	req.Header.Set("X-Delivery-Attempt", strconv.Itoa(attempt))
+	if secret := t.secret.Get(); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
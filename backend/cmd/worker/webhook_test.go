@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type webhookDelivery struct {
+	key, attempt, signature string
+	body                    []byte
+}
+
+// 同じイベントのリトライ・SQS再配信で Idempotency-Key と署名は変わらず、X-Delivery-Attempt だけが増える
+func TestWebhookDeliveryHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int // 先頭から何回500を返すか
+		receiveCount int
+		wantErr      bool
+		wantAttempts []string
+	}{
+		{name: "first try", failures: 0, receiveCount: 1, wantAttempts: []string{"1"}},
+		{name: "retried within one receive", failures: 2, receiveCount: 1, wantAttempts: []string{"1", "2", "3"}},
+		{name: "all retries fail", failures: 3, receiveCount: 1, wantErr: true, wantAttempts: []string{"1", "2", "3"}},
+		{name: "redelivered by SQS", failures: 1, receiveCount: 2, wantAttempts: []string{"4", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []webhookDelivery
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, webhookDelivery{
+					key:       r.Header.Get("Idempotency-Key"),
+					attempt:   r.Header.Get("X-Delivery-Attempt"),
+					signature: r.Header.Get("X-Signature"),
+					body:      body,
+				})
+				if len(got) <= tt.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer srv.Close()
+
+			t.Setenv("WEBHOOK_ROUTES", "")
+			t.Setenv("WEBHOOK_URL", srv.URL)
+			t.Setenv("WEBHOOK_SECRET", "s3cret")
+			t.Setenv("WEBHOOK_RETRIES", "3")
+			t.Setenv("WEBHOOK_RETRY_BACKOFF", "1ms")
+			s, err := newWebhookSenderFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ev := StatusChangedEvent{EventID: "e1", RequestID: "r1", NewStatus: "DONE", ChangedAt: "2024-05-01T09:00:00Z"}
+			err = s.Deliver(context.Background(), ev, tt.receiveCount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var attempts []string
+			for i, d := range got {
+				attempts = append(attempts, d.attempt)
+				if d.key != "e1" {
+					t.Errorf("delivery %d: Idempotency-Key = %q, want e1", i, d.key)
+				}
+				mac := hmac.New(sha256.New, []byte("s3cret"))
+				mac.Write(d.body)
+				if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.signature != want {
+					t.Errorf("delivery %d: X-Signature = %q, want %q", i, d.signature, want)
+				}
+				if i > 0 && (d.signature != got[0].signature || string(d.body) != string(got[0].body)) {
+					t.Errorf("delivery %d: body or signature changed across retries", i)
+				}
+			}
+			if !reflect.DeepEqual(attempts, tt.wantAttempts) {
+				t.Errorf("X-Delivery-Attempt = %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestReceiveCount(t *testing.T) {
+	attr := string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 1},
+		{"1", 1},
+		{"4", 4},
+		{"0", 1},
+		{"x", 1},
+	}
+	for _, tt := range tests {
+		m := sqstypes.Message{MessageId: aws.String("m1")}
+		if tt.value != "" {
+			m.Attributes = map[string]string{attr: tt.value}
+		}
+		if got := receiveCount(m); got != tt.want {
+			t.Errorf("receiveCount(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+// WEBHOOK_ROUTES のキーはイベントのステータスと同じく正規化して引く。知らないステータスは起動時にエラー
+func TestWebhookRoutesKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  string
+		wantErr string
+		want    map[string]string // ステータス → 宛先URL
+	}{
+		{
+			name:   "normalized",
+			routes: `{" rejected ":{"url":"http://reviewers/hook"},"In_Progress":{"url":"http://ops/hook"},"DONE":{"url":"http://done/hook"}}`,
+			want:   map[string]string{"REJECTED": "http://reviewers/hook", "IN_PROGRESS": "http://ops/hook", "DONE": "http://done/hook", "PENDING": "http://default/hook"},
+		},
+		{name: "unknown status", routes: `{"FINISHED":{"url":"http://x/hook"}}`, wantErr: `unknown status "FINISHED"`},
+		{name: "typo in key", routes: `{"REJECT":{"url":"http://x/hook"}}`, wantErr: `unknown status "REJECT"`},
+		{name: "same status twice after normalizing", routes: `{"done":{"url":"http://a/hook"},"DONE":{"url":"http://b/hook"}}`, wantErr: "DONE is configured more than once"},
+		{name: "missing url", routes: `{"done":{}}`, wantErr: "url required for DONE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WEBHOOK_ROUTES", tt.routes)
+			t.Setenv("WEBHOOK_URL", "http://default/hook")
+			s, err := newWebhookSenderFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for status, url := range tt.want {
+				if got := s.targetFor(status).url; got != url {
+					t.Errorf("targetFor(%s) = %s, want %s", status, got, url)
+				}
+			}
+		})
+	}
+}
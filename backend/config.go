@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+// 0を「無効/無制限」として使う設定用
+func envIntAllowZero(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+const defaultListenAddr = ":8080"
+
+// LISTEN_ADDR（既定 :8080）。"127.0.0.1:8080" でloopbackのみ、"[::1]:9090" 等も可
+func listenAddr() (string, error) {
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		return defaultListenAddr, nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("LISTEN_ADDR: %w", err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("LISTEN_ADDR: invalid port %q", port)
+	}
+	return addr, nil
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// 未設定・不正値ならdef
+func envBoolDefault(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// 未設定ならdef
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// カンマ区切りの値（空白を詰め、空の要素は捨てる）
+func envList(key, def string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		v = def
+	}
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// 起動時に1回だけ解決した設定（既定値や不正値のフォールバックを適用した後の値）。
+// GET /admin/config はこの構造体をリフレクションでそのまま出すので、フィールドを足せば自動で載る。
+// env タグが環境変数名、secret:"true" のフィールドは値を伏せる（設定されているかどうかだけ分かる）
+type Config struct {
+	AppEnv             string `env:"APP_ENV"`
+	AWSRegion          string `env:"AWS_REGION"`
+	AWSProfile         string `env:"AWS_PROFILE"`
+	AWSAccessKeyID     string `env:"AWS_ACCESS_KEY_ID" secret:"true"`
+	AWSSecretAccessKey string `env:"AWS_SECRET_ACCESS_KEY" secret:"true"`
+	AWSSessionToken    string `env:"AWS_SESSION_TOKEN" secret:"true"`
+	DynamoDBEndpoint   string `env:"DYNAMODB_ENDPOINT"`
+	SQSEndpoint        string `env:"SQS_ENDPOINT"`
+	SQSQueueURL        string `env:"SQS_QUEUE_URL"`
+
+	AdminToken     string `env:"ADMIN_TOKEN" secret:"true"` // ADMIN_TOKEN_FILE があればその中身
+	AdminTokenFile string `env:"ADMIN_TOKEN_FILE"`
+	AdminTokens    string `env:"ADMIN_TOKENS" secret:"true"`
+	CursorSecret   string `env:"CURSOR_SECRET" secret:"true"` // 空ならプロセスごとにランダム
+
+	ListenAddr         string                   `env:"LISTEN_ADDR"`
+	DisabledRoutes     []string                 `env:"DISABLED_ROUTES"`
+	CORSAllowedOrigins []string                 `env:"CORS_ALLOWED_ORIGINS"`
+	CORSExposeHeaders  string                   `env:"CORS_EXPOSE_HEADERS"`
+	ForceHTTPS         bool                     `env:"FORCE_HTTPS"`
+	HSTSMaxAge         int                      `env:"HSTS_MAX_AGE"`
+	HandlerTimeout     time.Duration            `env:"HANDLER_TIMEOUT"`
+	HandlerTimeouts    map[string]time.Duration `env:"HANDLER_TIMEOUTS"`
+	TLSCertFile        string                   `env:"TLS_CERT_FILE"`
+	TLSKeyFile         string                   `env:"TLS_KEY_FILE"`
+	AppPublicBaseURL   string                   `env:"APP_PUBLIC_BASE_URL"`
+	TrustProxyHeaders  bool                     `env:"TRUST_PROXY_HEADERS"`
+	AccessLogExclude   []string                 `env:"ACCESS_LOG_EXCLUDE"`
+	RejectionLog       bool                     `env:"REJECTION_LOG"`
+	MaxBodyBytes       int                      `env:"MAX_BODY_BYTES"`
+	GzipEnabled        bool                     `env:"GZIP_ENABLED"`
+	GzipMinBytes       int                      `env:"GZIP_MIN_BYTES"`
+
+	DefaultStatus               string        `env:"DEFAULT_STATUS"`
+	DisplayIDs                  bool          `env:"DISPLAY_IDS"`
+	EnableReadCache             bool          `env:"ENABLE_READ_CACHE"`
+	ReadCacheTTL                time.Duration `env:"READ_CACHE_TTL"`
+	ReadCacheSize               int           `env:"READ_CACHE_SIZE"`
+	TitleMinLen                 int           `env:"TITLE_MIN_LEN"`
+	TitlePattern                string        `env:"TITLE_PATTERN"`
+	TitleDenylist               string        `env:"TITLE_DENYLIST"`
+	ForbidDuplicateTitles       bool          `env:"FORBID_DUPLICATE_TITLES"`
+	DedupWindow                 time.Duration `env:"DEDUP_WINDOW"`
+	DedupIdentity               string        `env:"DEDUP_IDENTITY"`
+	MaxOpenRequestsPerRequester int           `env:"MAX_OPEN_REQUESTS_PER_REQUESTER"`
+	RequesterIdentity           string        `env:"REQUESTER_IDENTITY"`
+	OpenRequestsRetryAfter      time.Duration `env:"OPEN_REQUESTS_RETRY_AFTER"`
+	GetRateLimit                int           `env:"GET_RATE_LIMIT"`
+	GetRateBurst                int           `env:"GET_RATE_BURST"`
+	CreateRateLimit             int           `env:"CREATE_RATE_LIMIT"`
+	CreateRateBurst             int           `env:"CREATE_RATE_BURST"`
+
+	APIWritesHistory        bool     `env:"API_WRITES_HISTORY"`
+	ReasonRequiredStatuses  []string `env:"REASON_REQUIRED_STATUSES"`
+	ReturnRequesterToken    bool     `env:"RETURN_REQUESTER_TOKEN"`
+	TimeFormat              string   `env:"TIME_FORMAT"`
+	EmitCreatedEvents       bool     `env:"EMIT_CREATED_EVENTS"`
+	HistoryStorage          string   `env:"HISTORY_STORAGE"`
+	HistoryRetention        int      `env:"HISTORY_RETENTION"`
+	DynamoDBConsistentReads bool     `env:"DYNAMODB_CONSISTENT_READS"`
+	AllowDestructiveOps     bool     `env:"ALLOW_DESTRUCTIVE_OPS"`
+	BulkMaxItems            int      `env:"BULK_MAX_ITEMS"`
+	BulkConcurrency         int      `env:"BULK_CONCURRENCY"`
+	HistoryBatchMaxIDs      int      `env:"HISTORY_BATCH_MAX_IDS"`
+	BatchGetMaxItems        int      `env:"BATCH_GET_MAX_ITEMS"`
+
+	OverdueGrace           time.Duration `env:"OVERDUE_GRACE"`
+	QueueStatsCacheTTL     time.Duration `env:"QUEUE_STATS_CACHE_TTL"`
+	DependencyTripDuration time.Duration `env:"DEPENDENCY_TRIP_DURATION"`
+	EventMaxAge            time.Duration `env:"EVENT_MAX_AGE"`
+	WorkerHeartbeatStale   time.Duration `env:"WORKER_HEARTBEAT_STALE"`
+	StartupRetries         int           `env:"STARTUP_RETRIES"`
+	StartupRetryInterval   time.Duration `env:"STARTUP_RETRY_INTERVAL"`
+
+	TracingEnabled           bool   `env:"TRACING_ENABLED"`
+	OTELExporterOTLPEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	EnablePprof              bool   `env:"ENABLE_PPROF"`
+	PprofAddr                string `env:"PPROF_ADDR"`
+	EnableDebugEndpoints     bool   `env:"ENABLE_DEBUG_ENDPOINTS"`
+}
+
+// 各設定を読む側と同じヘルパー・既定値で解決する。起動を止めるべき不正値はエラー
+func loadConfig() (Config, error) {
+	c := Config{
+		AppEnv:             os.Getenv("APP_ENV"),
+		AWSRegion:          os.Getenv("AWS_REGION"),
+		AWSProfile:         os.Getenv("AWS_PROFILE"),
+		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		AWSSecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		DynamoDBEndpoint:   os.Getenv("DYNAMODB_ENDPOINT"),
+		SQSEndpoint:        os.Getenv("SQS_ENDPOINT"),
+		SQSQueueURL:        os.Getenv("SQS_QUEUE_URL"),
+
+		AdminToken:     adminTokenSecret.Get(),
+		AdminTokenFile: os.Getenv("ADMIN_TOKEN_FILE"),
+		AdminTokens:    os.Getenv("ADMIN_TOKENS"),
+		CursorSecret:   os.Getenv("CURSOR_SECRET"),
+
+		CORSAllowedOrigins: envList("CORS_ALLOWED_ORIGINS", ""),
+		CORSExposeHeaders:  envString("CORS_EXPOSE_HEADERS", defaultCORSExposeHeaders),
+		ForceHTTPS:         envBool("FORCE_HTTPS"),
+		HSTSMaxAge:         envIntAllowZero("HSTS_MAX_AGE", defaultHSTSMaxAge),
+		TLSCertFile:        os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:         os.Getenv("TLS_KEY_FILE"),
+		AppPublicBaseURL:   strings.TrimRight(envString("APP_PUBLIC_BASE_URL", defaultPublicBaseURL), "/"),
+		TrustProxyHeaders:  envBool("TRUST_PROXY_HEADERS"),
+		AccessLogExclude:   envList("ACCESS_LOG_EXCLUDE", defaultAccessLogExclude),
+		RejectionLog:       envBoolDefault("REJECTION_LOG", true),
+		MaxBodyBytes:       envInt("MAX_BODY_BYTES", defaultMaxBodyBytes),
+		GzipEnabled:        envBoolDefault("GZIP_ENABLED", true),
+		GzipMinBytes:       envInt("GZIP_MIN_BYTES", defaultGzipMinBytes),
+
+		DisplayIDs:                  envBool("DISPLAY_IDS"),
+		EnableReadCache:             envBool("ENABLE_READ_CACHE"),
+		ReadCacheTTL:                envDuration("READ_CACHE_TTL", defaultReadCacheTTL),
+		ReadCacheSize:               envInt("READ_CACHE_SIZE", defaultReadCacheSize),
+		TitleMinLen:                 envInt("TITLE_MIN_LEN", 1),
+		TitlePattern:                os.Getenv("TITLE_PATTERN"),
+		TitleDenylist:               os.Getenv("TITLE_DENYLIST"),
+		ForbidDuplicateTitles:       envBool("FORBID_DUPLICATE_TITLES"),
+		DedupWindow:                 envDuration("DEDUP_WINDOW", 0),
+		MaxOpenRequestsPerRequester: envInt("MAX_OPEN_REQUESTS_PER_REQUESTER", 0),
+		OpenRequestsRetryAfter:      envDuration("OPEN_REQUESTS_RETRY_AFTER", time.Minute),
+		GetRateLimit:                envInt("GET_RATE_LIMIT", 0),
+		CreateRateLimit:             envInt("CREATE_RATE_LIMIT", 0),
+
+		APIWritesHistory:        envBool("API_WRITES_HISTORY"),
+		ReturnRequesterToken:    envBool("RETURN_REQUESTER_TOKEN"),
+		EmitCreatedEvents:       envBool("EMIT_CREATED_EVENTS"),
+		HistoryRetention:        envIntAllowZero("HISTORY_RETENTION", defaultHistoryRetention),
+		DynamoDBConsistentReads: envBoolDefault("DYNAMODB_CONSISTENT_READS", true),
+		AllowDestructiveOps:     envBool("ALLOW_DESTRUCTIVE_OPS"),
+		BulkMaxItems:            envInt("BULK_MAX_ITEMS", defaultBulkMaxItems),
+		BulkConcurrency:         envInt("BULK_CONCURRENCY", defaultBulkConcurrency),
+		HistoryBatchMaxIDs:      envInt("HISTORY_BATCH_MAX_IDS", defaultHistoryBatchMax),
+		BatchGetMaxItems:        envInt("BATCH_GET_MAX_ITEMS", defaultBatchGetMaxItems),
+
+		OverdueGrace:           envDuration("OVERDUE_GRACE", 0),
+		QueueStatsCacheTTL:     envDuration("QUEUE_STATS_CACHE_TTL", 5*time.Second),
+		DependencyTripDuration: envDuration("DEPENDENCY_TRIP_DURATION", 5*time.Second),
+		EventMaxAge:            envDuration("EVENT_MAX_AGE", 0),
+		WorkerHeartbeatStale:   envDuration("WORKER_HEARTBEAT_STALE", defaultWorkerHeartbeatStale),
+		StartupRetries:         envInt("STARTUP_RETRIES", defaultStartupRetries),
+		StartupRetryInterval:   envDuration("STARTUP_RETRY_INTERVAL", defaultStartupRetryInterval),
+
+		TracingEnabled:           envBool("TRACING_ENABLED"),
+		OTELExporterOTLPEndpoint: os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		EnablePprof:              envBool("ENABLE_PPROF"),
+		PprofAddr:                envString("PPROF_ADDR", defaultPprofAddr),
+		EnableDebugEndpoints:     envBool("ENABLE_DEBUG_ENDPOINTS"),
+	}
+	// 知らない値は既定の扱いになる（読む側の switch / 比較と同じ）
+	c.DedupIdentity = "requester"
+	if v := os.Getenv("DEDUP_IDENTITY"); v == "ip" || v == "api_key" {
+		c.DedupIdentity = v
+	}
+	c.RequesterIdentity = "ip"
+	if os.Getenv("REQUESTER_IDENTITY") == "api_key" {
+		c.RequesterIdentity = "api_key"
+	}
+	c.TimeFormat = "rfc3339"
+	if strings.EqualFold(os.Getenv("TIME_FORMAT"), "epoch") {
+		c.TimeFormat = "epoch"
+	}
+	c.HistoryStorage = "inline"
+	if historyItemsMode() {
+		c.HistoryStorage = "items"
+	}
+	for _, st := range envList("REASON_REQUIRED_STATUSES", "REJECTED") {
+		c.ReasonRequiredStatuses = append(c.ReasonRequiredStatuses, normalizeStatus(st))
+	}
+	// BURST の既定は RATE_LIMIT と同じ（レート制限が無効なら0）
+	c.GetRateBurst = envInt("GET_RATE_BURST", c.GetRateLimit)
+	c.CreateRateBurst = envInt("CREATE_RATE_BURST", c.CreateRateLimit)
+	if c.GetRateLimit == 0 {
+		c.GetRateBurst = 0
+	}
+	if c.CreateRateLimit == 0 {
+		c.CreateRateBurst = 0
+	}
+
+	var err error
+	if c.ListenAddr, err = listenAddr(); err != nil {
+		return c, err
+	}
+	if c.DefaultStatus, err = loadDefaultStatus(); err != nil {
+		return c, err
+	}
+	timeouts, err := loadHandlerTimeouts()
+	if err != nil {
+		return c, err
+	}
+	c.HandlerTimeout, c.HandlerTimeouts = timeouts.def, timeouts.routes
+	disabled, err := loadDisabledRoutes()
+	if err != nil {
+		return c, err
+	}
+	for name := range disabled {
+		c.DisabledRoutes = append(c.DisabledRoutes, name)
+	}
+	sort.Strings(c.DisabledRoutes)
+	return c, nil
+}
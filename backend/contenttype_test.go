@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithJSONContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		chunked     bool
+		wantStatus  int
+	}{
+		{name: "json", method: http.MethodPost, contentType: "application/json", body: "{}", wantStatus: http.StatusOK},
+		{name: "json with charset", method: http.MethodPatch, contentType: "application/json; charset=utf-8", body: "{}", wantStatus: http.StatusOK},
+		{name: "uppercase media type", method: http.MethodPut, contentType: "Application/JSON", body: "{}", wantStatus: http.StatusOK},
+		{name: "text plain", method: http.MethodPost, contentType: "text/plain", body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "form", method: http.MethodPatch, contentType: "application/x-www-form-urlencoded", body: "a=b", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "json suffix type", method: http.MethodPost, contentType: "application/problem+json", body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing content type", method: http.MethodPost, body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed content type", method: http.MethodPost, contentType: "application/json; charset", body: "{}", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "chunked body without content type", method: http.MethodPost, body: "{}", chunked: true, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "post without body", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "get ignores content type", method: http.MethodGet, contentType: "text/plain", body: "x", wantStatus: http.StatusOK},
+		{name: "delete ignores content type", method: http.MethodDelete, contentType: "text/plain", body: "x", wantStatus: http.StatusOK},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/requests", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.chunked {
+				r.ContentLength = -1
+				r.TransferEncoding = []string{"chunked"}
+			}
+			rec := httptest.NewRecorder()
+			withJSONContentType(next).ServeHTTP(rec, r)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
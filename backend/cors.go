@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// ブラウザのJSから読めるようにするレスポンスヘッダ（CORS_EXPOSE_HEADERS で上書き）
+	defaultCORSExposeHeaders = "X-Request-ID,ETag,Location,Retry-After,X-Next-Cursor,X-Total-Count"
+	corsAllowHeaders         = "Content-Type, Authorization, X-Request-ID, X-Requester-Token, X-Requester-Key"
+	corsAllowMethods         = "GET, POST, PATCH, PUT, DELETE, HEAD"
+)
+
+// CORS_ALLOWED_ORIGINS（カンマ区切り。"*" で全許可、空なら無効）に一致するOriginにだけCORSヘッダを付ける。
+// X-Request-ID などのカスタムヘッダは Access-Control-Expose-Headers に載せないとJSから見えない
+func withCORS(next http.Handler) http.Handler {
+	origins := map[string]bool{}
+	for _, o := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins[o] = true
+		}
+	}
+	if len(origins) == 0 {
+		return next
+	}
+	expose := os.Getenv("CORS_EXPOSE_HEADERS")
+	if expose == "" {
+		expose = defaultCORSExposeHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(origins["*"] || origins[origin]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", expose)
+
+		// preflight はハンドラまで渡さない
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
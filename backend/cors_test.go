@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithCORSExposeHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    string
+		expose     string
+		origin     string
+		preflight  bool
+		wantExpose string // 空ならCORSヘッダなし
+		wantStatus int
+	}{
+		{name: "allowed origin", allowed: "https://app.example", origin: "https://app.example", wantExpose: defaultCORSExposeHeaders, wantStatus: http.StatusOK},
+		{name: "wildcard", allowed: "*", origin: "https://other.example", wantExpose: defaultCORSExposeHeaders, wantStatus: http.StatusOK},
+		{name: "configured expose set", allowed: "*", expose: "X-Request-ID", origin: "https://app.example", wantExpose: "X-Request-ID", wantStatus: http.StatusOK},
+		{name: "other origin", allowed: "https://app.example", origin: "https://evil.example", wantStatus: http.StatusOK},
+		{name: "same-origin request", allowed: "*", wantStatus: http.StatusOK},
+		{name: "cors disabled", origin: "https://app.example", wantStatus: http.StatusOK},
+		{name: "preflight", allowed: "*", origin: "https://app.example", preflight: true, wantExpose: defaultCORSExposeHeaders, wantStatus: http.StatusNoContent},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "rid-1")
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.allowed)
+			t.Setenv("CORS_EXPOSE_HEADERS", tt.expose)
+			method := http.MethodGet
+			if tt.preflight {
+				method = http.MethodOptions
+			}
+			r := httptest.NewRequest(method, "/requests/r1", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			}
+			rec := httptest.NewRecorder()
+			withCORS(next).ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			h := rec.Header()
+			if got := h.Get("Access-Control-Expose-Headers"); got != tt.wantExpose {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.wantExpose)
+			}
+			wantOrigin := ""
+			if tt.wantExpose != "" {
+				wantOrigin = tt.origin
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, wantOrigin)
+			}
+			if tt.preflight && h.Get("Access-Control-Allow-Methods") == "" {
+				t.Error("preflight without Access-Control-Allow-Methods")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateRequestReturnsCreatedWithLocation(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		putErr       error
+		wantStatus   int
+		wantLocation string // 空なら Location なし。"*" は生成されたrequestId
+	}{
+		{name: "generated id", body: `{"title":"laptop"}`, wantStatus: http.StatusCreated, wantLocation: "*"},
+		{name: "client id", body: `{"title":"laptop","requestId":"req-1"}`, wantStatus: http.StatusCreated, wantLocation: "/requests/req-1"},
+		{name: "client id conflict", body: `{"title":"laptop","requestId":"req-1"}`, putErr: awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException"}, wantStatus: http.StatusConflict},
+		{name: "missing title", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"DEDUP_WINDOW", "DISPLAY_IDS", "FORBID_DUPLICATE_TITLES", "EMIT_CREATED_EVENTS", "MAX_OPEN_REQUESTS_PER_REQUESTER", "RETURN_REQUESTER_TOKEN"} {
+				t.Setenv(k, "")
+			}
+			t.Setenv("APP_PUBLIC_BASE_URL", "https://tracker.example")
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+				if op == "PutItem" {
+					return nil, tt.putErr
+				}
+				t.Errorf("unexpected op %s", op)
+				return nil, nil
+			}}
+			srv := &server{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), queueURL: "http://fake/queue"}
+
+			rec := httptest.NewRecorder()
+			srv.handleCreateRequest(rec, httptest.NewRequest(http.MethodPost, "/requests", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			loc := rec.Header().Get("Location")
+			if tt.wantLocation == "" {
+				if loc != "" {
+					t.Errorf("Location = %q, want none", loc)
+				}
+				return
+			}
+			var out CreateRequestOutput
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+				t.Fatalf("bad body: %v", err)
+			}
+			want := tt.wantLocation
+			if want == "*" {
+				want = "/requests/" + out.RequestID
+			}
+			if out.RequestID == "" || loc != want {
+				t.Errorf("Location = %q, want %q", loc, want)
+			}
+			if !strings.HasPrefix(out.TrackingURL, "https://tracker.example"+want+"?t=") {
+				t.Errorf("trackingUrl = %q, want it under %s", out.TrackingURL, want)
+			}
+		})
+	}
+}
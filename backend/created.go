@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// メッセージ属性 eventType でworkerが処理を振り分ける（属性なしは StatusChanged 扱い）
+const (
+	eventTypeAttr           = "eventType"
+	eventTypeStatusChanged  = "StatusChanged"
+	eventTypeRequestCreated = "RequestCreated"
+)
+
+// EMIT_CREATED_EVENTS=true のとき作成直後に送る。workerは初期ステータスの履歴を1件入れる
+type RequestCreatedEvent struct {
+	EventID       string `json:"eventId"`
+	RequestID     string `json:"requestId"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"createdAt"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+}
+
+// traceの伝播用の属性に eventType を足す
+func eventMessageAttributes(ctx context.Context, eventType string) map[string]sqstypes.MessageAttributeValue {
+	attrs := traceMessageAttributes(ctx)
+	if attrs == nil {
+		attrs = map[string]sqstypes.MessageAttributeValue{}
+	}
+	attrs[eventTypeAttr] = sqstypes.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(eventType),
+	}
+	return attrs
+}
+
+func enqueueRequestCreated(ctx context.Context, c *sqs.Client, queueURL string, ev RequestCreatedEvent) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "sqs.SendMessage",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("eventId", ev.EventID), attribute.String("requestId", ev.RequestID)),
+	)
+	defer span.End()
+
+	ev.SchemaVersion = eventSchemaVersion
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = c.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: eventMessageAttributes(ctx, eventTypeRequestCreated),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "send failed")
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+// CURSOR_SECRET 未設定ならプロセスごとにランダム（再起動で古いcursorは無効になる）
+func loadCursorSecret() []byte {
+	if v := os.Getenv("CURSOR_SECRET"); v != "" {
+		return []byte(v)
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// LastEvaluatedKey を改ざん検知付きの不透明な文字列にする。
+// キー属性はすべてS型（PK / GSI1PK / createdAt）なので map[string]string で足りる。
+func encodeCursor(secret []byte, key map[string]types.AttributeValue) string {
+	if len(key) == 0 {
+		return ""
+	}
+	plain := map[string]string{}
+	for k, v := range key {
+		if sv, ok := v.(*types.AttributeValueMemberS); ok {
+			plain[k] = sv.Value
+		}
+	}
+	payload, _ := json.Marshal(plain)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeCursor(secret []byte, cursor string) (map[string]types.AttributeValue, error) {
+	p, s, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, errBadCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errBadCursor
+	}
+
+	var plain map[string]string
+	if err := json.Unmarshal(payload, &plain); err != nil {
+		return nil, errBadCursor
+	}
+	key := make(map[string]types.AttributeValue, len(plain))
+	for k, v := range plain {
+		key[k] = &types.AttributeValueMemberS{Value: v}
+	}
+	return key, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 二重送信とみなした先行リクエストがまだ書き込み中（番兵だけある）
+var errDedupInFlight = errors.New("duplicate submission in progress")
+
+// DEDUP_WINDOW（既定0=無効）の間、同じ依頼者・同じタイトルの作成は新規作成せず既存のリクエストを返す。
+// Idempotency-Key と違ってクライアント側の対応はいらない（ブラウザの二重送信など）
+func dedupWindow() time.Duration {
+	return envDuration("DEDUP_WINDOW", 0)
+}
+
+// DEDUP_IDENTITY: requester（既定。REQUESTER_IDENTITY と同じ識別子）/ ip / api_key。
+// api_key で X-Requester-Key がなければ空（重複判定しない）
+func dedupIdentity(r *http.Request) string {
+	switch os.Getenv("DEDUP_IDENTITY") {
+	case "ip":
+		return hashedKey("ip", clientIP(r))
+	case "api_key":
+		if k := r.Header.Get("X-Requester-Key"); k != "" {
+			return hashedKey("key", k)
+		}
+		return ""
+	default:
+		return requesterKeyFrom(r)
+	}
+}
+
+// タイトルの正規化は FORBID_DUPLICATE_TITLES と同じ（大文字小文字・空白の違いは同じとみなす）
+func dedupSentinelPK(identity, title string) string {
+	norm := strings.ToLower(strings.Join(strings.Fields(title), " "))
+	sum := sha256.Sum256([]byte(identity + "\n" + norm))
+	return "DEDUP#" + hex.EncodeToString(sum[:])
+}
+
+// 番兵item（PK=DEDUP#<hash>, requestId, expiresAt）を置く。期限内の番兵があれば、その requestId を返す。
+// expiresAt はテーブルのTTL属性だが、TTLの削除は遅れるので条件式でも期限を見る
+func claimDedup(ctx context.Context, ddb *dynamodb.Client, sentinel, requestID string, window time.Duration) (existingID string, err error) {
+	now := time.Now().Unix()
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(requestsTable),
+		Item: map[string]types.AttributeValue{
+			"PK":        &types.AttributeValueMemberS{Value: sentinel},
+			"requestId": &types.AttributeValueMemberS{Value: requestID},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(now+int64(window.Seconds()), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(PK) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var cfe *types.ConditionalCheckFailedException
+	if errors.As(err, &cfe) {
+		var sent sentinelItem
+		unmarshalItem(cfe.Item, &sent)
+		return sent.RequestID, nil
+	}
+	return "", err
+}
+
+// 作成に失敗したら番兵を消す（消せなくても期限で外れる）
+func releaseDedup(ctx context.Context, ddb *dynamodb.Client, sentinel, requestID string) {
+	_, _ = ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(requestsTable),
+		Key:                 map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: sentinel}},
+		ConditionExpression: aws.String("requestId = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: requestID},
+		},
+	})
+}
+
+// 既存リクエストを作成時と同じ形で返す（trackingUrl付き。送信したのと同じ依頼者なので）
+func dedupExistingOutput(ctx context.Context, ddb *dynamodb.Client, r *http.Request, id string) (CreateRequestOutput, error) {
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(requestsTable),
+		Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return CreateRequestOutput{}, err
+	}
+	if out.Item == nil {
+		return CreateRequestOutput{}, errDedupInFlight
+	}
+	it := decodeRequestItem(out.Item)
+	res := CreateRequestOutput{
+		RequestID:   id,
+		DisplayID:   it.DisplayID,
+		Title:       it.Title,
+		Status:      it.Status,
+		Tags:        it.sortedTags(),
+		CreatedAt:   it.CreatedAt,
+		TrackingURL: publicBaseURL(r) + "/requests/" + id + "?t=" + it.RequesterToken,
+		Version:     it.Version,
+	}
+	if envBool("RETURN_REQUESTER_TOKEN") {
+		res.RequesterToken = it.RequesterToken
+	}
+	return res, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// 依存サービスの不調状態。接続系のエラーを見たら DEPENDENCY_TRIP_DURATION（既定5s）の間は不調とみなし、
+// その間の書き込みはDynamoDBを叩かずに503で返す（/ready も503）
+type dependencyState struct {
+	name string
+
+	mu      sync.Mutex
+	until   time.Time
+	lastErr string
+}
+
+var (
+	dynamoHealth = &dependencyState{name: "dynamodb"}
+	sqsHealth    = &dependencyState{name: "sqs"}
+)
+
+func (d *dependencyState) trip(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.until = time.Now().Add(envDuration("DEPENDENCY_TRIP_DURATION", 5*time.Second))
+	d.lastErr = err.Error()
+}
+
+// 不調なら残り時間を返す
+func (d *dependencyState) degraded() (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	left := time.Until(d.until)
+	return left, left > 0
+}
+
+// 接続できない・応答がない・5xx のように、時間をおけば直りうるエラーか。
+// ValidationException などリクエスト自体の問題はfalse。
+// contextのキャンセル（クライアント切断・HANDLER_TIMEOUT）も依存側の問題ではないのでfalse
+func isUnavailableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var sendErr *smithyhttp.RequestSendError
+	if errors.As(err, &sendErr) {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// 接続系のエラーなら依存を不調にして true
+func observeDependencyError(d *dependencyState, err error) bool {
+	if !isUnavailableError(err) {
+		return false
+	}
+	d.trip(err)
+	return true
+}
+
+// Retry-Afterは秒（切り上げ、最低1）
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(d.Seconds())))))
+}
+
+func writeUnavailable(w http.ResponseWriter, r *http.Request, d *dependencyState, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	httpError(w, r, d.name+" unavailable, retry later", http.StatusServiceUnavailable)
+}
+
+// DynamoDBが不調の間、書き込み系のメソッドはハンドラまで行かずに503
+func withDependencyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			if left, ok := dynamoHealth.degraded(); ok {
+				writeUnavailable(w, r, dynamoHealth, left)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /ready。依存が不調なら503（/health はプロセスが生きているかだけ）
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	var down []string
+	var retry time.Duration
+	for _, d := range []*dependencyState{dynamoHealth, sqsHealth} {
+		if left, ok := d.degraded(); ok {
+			d.mu.Lock()
+			down = append(down, fmt.Sprintf("%s: %s", d.name, d.lastErr))
+			d.mu.Unlock()
+			retry = max(retry, left)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(down) > 0 {
+		setRetryAfter(w, retry)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "degraded\n"+strings.Join(down, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var errConnRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+// フェイクDynamoDBにGetItemを1回投げて、SDKが返すエラーを得る
+func sdkError(t *testing.T, fail error) error {
+	t.Helper()
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) { return nil, fail }}
+	_, err := fake.dynamoClient().GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(requestsTable),
+		Key:       map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#r1"}},
+	})
+	if err == nil {
+		t.Fatal("GetItem succeeded")
+	}
+	return err
+}
+
+func resetDependencyState(t *testing.T) {
+	t.Cleanup(func() {
+		for _, d := range []*dependencyState{dynamoHealth, sqsHealth} {
+			d.mu.Lock()
+			d.until, d.lastErr = time.Time{}, ""
+			d.mu.Unlock()
+		}
+	})
+}
+
+func TestIsUnavailableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  func(t *testing.T) error
+		want bool
+	}{
+		{name: "connection refused from the SDK", err: func(t *testing.T) error { return sdkError(t, errConnRefused) }, want: true},
+		{name: "5xx from the SDK", err: func(t *testing.T) error {
+			return sdkError(t, awsError{Status: http.StatusInternalServerError, Code: "InternalServerError"})
+		}, want: true},
+		{name: "validation error from the SDK", err: func(t *testing.T) error {
+			return sdkError(t, awsError{Status: http.StatusBadRequest, Code: "ValidationException"})
+		}, want: false},
+		{name: "bare connection refused", err: func(*testing.T) error { return fmt.Errorf("put: %w", syscall.ECONNREFUSED) }, want: true},
+		{name: "connection reset", err: func(*testing.T) error { return syscall.ECONNRESET }, want: true},
+		{name: "context canceled", err: func(*testing.T) error { return fmt.Errorf("get: %w", context.Canceled) }, want: false},
+		{name: "deadline exceeded", err: func(*testing.T) error { return context.DeadlineExceeded }, want: false},
+		{name: "other error", err: func(*testing.T) error { return errors.New("boom") }, want: false},
+		{name: "nil", err: func(*testing.T) error { return nil }, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err(t)
+			if got := isUnavailableError(err); got != tt.want {
+				t.Errorf("isUnavailableError(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+// 接続拒否で503＋Retry-After、その後はDynamoDBを叩かずに書き込みを503で返し、/ready も503になる
+func TestConnectionRefusedTripsDependency(t *testing.T) {
+	resetDependencyState(t)
+	t.Setenv("DEPENDENCY_TRIP_DURATION", "3s")
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, "/requests/r1/status", nil)
+	writeStoreError(rec, r, sdkError(t, errConnRefused), "failed to update")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "3" {
+		t.Fatalf("status=%d Retry-After=%q, want 503 and 3", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	reached := 0
+	guarded := withDependencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached++ }))
+	for _, tt := range []struct {
+		method string
+		want   int
+	}{
+		{http.MethodPost, http.StatusServiceUnavailable},
+		{http.MethodPatch, http.StatusServiceUnavailable},
+		{http.MethodDelete, http.StatusServiceUnavailable},
+		{http.MethodGet, http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		guarded.ServeHTTP(rec, httptest.NewRequest(tt.method, "/requests", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.method, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", tt.method)
+		}
+	}
+	if reached != 1 {
+		t.Errorf("handler reached %d times, want only the GET", reached)
+	}
+
+	rec = httptest.NewRecorder()
+	handleReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/ready status = %d, want 503", rec.Code)
+	}
+}
+
+func TestValidationErrorDoesNotTrip(t *testing.T) {
+	resetDependencyState(t)
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPatch, "/requests/r1/status", nil)
+	writeStoreError(rec, r, sdkError(t, awsError{Status: http.StatusBadRequest, Code: "ValidationException"}), "failed to update")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if _, ok := dynamoHealth.degraded(); ok {
+		t.Error("validation error tripped the dependency")
+	}
+}
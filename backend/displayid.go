@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 表示用の連番を数えるカウンタitem（Requestsテーブル。一覧・exportは REQ# で絞るので混ざらない）
+const displayIDCounterPK = "SEQ#requests"
+
+// DISPLAY_IDS=true のとき、作成時に REQ-00001 のような連番を displayId として振る。
+// 電話などで読み上げる用の別名で、キーはあくまで requestId（UUID）
+func displayIDsEnabled() bool {
+	return envBool("DISPLAY_IDS")
+}
+
+func formatDisplayID(seq int64) string {
+	return fmt.Sprintf("REQ-%05d", seq)
+}
+
+// カウンタを1つ進めて新しい値を返す。ADDはitemや属性がなければ0から作るので、
+// 初回の同時作成でも初期化の競合はない（どちらも別の番号を受け取る）。
+// 採番後に作成が失敗すると番号は欠番になる（連番の穴は許容する）
+func nextDisplayID(ctx context.Context, ddb *dynamodb.Client) (string, error) {
+	out, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(requestsTable),
+		Key:              map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: displayIDCounterPK}},
+		UpdateExpression: aws.String("ADD seq :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return "", err
+	}
+	n, ok := out.Attributes["seq"].(*types.AttributeValueMemberN)
+	if !ok {
+		return "", fmt.Errorf("display id counter: seq missing in response")
+	}
+	seq, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("display id counter: %w", err)
+	}
+	return formatDisplayID(seq), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestFormatDisplayID(t *testing.T) {
+	cases := []struct {
+		seq  int64
+		want string
+	}{
+		{1, "REQ-00001"},
+		{42, "REQ-00042"},
+		{99999, "REQ-99999"},
+		{100000, "REQ-100000"}, // 5桁を超えても切り詰めない
+	}
+	for _, c := range cases {
+		if got := formatDisplayID(c.seq); got != c.want {
+			t.Errorf("formatDisplayID(%d) = %q, want %q", c.seq, got, c.want)
+		}
+	}
+}
+
+// カウンタitemの ADD seq :one をDynamoDBと同じく1件ずつ原子的に適用するフェイク。
+// itemがない状態から同時に採番しても番号が重複・欠落しないことを確かめる
+func TestNextDisplayIDConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var seq int64
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		if op != "UpdateItem" {
+			t.Errorf("unexpected op %s", op)
+			return nil, nil
+		}
+		key := in["Key"].(map[string]any)["PK"].(map[string]any)["S"]
+		if key != displayIDCounterPK || in["UpdateExpression"] != "ADD seq :one" || in["ReturnValues"] != "UPDATED_NEW" {
+			t.Errorf("unexpected counter update: %v", in)
+		}
+		mu.Lock()
+		seq++
+		n := seq
+		mu.Unlock()
+		return map[string]any{"Attributes": map[string]any{"seq": map[string]any{"N": strconv.FormatInt(n, 10)}}}, nil
+	}}
+	ddb := fake.dynamoClient()
+
+	const workers = 50
+	ids := make([]string, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := nextDisplayID(context.Background(), ddb)
+			if err != nil {
+				t.Errorf("nextDisplayID: %v", err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("duplicate display id %s", id)
+		}
+		seen[id] = true
+	}
+	for n := int64(1); n <= workers; n++ {
+		if !seen[formatDisplayID(n)] {
+			t.Errorf("missing %s", formatDisplayID(n))
+		}
+	}
+}
+
+func TestNextDisplayIDMissingSeq(t *testing.T) {
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		return map[string]any{"Attributes": map[string]any{}}, nil
+	}}
+	if _, err := nextDisplayID(context.Background(), fake.dynamoClient()); err == nil {
+		t.Error("want error when the response has no seq")
+	}
+}
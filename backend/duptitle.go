@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var errDuplicateTitle = errors.New("duplicate open request title")
+
+func isTerminalStatus(s string) bool {
+	nexts, ok := allowedTransitions[s]
+	return ok && len(nexts) == 0
+}
+
+// 大文字小文字・前後/連続空白の違いは同じタイトルとみなす。長いタイトルでもキー長が一定になるようハッシュ化
+func titleSentinelPK(title string) string {
+	norm := strings.ToLower(strings.Join(strings.Fields(title), " "))
+	sum := sha256.Sum256([]byte(norm))
+	return "TITLE#" + hex.EncodeToString(sum[:])
+}
+
+// FORBID_DUPLICATE_TITLES=true のときの作成処理。
+// タイトルごとの番兵item（PK=TITLE#<hash>, requestId）とリクエスト本体を同一トランザクションで書くので、
+// 読んでから書く方式と違って同時作成でも二重にならない。
+// 番兵が指す既存リクエストが終端済み（またはもう存在しない）なら番兵を付け替えて作成する。
+func putRequestUniqueTitle(ctx context.Context, ddb *dynamodb.Client, item map[string]types.AttributeValue, requestID, title string) (existingID string, err error) {
+	sentinel := titleSentinelPK(title)
+
+	// 1回目: 番兵が存在しないこと。2回目: 番兵が古いリクエストを指したままであること
+	cond := "attribute_not_exists(PK)"
+	var condValues map[string]types.AttributeValue
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{
+					TableName: aws.String(requestsTable),
+					Item: map[string]types.AttributeValue{
+						"PK":        &types.AttributeValueMemberS{Value: sentinel},
+						"requestId": &types.AttributeValueMemberS{Value: requestID},
+					},
+					ConditionExpression:       aws.String(cond),
+					ExpressionAttributeValues: condValues,
+				}},
+				{Put: &types.Put{
+					TableName:           aws.String(requestsTable),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(PK)"),
+				}},
+			},
+		})
+		if err == nil {
+			return "", nil
+		}
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) == 2 &&
+			aws.ToString(tce.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+			return "", errRequestIDExists
+		}
+		if !errors.As(err, &tce) || len(tce.CancellationReasons) == 0 ||
+			aws.ToString(tce.CancellationReasons[0].Code) != "ConditionalCheckFailed" {
+			return "", err
+		}
+
+		existingID, open, err := sentinelOwnerOpen(ctx, ddb, sentinel)
+		if err != nil {
+			return "", err
+		}
+		if open {
+			return existingID, errDuplicateTitle
+		}
+		cond = "requestId = :old"
+		condValues = map[string]types.AttributeValue{
+			":old": &types.AttributeValueMemberS{Value: existingID},
+		}
+	}
+	// 付け替え中に他の作成と競合した
+	return "", errDuplicateTitle
+}
+
+// 番兵が指すリクエストIDと、それがまだ未完了かどうか
+func sentinelOwnerOpen(ctx context.Context, ddb *dynamodb.Client, sentinel string) (string, bool, error) {
+	out, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(requestsTable),
+		Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: sentinel}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	var sent sentinelItem
+	unmarshalItem(out.Item, &sent)
+	id := sent.RequestID
+	if id == "" {
+		return "", false, nil
+	}
+
+	req, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(requestsTable),
+		Key:                  map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+		ProjectionExpression: aws.String("#st"),
+		ExpressionAttributeNames: map[string]string{
+			"#st": "status",
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	status := decodeRequestItem(req.Item).Status
+	return id, status != "" && !isTerminalStatus(status), nil
+}
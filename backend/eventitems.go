@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// HISTORY_STORAGE=items のときの履歴テーブル。1イベント1item（PK=REQ#<id>, SK=EVT#<changedAt>#<eventId>）。
+// Requestsにはソートキーがないので別テーブル
+const eventsTable = "RequestEvents"
+
+// 既定は inline（requestのitemの statusHistory に追記）
+func historyItemsMode() bool {
+	return os.Getenv("HISTORY_STORAGE") == "items"
+}
+
+func historyEntryFromEventItem(item map[string]types.AttributeValue) HistoryEntry {
+	var e HistoryEntry
+	unmarshalItem(item, &e)
+	return e
+}
+
+// GET /requests/{id}/history（items モード）。SK順（changedAt順）にQueryし、続きは X-Next-Cursor。
+// offsetやX-Total-Countは全件を読まないと出せないので使わない
+func (s *server) handleHistoryItems(w http.ResponseWriter, r *http.Request, id, token, statusFilter string, limit int) {
+	q := r.URL.Query()
+	if q.Get("offset") != "" {
+		httpError(w, r, "offset is not supported with HISTORY_STORAGE=items; use cursor", http.StatusBadRequest)
+		return
+	}
+	in := &dynamodb.QueryInput{
+		TableName:              aws.String(eventsTable),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "REQ#" + id},
+		},
+		ConsistentRead: aws.Bool(wantConsistentRead(r)),
+	}
+	if statusFilter != "" {
+		in.FilterExpression = aws.String("newStatus = :s")
+		in.ExpressionAttributeValues[":s"] = &types.AttributeValueMemberS{Value: statusFilter}
+	}
+	if c := q.Get("cursor"); c != "" {
+		key, err := decodeCursor(s.cursorSecret, c)
+		if err != nil {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		in.ExclusiveStartKey = key
+	}
+
+	if _, err := getRequesterItemProjected(r.Context(), s.ddb, id, token, wantConsistentRead(r), []string{"PK"}); err != nil {
+		writeRequesterItemError(w, r, err)
+		return
+	}
+
+	// Limitはフィルタ前の件数なので、絞り込み時は足りるまで読み進める
+	entries := make([]HistoryEntry, 0, limit)
+	var next map[string]types.AttributeValue
+	for {
+		in.Limit = aws.Int32(int32(limit - len(entries)))
+		out, err := s.ddb.Query(r.Context(), in)
+		if err != nil {
+			writeStoreError(w, r, err, "failed to read")
+			return
+		}
+		for _, item := range out.Items {
+			entries = append(entries, historyEntryFromEventItem(item))
+		}
+		next = out.LastEvaluatedKey
+		if len(next) == 0 || len(entries) >= limit {
+			break
+		}
+		in.ExclusiveStartKey = next
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if c := encodeCursor(s.cursorSecret, next); c != "" {
+		w.Header().Set("X-Next-Cursor", c)
+	}
+	writeJSON(w, r, entries)
+}
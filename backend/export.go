@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var exportColumns = []string{"requestId", "title", "status", "priority", "assignee", "createdAt", "statusUpdatedAt"}
+
+// GET /admin/requests/export (admin only)
+// Scanのページごとに書き出すので件数が多くてもメモリは1ページ分で済む
+func (s *server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeRead); !ok {
+		return
+	}
+
+	p := dynamodb.NewScanPaginator(s.ddb, &dynamodb.ScanInput{
+		TableName:        aws.String(requestsTable),
+		ConsistentRead:   aws.Bool(false),
+		FilterExpression: aws.String("begins_with(PK, :reqPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":reqPrefix": &types.AttributeValueMemberS{Value: "REQ#"},
+		},
+	})
+	src := scanSource(p)
+
+	// 最初のページで失敗した場合だけ500を返せる
+	items, done, err := src(r.Context())
+	if err != nil {
+		httpError(w, r, "failed to read", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "requests-" + time.Now().UTC().Format("20060102-150405") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(exportColumns)
+	rows := 0
+	for {
+		for _, item := range items {
+			it := decodeRequestItem(item)
+			priority := ""
+			if it.Priority != nil {
+				priority = strconv.Itoa(*it.Priority)
+			}
+			_ = cw.Write([]string{it.requestID(), it.Title, it.Status, priority, it.Assignee, it.CreatedAt, it.StatusUpdatedAt})
+			rows++
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Printf("export write error: %v (after %d rows)", err, rows)
+			return
+		}
+		if done {
+			break
+		}
+		_ = rc.Flush()
+
+		items, done, err = src(r.Context())
+		if err != nil {
+			// ヘッダ送信後なので途中までのCSVで打ち切る
+			log.Printf("export read error: %v (after %d rows)", err, rows)
+			return
+		}
+	}
+	log.Printf("export done rows=%d", rows)
+}
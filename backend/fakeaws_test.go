@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// DynamoDB/SQS のJSONプロトコルをプロセス内で受けるフェイク（HTTPClientとして差し込む）。
+// handleは操作名（X-Amz-Target の後半）とリクエストJSONを受け取り、応答JSONかawsErrorを返す
+type fakeAWS struct {
+	t      *testing.T
+	handle func(op string, in map[string]any) (any, error)
+
+	mu    sync.Mutex
+	calls []fakeCall
+}
+
+type fakeCall struct {
+	Op    string
+	Input map[string]any
+}
+
+// DynamoDB/SQSのエラー応答（__type で例外の型が決まる）。ItemはConditionalCheckFailedのALL_OLD、
+// ReasonsはTransactionCanceledExceptionのCancellationReasons（Codeだけ）
+type awsError struct {
+	Status  int
+	Code    string
+	Item    map[string]any
+	Reasons []string
+}
+
+func (e awsError) Error() string { return e.Code }
+
+func (f *fakeAWS) Do(req *http.Request) (*http.Response, error) {
+	target := req.Header.Get("X-Amz-Target")
+	op := target[strings.LastIndex(target, ".")+1:]
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	in := map[string]any{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &in); err != nil {
+			f.t.Errorf("%s: bad request body: %v", op, err)
+		}
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{Op: op, Input: in})
+	f.mu.Unlock()
+
+	status, out := http.StatusOK, any(map[string]any{})
+	if f.handle != nil {
+		resp, err := f.handle(op, in)
+		if ae, ok := err.(awsError); ok {
+			body := map[string]any{"__type": ae.Code, "message": ae.Code}
+			if ae.Item != nil {
+				body["Item"] = ae.Item
+			}
+			if ae.Reasons != nil {
+				var reasons []map[string]any
+				for _, c := range ae.Reasons {
+					reasons = append(reasons, map[string]any{"Code": c})
+				}
+				body["CancellationReasons"] = reasons
+			}
+			status, out = ae.Status, body
+		} else if err != nil {
+			return nil, err
+		} else if resp != nil {
+			out = resp
+		}
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		f.t.Fatalf("%s: encode response: %v", op, err)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/x-amz-json-1.0"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeAWS) callsOf(op string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeCall
+	for _, c := range f.calls {
+		if c.Op == op {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (f *fakeAWS) sqsClient() *sqs.Client {
+	return sqs.New(sqs.Options{
+		Region:                           "us-east-1",
+		BaseEndpoint:                     aws.String("http://fake"),
+		Credentials:                      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		HTTPClient:                       f,
+		Retryer:                          aws.NopRetryer{},
+		DisableMessageChecksumValidation: true,
+	})
+}
+
+func (f *fakeAWS) dynamoClient() *dynamodb.Client {
+	return dynamodb.New(dynamodb.Options{
+		Region:                          "us-east-1",
+		BaseEndpoint:                    aws.String("http://fake"),
+		Credentials:                     credentials.NewStaticCredentialsProvider("test", "test", ""),
+		HTTPClient:                      f,
+		Retryer:                         aws.NopRetryer{},
+		DisableValidateResponseChecksum: true,
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ?fields= で指定できるフィールド（JSONのキー → DynamoDBの属性名）。
+// requesterTokenはここに載せないので射影できない
+var requestFieldAttrs = map[string]string{
+	"requestId":    "PK",
+	"displayId":    "displayId",
+	"title":        "title",
+	"status":       "status",
+	"tags":         "tags",
+	"createdAt":    "createdAt",
+	"statusReason": "statusReason",
+}
+
+// ?fields=title,status を検証して返す。未指定ならnil（全フィールド）
+func parseFields(r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+	var fields []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if _, ok := requestFieldAttrs[f]; !ok {
+			return nil, fmt.Errorf("unknown field: %s", f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields must not be empty")
+	}
+	return fields, nil
+}
+
+func fieldAttrs(fields []string) []string {
+	attrs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, requestFieldAttrs[f])
+	}
+	return attrs
+}
+
+// statusなどの予約語があるので属性名はすべて #p0.. に置き換える
+func projection(attrs []string) (*string, map[string]string) {
+	names := make(map[string]string, len(attrs))
+	refs := make([]string, 0, len(attrs))
+	for i, a := range attrs {
+		ref := fmt.Sprintf("#p%d", i)
+		names[ref] = a
+		refs = append(refs, ref)
+	}
+	return aws.String(strings.Join(refs, ", ")), names
+}
+
+// GetRequestOutputのうち指定フィールドだけを返す
+func projectRequestOutput(id string, item map[string]types.AttributeValue, fields []string) map[string]any {
+	g := getRequestOutputFromItem(id, item)
+	out := make(map[string]any, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "requestId":
+			out[f] = g.RequestID
+		case "displayId":
+			out[f] = g.DisplayID
+		case "title":
+			out[f] = g.Title
+		case "status":
+			out[f] = g.Status
+		case "tags":
+			out[f] = g.Tags
+		case "createdAt":
+			out[f] = g.CreatedAt
+		case "statusReason":
+			out[f] = g.StatusReason
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultGzipMinBytes = 1024
+
+// Accept-Encoding: gzip のクライアントにだけ圧縮して返す。
+// 先頭 GZIP_MIN_BYTES バイトまではバッファし、それより小さいレスポンスは圧縮しない。
+// SSE（text/event-stream）と、ハンドラが自分でContent-Encodingを付けたものは素通し
+func withGzip(next http.Handler) http.Handler {
+	if !envBoolDefault("GZIP_ENABLED", true) {
+		return next
+	}
+	minBytes := envInt("GZIP_MIN_BYTES", defaultGzipMinBytes)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, minBytes: minBytes}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// "gzip;q=0" は拒否扱い
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if c := strings.TrimSpace(coding); c != "gzip" && c != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minBytes int
+	status   int
+	buf      []byte
+	decided  bool // 圧縮する/しないを決めてヘッダを送った後
+	gz       *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.decided || g.status != 0 {
+		return
+	}
+	g.status = code
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.decided {
+		g.buf = append(g.buf, b...)
+		if len(g.buf) < g.minBytes {
+			return len(b), nil
+		}
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// バッファ済みの内容を、compress=trueなら（可能であれば）圧縮して書き出す
+func (g *gzipResponseWriter) decide(compress bool) error {
+	g.decided = true
+	h := g.ResponseWriter.Header()
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		// 圧縮後のバイト列でsniffされないように先に決めておく
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if compress && h.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") &&
+		g.status != http.StatusNoContent && g.status != http.StatusNotModified {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(buf)
+	} else {
+		_, err = g.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// ストリーミング応答はFlushされた時点で圧縮を始める（ページごとに届くように）
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		_ = g.decide(true)
+	}
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) close() {
+	if !g.decided {
+		// 閾値に届かなかった小さいレスポンス
+		_ = g.decide(false)
+	}
+	if g.gz != nil {
+		_ = g.gz.Close()
+	}
+}
+
+// http.ResponseController 用
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
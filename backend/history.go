@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// statusHistoryの要素とRequestEventsのitemで属性名は共通
+type HistoryEntry struct {
+	EventID   string `json:"eventId" dynamodbav:"eventId"`
+	NewStatus string `json:"newStatus" dynamodbav:"newStatus"`
+	ChangedAt string `json:"changedAt" dynamodbav:"changedAt"`
+	ChangedBy string `json:"changedBy,omitempty" dynamodbav:"changedBy,omitempty"`
+	HandledAt string `json:"handledAt,omitempty" dynamodbav:"handledAt,omitempty"`
+	Reason    string `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+}
+
+// workerが追記したstatusHistory(L of M)をデコードする。壊れた要素は読み飛ばす
+func decodeHistory(item map[string]types.AttributeValue) []HistoryEntry {
+	l, ok := item["statusHistory"].(*types.AttributeValueMemberL)
+	if !ok {
+		return nil
+	}
+	entries := make([]HistoryEntry, 0, len(l.Value))
+	for _, v := range l.Value {
+		m, ok := v.(*types.AttributeValueMemberM)
+		if !ok {
+			continue
+		}
+		var e HistoryEntry
+		unmarshalItem(m.Value, &e)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// GET /requests/{id}/history?t=...&status=DONE&limit=50&offset=0
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request, id string) {
+	q := r.URL.Query()
+	t, ok := requireRequesterToken(w, r)
+	if !ok {
+		return
+	}
+	statusFilter := normalizeStatus(q.Get("status"))
+	if statusFilter != "" && !isValidStatus(statusFilter) {
+		httpError(w, r, "invalid status", http.StatusBadRequest)
+		return
+	}
+	limit, offset, ok := parsePage(q.Get("limit"), q.Get("offset"))
+	if !ok {
+		httpError(w, r, "invalid limit/offset", http.StatusBadRequest)
+		return
+	}
+	if historyItemsMode() {
+		s.handleHistoryItems(w, r, id, t, statusFilter, limit)
+		return
+	}
+
+	item, err := getRequesterItem(r.Context(), s.ddb, id, t, wantConsistentRead(r))
+	if err != nil {
+		writeRequesterItemError(w, r, err)
+		return
+	}
+
+	// フィルタ → 件数確定 → ページング の順
+	entries := decodeHistory(item)
+	if statusFilter != "" {
+		matched := entries[:0]
+		for _, e := range entries {
+			if e.NewStatus == statusFilter {
+				matched = append(matched, e)
+			}
+		}
+		entries = matched
+	}
+	total := len(entries)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, r, entries[start:end])
+}
+
+func parsePage(limitStr, offsetStr string) (limit, offset int, ok bool) {
+	limit = defaultHistoryLimit
+	if limitStr != "" {
+		v, err := strconv.Atoi(limitStr)
+		if err != nil || v <= 0 || v > maxHistoryLimit {
+			return 0, 0, false
+		}
+		limit = v
+	}
+	if offsetStr != "" {
+		v, err := strconv.Atoi(offsetStr)
+		if err != nil || v < 0 {
+			return 0, 0, false
+		}
+		offset = v
+	}
+	return limit, offset, true
+}
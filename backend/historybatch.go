@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	batchGetMaxKeys          = 100 // BatchGetItemの1回あたりの上限
+	defaultHistoryBatchMax   = 500
+	batchGetUnprocessedRetry = 5
+)
+
+var errUnprocessedKeys = errors.New("unprocessed keys remained after retries")
+
+type HistoryBatchInput struct {
+	RequestIDs []string `json:"requestIds"`
+}
+
+// POST /admin/requests/history/batch (read scope)
+// 存在しないIDは結果のmapに含めない
+func (s *server) handleHistoryBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeRead); !ok {
+		return
+	}
+
+	var in HistoryBatchInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(in.RequestIDs) == 0 {
+		httpError(w, r, "requestIds required", http.StatusBadRequest)
+		return
+	}
+	maxIDs := envInt("HISTORY_BATCH_MAX_IDS", defaultHistoryBatchMax)
+	if len(in.RequestIDs) > maxIDs {
+		httpError(w, r, "too many requestIds (max "+strconv.Itoa(maxIDs)+")", http.StatusBadRequest)
+		return
+	}
+	// BatchGetItemは同じキーが2回あるとエラーになるので重複を落とす
+	seen := map[string]bool{}
+	ids := make([]string, 0, len(in.RequestIDs))
+	for _, id := range in.RequestIDs {
+		if id == "" {
+			httpError(w, r, "empty requestId", http.StatusBadRequest)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	out := make(map[string][]HistoryEntry, len(ids))
+	for start := 0; start < len(ids); start += batchGetMaxKeys {
+		items, err := batchGetRequests(r.Context(), s.ddb, ids[start:min(start+batchGetMaxKeys, len(ids))], []string{"PK", "statusHistory"})
+		if err != nil {
+			writeStoreError(w, r, err, "failed to read")
+			return
+		}
+		for _, item := range items {
+			h := decodeHistory(item)
+			if h == nil {
+				h = []HistoryEntry{}
+			}
+			out[decodeRequestItem(item).requestID()] = h
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, out)
+}
+
+// 100件以下のIDを attrs の属性だけ読む。UnprocessedKeys（スロットリング等）はバックオフして読み直す
+func batchGetRequests(ctx context.Context, ddb *dynamodb.Client, ids []string, attrs []string) ([]map[string]types.AttributeValue, error) {
+	keys := make([]map[string]types.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + id},
+		})
+	}
+	proj, names := projection(attrs)
+	req := map[string]types.KeysAndAttributes{
+		requestsTable: {
+			Keys:                     keys,
+			ProjectionExpression:     proj,
+			ExpressionAttributeNames: names,
+		},
+	}
+
+	var items []map[string]types.AttributeValue
+	backoff := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		res, err := ddb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: req})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, res.Responses[requestsTable]...)
+		if len(res.UnprocessedKeys[requestsTable].Keys) == 0 {
+			return items, nil
+		}
+		if attempt >= batchGetUnprocessedRetry {
+			return nil, errUnprocessedKeys
+		}
+		req = res.UnprocessedKeys
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 読み込みの失敗はストアのエラーとして返す。再試行しても残った UnprocessedKeys は一時的なものなので503
+func TestHistoryBatchReadErrors(t *testing.T) {
+	unprocessed := func(in map[string]any) (any, error) {
+		return map[string]any{"UnprocessedKeys": in["RequestItems"]}, nil
+	}
+	tests := []struct {
+		name         string
+		batchGet     func(in map[string]any) (any, error)
+		wantCode     int
+		wantRetry    bool
+		wantDegraded bool
+	}{
+		{name: "unprocessed keys after retries", batchGet: unprocessed, wantCode: http.StatusServiceUnavailable, wantRetry: true},
+		{name: "dynamodb unreachable", batchGet: func(map[string]any) (any, error) { return nil, errConnRefused },
+			wantCode: http.StatusServiceUnavailable, wantRetry: true, wantDegraded: true},
+		{name: "unexpected error", batchGet: func(map[string]any) (any, error) {
+			return nil, awsError{Status: http.StatusBadRequest, Code: "ValidationException"}
+		}, wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKENS", "")
+			resetDependencyState(t)
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) { return tt.batchGet(in) }}
+			srv := &server{ddb: fake.dynamoClient()}
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/requests/history/batch", strings.NewReader(`{"requestIds":["r1","r2"]}`))
+			r.Header.Set("Authorization", "Bearer dev-admin-token")
+			rec := httptest.NewRecorder()
+			srv.handleHistoryBatch(rec, r)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if got := rec.Header().Get("Retry-After") != ""; got != tt.wantRetry {
+				t.Errorf("Retry-After set = %v, want %v", got, tt.wantRetry)
+			}
+			if _, degraded := dynamoHealth.degraded(); degraded != tt.wantDegraded {
+				t.Errorf("dynamodb degraded = %v, want %v", degraded, tt.wantDegraded)
+			}
+			decodeErrorBody(t, rec)
+		})
+	}
+}
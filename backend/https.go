@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// HSTS_MAX_AGE（秒）の既定は1年
+const defaultHSTSMaxAge = 365 * 24 * 60 * 60
+
+// FORCE_HTTPS=true のとき、平文HTTPのリクエストを同じURLのhttpsへ308でリダイレクトし、
+// HTTPSの応答には Strict-Transport-Security を付ける。
+// TLS終端のプロキシの後ろでは X-Forwarded-Proto で判定する。/health はHTTPのままのprobe用に対象外
+func withForceHTTPS(next http.Handler) http.Handler {
+	if !envBool("FORCE_HTTPS") {
+		return next
+	}
+	maxAge := defaultHSTSMaxAge
+	if v, err := strconv.Atoi(os.Getenv("HSTS_MAX_AGE")); err == nil && v >= 0 {
+		maxAge = v
+	}
+	hsts := "max-age=" + strconv.Itoa(maxAge)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.TLS == nil && !strings.EqualFold(firstForwarded(r.Header.Get("X-Forwarded-Proto")), "https") {
+			// 308はメソッドと本文を保ったままリダイレクトさせる（POST/PATCHも同じリクエストで送り直される）
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+			return
+		}
+		w.Header().Set("Strict-Transport-Security", hsts)
+		next.ServeHTTP(w, r)
+	})
+}
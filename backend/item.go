@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 依頼itemのPKは REQ#<requestId>
+const requestPKPrefix = "REQ#"
+
+// Requestsテーブルの依頼item。属性名はこのタグにまとめる（作成・読み込み・更新で名前がずれないように）。
+// 射影して読むこともあるので、読み込み時はどの属性もなくてよい。
+// statusHistory / processedEventIds / pendingEvents などworkerや更新式だけが触る属性はここに載せない
+type requestItem struct {
+	PK              string   `dynamodbav:"PK"`
+	Title           string   `dynamodbav:"title,omitempty"`
+	Status          string   `dynamodbav:"status,omitempty"`
+	InitialStatus   string   `dynamodbav:"initialStatus,omitempty"`
+	CreatedAt       string   `dynamodbav:"createdAt,omitempty"`
+	GSI1PK          string   `dynamodbav:"GSI1PK,omitempty"`
+	RequesterToken  string   `dynamodbav:"requesterToken,omitempty"`
+	RequesterKey    string   `dynamodbav:"requesterKey,omitempty"`
+	OwnerKey        string   `dynamodbav:"ownerKey,omitempty"` // GSIのキー属性なので空文字は入れない（omitempty）
+	RequesterEmail  string   `dynamodbav:"requesterEmail,omitempty"`
+	Tags            []string `dynamodbav:"tags,stringset,omitempty"`
+	Assignee        string   `dynamodbav:"assignee,omitempty"`
+	Priority        *int     `dynamodbav:"priority,omitempty"`
+	DueAt           string   `dynamodbav:"dueAt,omitempty"`
+	StatusUpdatedAt string   `dynamodbav:"statusUpdatedAt,omitempty"`
+	StatusChangedBy string   `dynamodbav:"statusChangedBy,omitempty"`
+	StatusReason    string   `dynamodbav:"statusReason,omitempty"`
+	DisplayID       string   `dynamodbav:"displayId,omitempty"` // DISPLAY_IDS=true で作成したものだけ
+	// ステータス・タグ・担当者・優先度/期限の更新のたびに ADD version :one で+1。属性がない古いitemは0
+	Version int `dynamodbav:"version,omitempty"`
+}
+
+func (it requestItem) requestID() string {
+	return strings.TrimPrefix(it.PK, requestPKPrefix)
+}
+
+// タグをソート済みで返す（なければ空配列。JSONでnullにしない）
+func (it requestItem) sortedTags() []string {
+	out := append([]string{}, it.Tags...)
+	sort.Strings(out)
+	return out
+}
+
+// 重複防止の番兵item（DEDUP#<hash> / TITLE#<hash>）。指している依頼のID
+type sentinelItem struct {
+	RequestID string `dynamodbav:"requestId"`
+}
+
+func marshalRequestItem(it requestItem) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMap(it)
+}
+
+// 型の合わない属性があっても、読めた分で続ける（壊れた属性は空扱い）
+func unmarshalItem(item map[string]types.AttributeValue, out any) {
+	if err := attributevalue.UnmarshalMap(item, out); err != nil {
+		log.Printf("unmarshal item: %v", err)
+	}
+}
+
+func decodeRequestItem(item map[string]types.AttributeValue) requestItem {
+	var it requestItem
+	unmarshalItem(item, &it)
+	return it
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+
+	createdAtIndex = "createdAt-index"
+	gsi1PKPrefix   = "REQ#" // createdAt-index のパーティションキーは REQ#yyyy-mm
+)
+
+// createdAt-index は作成月でシャードする。全件同じキーだと書き込みが1パーティションに集中する
+func gsi1PKFor(createdAt string) string {
+	if len(createdAt) < 7 {
+		return gsi1PKPrefix + createdAt
+	}
+	return gsi1PKPrefix + createdAt[:7]
+}
+
+// from〜to（UTC）にかかる月のシャードを古い順に
+func monthShards(from, to time.Time) []string {
+	var shards []string
+	m := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !m.After(to.UTC()) {
+		shards = append(shards, gsi1PKPrefix+m.Format("2006-01"))
+		m = m.AddDate(0, 1, 0)
+	}
+	return shards
+}
+
+func parseListLimit(v string) (int, bool) {
+	if v == "" {
+		return defaultListLimit, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxListLimit {
+		return 0, false
+	}
+	return n, true
+}
+
+// status / tag の FilterExpression（どちらも空なら expr は空）
+type listFilter struct {
+	expr   string
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func (f *listFilter) and(cond, key, value string) {
+	if f.expr != "" {
+		f.expr += " AND "
+	}
+	f.expr += cond
+	f.values[key] = &types.AttributeValueMemberS{Value: value}
+}
+
+func newListFilter(status, tag string) listFilter {
+	var conds []string
+	f := listFilter{values: map[string]types.AttributeValue{}}
+	if status != "" {
+		conds = append(conds, "#st = :st")
+		f.names = map[string]string{"#st": "status"}
+		f.values[":st"] = &types.AttributeValueMemberS{Value: status}
+	}
+	if tag != "" {
+		conds = append(conds, "contains(tags, :tag)")
+		f.values[":tag"] = &types.AttributeValueMemberS{Value: tag}
+	}
+	f.expr = strings.Join(conds, " AND ")
+	return f
+}
+
+// GET /admin/requests?limit=100&status=PENDING&tag=billing (admin only)
+// from/to を指定した場合は createdAt-index の範囲クエリ（cursorでページング）
+func (s *server) handleListRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeRead); !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit, ok := parseListLimit(q.Get("limit"))
+	if !ok {
+		httpError(w, r, "invalid limit", http.StatusBadRequest)
+		return
+	}
+	status := normalizeStatus(q.Get("status"))
+	if status != "" && !isValidStatus(status) {
+		httpError(w, r, "invalid status", http.StatusBadRequest)
+		return
+	}
+	f := newListFilter(status, q.Get("tag"))
+
+	if q.Get("from") != "" || q.Get("to") != "" {
+		s.listByCreatedAt(w, r, limit, f)
+		return
+	}
+
+	// 一覧はコスト優先で結果整合（GSIのQueryはそもそも強い整合性を選べない）
+	in := &dynamodb.ScanInput{
+		TableName:      aws.String(requestsTable),
+		Limit:          aws.Int32(int32(min(limit, defaultListLimit))),
+		ConsistentRead: aws.Bool(false),
+	}
+	// テーブルにはリクエスト以外の番兵item（TITLE#...）もあるのでREQ#だけに絞る
+	f.and("begins_with(PK, :reqPrefix)", ":reqPrefix", "REQ#")
+	in.FilterExpression = aws.String(f.expr)
+	in.ExpressionAttributeNames = f.names
+	in.ExpressionAttributeValues = f.values
+	p := dynamodb.NewScanPaginator(s.ddb, in)
+	streamJSONArray(w, r, scanSource(p), limit, func(item map[string]types.AttributeValue) any {
+		return summaryFromItem(item)
+	})
+}
+
+// 1ページ分だけ返し、続きがあれば X-Next-Cursor ヘッダに署名付きcursorを入れる。
+// 月シャードは期間が重ならないので、古い月から順に読んでつなげればcreatedAt順のマージと同じになる。
+// cursorはシャード内の続き（LastEvaluatedKey）か、次のシャードの先頭（GSI1PKだけ）
+func (s *server) listByCreatedAt(w http.ResponseWriter, r *http.Request, limit int, f listFilter) {
+	q := r.URL.Query()
+	from, errFrom := time.Parse(time.RFC3339, q.Get("from"))
+	to, errTo := time.Parse(time.RFC3339, q.Get("to"))
+	if errFrom != nil || errTo != nil {
+		httpError(w, r, "from and to must be RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		httpError(w, r, "from must be <= to", http.StatusBadRequest)
+		return
+	}
+
+	in := &dynamodb.QueryInput{
+		TableName:              aws.String(requestsTable),
+		IndexName:              aws.String(createdAtIndex),
+		KeyConditionExpression: aws.String("GSI1PK = :pk AND createdAt BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			// 保存値はUTCのRFC3339なので文字列比較で範囲になる
+			":from": &types.AttributeValueMemberS{Value: from.UTC().Format(time.RFC3339)},
+			":to":   &types.AttributeValueMemberS{Value: to.UTC().Format(time.RFC3339)},
+		},
+	}
+	if f.expr != "" {
+		in.FilterExpression = aws.String(f.expr)
+		in.ExpressionAttributeNames = f.names
+		maps.Copy(in.ExpressionAttributeValues, f.values)
+	}
+
+	shards := monthShards(from, to)
+	shard := 0
+	var startKey map[string]types.AttributeValue
+	if c := q.Get("cursor"); c != "" {
+		key, err := decodeCursor(s.cursorSecret, c)
+		if err != nil {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		shard = slices.Index(shards, decodeRequestItem(key).GSI1PK)
+		if shard < 0 {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if len(key) > 1 {
+			startKey = key
+		}
+	}
+
+	list := make([]AdminRequestSummary, 0, limit)
+	for shard < len(shards) && len(list) < limit {
+		in.ExpressionAttributeValues[":pk"] = &types.AttributeValueMemberS{Value: shards[shard]}
+		in.ExclusiveStartKey = startKey
+		in.Limit = aws.Int32(int32(limit - len(list)))
+		out, err := s.ddb.Query(r.Context(), in)
+		if err != nil {
+			var ve *types.ResourceNotFoundException
+			if errors.As(err, &ve) {
+				httpError(w, r, "index not found (run make infra-apply)", http.StatusInternalServerError)
+				return
+			}
+			httpError(w, r, "failed to query", http.StatusInternalServerError)
+			return
+		}
+		for _, item := range out.Items {
+			list = append(list, summaryFromItem(item))
+		}
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			shard++
+		}
+	}
+
+	next := startKey
+	if len(next) == 0 && shard < len(shards) {
+		next = map[string]types.AttributeValue{"GSI1PK": &types.AttributeValueMemberS{Value: shards[shard]}}
+	}
+	if c := encodeCursor(s.cursorSecret, next); c != "" {
+		w.Header().Set("X-Next-Cursor", c)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, list)
+}
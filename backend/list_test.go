@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseListLimit(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"", defaultListLimit, true},
+		{"1", 1, true},
+		{"1000", maxListLimit, true},
+		{"1001", 0, false},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"ten", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseListLimit(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseListLimit(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
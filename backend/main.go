@@ -6,13 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
@@ -26,32 +27,65 @@ const (
 )
 
 type CreateRequestInput struct {
-	Title string `json:"title"`
+	RequestID string   `json:"requestId,omitempty"` // 省略時はUUIDを採番
+	Title     string   `json:"title"`
+	Tags      []string `json:"tags,omitempty"`
+	// 任意。あればworkerがステータス変更をSESでメール通知する（SES_SENDER 設定時）
+	RequesterEmail string `json:"requesterEmail,omitempty"`
 }
 
 type CreateRequestOutput struct {
-	RequestID   string `json:"requestId"`
-	Title       string `json:"title"`
-	CreatedAt   string `json:"createdAt"`
-	TrackingURL string `json:"trackingUrl"`
+	RequestID   string   `json:"requestId"`
+	DisplayID   string   `json:"displayId,omitempty"` // DISPLAY_IDS=true のときの REQ-00001 形式の連番
+	Title       string   `json:"title"`
+	Status      string   `json:"status"`
+	Tags        []string `json:"tags"`
+	CreatedAt   string   `json:"createdAt"`
+	TrackingURL string   `json:"trackingUrl"`
+	// 作成直後は1。ETagヘッダと同じ値なので、GETし直さずにそのまま更新へ進める
+	Version int `json:"version"`
+	// RETURN_REQUESTER_TOKEN=true のときだけ。trackingUrlと同じ値を単独でも返す
+	RequesterToken string `json:"requesterToken,omitempty"`
 }
 
 type GetRequestOutput struct {
-	RequestID string `json:"requestId"`
-	Title     string `json:"title"`
-	Status    string `json:"status"`
-	CreatedAt string `json:"createdAt"`
+	RequestID string   `json:"requestId"`
+	DisplayID string   `json:"displayId,omitempty"`
+	Title     string   `json:"title"`
+	Status    string   `json:"status"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"createdAt"`
+	// 直近のステータス変更の理由（REJECTEDの理由などを依頼者に見せる）
+	StatusReason string `json:"statusReason,omitempty"`
 }
 
 type PatchStatusInput struct {
 	Status string `json:"status"`
+	Force  bool   `json:"force"` // 同じステータスでも書き込んでイベントを出す（意図的な再入）
+	Reason string `json:"reason,omitempty"`
 }
 
+// 更新後のitem全体（requesterTokenは除く）。newStatus/changedAtは従来のクライアント向けに残す
 type PatchStatusOutput struct {
-	RequestID string `json:"requestId"`
-	NewStatus string `json:"newStatus"`
-	ChangedAt string `json:"changedAt"`
-	EventID   string `json:"eventId"`
+	RequestID       string   `json:"requestId"`
+	DisplayID       string   `json:"displayId,omitempty"`
+	Title           string   `json:"title"`
+	Status          string   `json:"status"`
+	Tags            []string `json:"tags"`
+	Assignee        string   `json:"assignee,omitempty"`
+	Priority        *int     `json:"priority,omitempty"`
+	DueAt           string   `json:"dueAt,omitempty"`
+	Overdue         bool     `json:"overdue,omitempty"`
+	CreatedAt       string   `json:"createdAt"`
+	StatusUpdatedAt string   `json:"statusUpdatedAt"`
+	StatusChangedBy string   `json:"statusChangedBy"`
+	StatusReason    string   `json:"statusReason,omitempty"`
+	Version         int      `json:"version"`
+	NewStatus       string   `json:"newStatus"`
+	ChangedAt       string   `json:"changedAt"`
+	EventID         string   `json:"eventId,omitempty"`
+	Note            string   `json:"note,omitempty"`
+	Noop            bool     `json:"noop,omitempty"` // 既に同じステータスだったので何も書いていない
 }
 
 type StatusChangedEvent struct {
@@ -59,37 +93,52 @@ type StatusChangedEvent struct {
 	RequestID string `json:"requestId"`
 	NewStatus string `json:"newStatus"`
 	ChangedAt string `json:"changedAt"`
+	ChangedBy string `json:"changedBy,omitempty"` // "admin" / "requester"
+	Reason    string `json:"reason,omitempty"`
+	// 送信時に eventSchemaVersion を入れる。形を変えたら上げてworker側に移行処理を足す
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+	// EVENT_MAX_AGE があれば送信時に入れる（RFC3339）。過ぎたイベントはworkerが適用せずに捨てる（eventDeadline）
+	Deadline string `json:"deadline,omitempty"`
+	// 管理者のreplayで再投入した通知だけのイベント。workerは履歴を追記しない
+	Replay bool `json:"replay,omitempty"`
+}
+
+type server struct {
+	ddb             *dynamodb.Client
+	sqs             *sqs.Client
+	queueURL        string
+	bulkMaxItems    int
+	bulkConcurrency int
+	cursorSecret    []byte
+	queueStats      *queueStatsCache
+	config          Config
 }
 
+// DYNAMODB_ENDPOINT が空なら実AWSのエンドポイントを使う
 func newDynamoClient(ctx context.Context) (*dynamodb.Client, error) {
 	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
-	if endpoint == "" {
-		return nil, fmt.Errorf("DYNAMODB_ENDPOINT is required")
-	}
-
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(os.Getenv("AWS_REGION")),
-	)
+	cfg, err := loadAWSConfig(ctx, endpoint)
 	if err != nil {
 		return nil, err
 	}
-
 	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
 	}), nil
 }
 
+// SQS_ENDPOINT が空なら実AWSのエンドポイントを使う
 func newSQSClient(ctx context.Context) (*sqs.Client, error) {
 	endpoint := os.Getenv("SQS_ENDPOINT")
-	if endpoint == "" {
-		return nil, fmt.Errorf("SQS_ENDPOINT is required")
-	}
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	cfg, err := loadAWSConfig(ctx, endpoint)
 	if err != nil {
 		return nil, err
 	}
 	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
 	}), nil
 }
 
@@ -106,14 +155,6 @@ func resolveQueueURL(ctx context.Context, c *sqs.Client) (string, error) {
     return aws.ToString(out.QueueUrl), nil
 }
 
-func getStringAttr(item map[string]types.AttributeValue, key string) (string, bool) {
-	v, ok := item[key].(*types.AttributeValueMemberS)
-	if !ok {
-		return "", false
-	}
-	return v.Value, true
-}
-
 func main() {
 
 	if os.Getenv("APP_ENV") != "production" {
@@ -121,228 +162,540 @@ func main() {
 	}
 	ctx := context.Background()
 
+	var err error
+	adminTokenSecret, err = newSecret("ADMIN_TOKEN")
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloadSecretsOnSIGHUP(adminTokenSecret)
+
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	initialStatus = config.DefaultStatus
+	titleValidators, err = loadTitleValidators()
+	if err != nil {
+		log.Fatal(err)
+	}
+	requestCache = loadReadCache()
+	getRateLimiter = loadRateLimiter("GET")
+	createRateLimiter = loadRateLimiter("CREATE")
+
 	ddb, err := newDynamoClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := checkTableWithRetry(ctx, ddb); err != nil {
+		log.Fatal(err)
+	}
+
 	sqsClient, err := newSQSClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	queueURL, err := resolveQueueURL(ctx, sqsClient)
+	queueURL, err := resolveQueueURLWithRetry(ctx, sqsClient)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	srv := &server{
+		ddb:             ddb,
+		sqs:             sqsClient,
+		queueURL:        queueURL,
+		bulkMaxItems:    config.BulkMaxItems,
+		bulkConcurrency: config.BulkConcurrency,
+		cursorSecret:    loadCursorSecret(),
+		queueStats:      &queueStatsCache{ttl: config.QueueStatsCacheTTL},
+		config:          config,
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, r, http.MethodGet)
 			return
 		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		fmt.Fprintln(w, "ok")
 	})
 
-	mux.HandleFunc("/requests", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	mux.HandleFunc("/ready", handleReady)
+	mux.HandleFunc("/status", srv.handleSystemStatus)
 
-		var in CreateRequestInput
-		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
-		}
-		if in.Title == "" {
-			http.Error(w, "title required", http.StatusBadRequest)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-
-		createdAt := time.Now().UTC().Format(time.RFC3339)
-		out := CreateRequestOutput{
-			RequestID: uuid.NewString(),
-			Title:     in.Title,
-			CreatedAt: createdAt,
-		}
-
-		requesterToken := uuid.NewString()
-
-		base := os.Getenv("APP_PUBLIC_BASE_URL")
-		if base == "" {
-			base = "http://localhost:8080"
-		}
-		base = strings.TrimRight(base, "/")
-		out.TrackingURL = fmt.Sprintf("%s/requests/%s?t=%s", base, out.RequestID, requesterToken)
-
-		pk := "REQ#" + out.RequestID
-		reqCtx := r.Context()
-		_, err = ddb.PutItem(reqCtx, &dynamodb.PutItemInput{
-			TableName: aws.String("Requests"),
-			Item: map[string]types.AttributeValue{
-				"PK":             &types.AttributeValueMemberS{Value: pk},
-				"title":          &types.AttributeValueMemberS{Value: out.Title},
-				"status":         &types.AttributeValueMemberS{Value: "PENDING"},
-				"createdAt":      &types.AttributeValueMemberS{Value: createdAt},
-				"requesterToken": &types.AttributeValueMemberS{Value: requesterToken},
-			},
-		})
-		if err != nil {
-			http.Error(w, "failed to persist request", http.StatusInternalServerError)
+	mux.HandleFunc("/requests", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			srv.handleOwnerRequests(w, r)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(out); err != nil {
-			http.Error(w, "failed to write response", http.StatusInternalServerError)
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 			return
 		}
+		srv.handleCreateRequest(w, r)
 	})
 	
+	mux.HandleFunc("/requests/batch-get", srv.handleBatchGet)
+
 	mux.HandleFunc("/requests/", func(w http.ResponseWriter, r *http.Request) {
 		// /requests/{id} or /requests/{id}/status
 		rest := strings.TrimPrefix(r.URL.Path, "/requests/")
 		rest = strings.Trim(rest, "/")
 		parts := strings.Split(rest, "/")
 		if len(parts) == 0 || parts[0] == "" {
-			http.NotFound(w, r)
+			notFound(w, r)
 			return
 		}
 		id := parts[0]
 		pk := "REQ#" + id
 
-		// ===== GET /requests/{id}?t=... =====
-		if len(parts) == 1 && r.Method == http.MethodGet {
-			t := r.URL.Query().Get("t")
-			if t == "" {
-				http.Error(w, "token required", http.StatusBadRequest)
+		// ===== HEAD /requests/{id}?t=... =====
+		// 追跡リンクが有効かだけを見る（一致200 / 不一致403 / なし404、本文なし）。読むのはrequesterTokenだけ
+		if len(parts) == 1 && r.Method == http.MethodHead {
+			t, ok := requireRequesterToken(w, r)
+			if !ok {
 				return
 			}
-
-			out, err := ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
-				TableName:      aws.String("Requests"),
-				Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: pk}},
-				ConsistentRead: aws.Bool(true),
-			})
-			if err != nil {
-				http.Error(w, "failed to read", http.StatusInternalServerError)
+			if !getRateLimiter.allow(w, r, t, "too many requests for this tracking link") {
 				return
 			}
-			if len(out.Item) == 0 {
-				http.Error(w, "not found", http.StatusNotFound)
+			if _, err := getRequesterItemProjected(r.Context(), ddb, id, t, wantConsistentRead(r), []string{"requesterToken"}); err != nil {
+				writeRequesterItemError(w, r, err)
 				return
 			}
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-			stored, ok := getStringAttr(out.Item, "requesterToken")
+		// ===== GET /requests/{id}?t=... =====
+		if len(parts) == 1 && r.Method == http.MethodGet {
+			t, ok := requireRequesterToken(w, r)
 			if !ok {
-				http.Error(w, "corrupt item", http.StatusInternalServerError)
 				return
 			}
-			if stored != t {
-				http.Error(w, "forbidden", http.StatusForbidden)
+			if !getRateLimiter.allow(w, r, t, "too many requests for this tracking link") {
 				return
 			}
 
-			title, _ := getStringAttr(out.Item, "title")
-			status, _ := getStringAttr(out.Item, "status")
-			createdAt, _ := getStringAttr(out.Item, "createdAt")
+			// ?fields= はJSONのときだけ（HTMLの追跡ページは全フィールドを使う）
+			var fields []string
+			if !prefersHTML(r) {
+				var err error
+				if fields, err = parseFields(r); err != nil {
+					httpError(w, r, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			item, err := getRequesterItemProjected(r.Context(), ddb, id, t, wantConsistentRead(r), fieldAttrs(fields))
+			if err != nil {
+				writeRequesterItemError(w, r, err)
+				return
+			}
+			if fields != nil {
+				w.Header().Add("Vary", "Accept")
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				writeJSON(w, r, projectRequestOutput(id, item, fields))
+				return
+			}
+
+			out := getRequestOutputFromItem(id, item)
 
+			// trackingUrlをブラウザで開いた場合はHTMLで返す
+			w.Header().Add("Vary", "Accept")
+			if prefersHTML(r) {
+				renderTrackingPage(w, out)
+				return
+			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(GetRequestOutput{
-				RequestID: id,
-				Title:     title,
-				Status:    status,
-				CreatedAt: createdAt,
-			})
+			writeJSON(w, r, out)
+			return
+		}
+
+		// ===== GET /requests/{id}/history?t=... =====
+		if len(parts) == 2 && parts[1] == "history" && r.Method == http.MethodGet {
+			srv.handleHistory(w, r, id)
+			return
+		}
+
+		// ===== POST /requests/{id}/cancel?t=... (requester) =====
+		if len(parts) == 2 && parts[1] == "cancel" && r.Method == http.MethodPost {
+			srv.handleCancel(w, r, id)
+			return
+		}
+
+		// ===== PATCH /requests/{id}/tags (admin only) =====
+		if len(parts) == 2 && parts[1] == "tags" && r.Method == http.MethodPatch {
+			srv.handlePatchTags(w, r, id)
+			return
+		}
+
+		// ===== PATCH /requests/{id}/assignee (admin only) =====
+		if len(parts) == 2 && parts[1] == "assignee" && r.Method == http.MethodPatch {
+			srv.handlePatchAssignee(w, r, id)
+			return
+		}
+
+		// ===== PATCH /requests/{id}/priority (admin only) =====
+		if len(parts) == 2 && parts[1] == "priority" && r.Method == http.MethodPatch {
+			srv.handlePatchPriority(w, r, id)
 			return
 		}
 
 		// ===== PATCH /requests/{id}/status (admin only) =====
 		if len(parts) == 2 && parts[1] == "status" && r.Method == http.MethodPatch {
-			expected := os.Getenv("ADMIN_TOKEN")
-			if expected == "" {
-				expected = "dev-admin-token"
-			}
-			if r.Header.Get("Authorization") != "Bearer "+expected {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			if _, ok := requireScope(w, r, scopeWrite); !ok {
 				return
 			}
 
 			var in PatchStatusInput
 			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-				http.Error(w, "bad json", http.StatusBadRequest)
+				httpError(w, r, "bad json", http.StatusBadRequest)
+				return
+			}
+			in.Status = normalizeStatus(in.Status)
+			if !isValidStatus(in.Status) {
+				httpError(w, r, "invalid status", http.StatusBadRequest)
 				return
 			}
-			switch in.Status {
-			case "PENDING", "IN_PROGRESS", "DONE", "REJECTED":
-			default:
-				http.Error(w, "invalid status", http.StatusBadRequest)
+			in.Reason = strings.TrimSpace(in.Reason)
+			if err := validateStatusReason(in.Status, in.Reason); err != nil {
+				httpError(w, r, err.Error(), http.StatusBadRequest)
 				return
 			}
 
 			changedAt := time.Now().UTC().Format(time.RFC3339)
 			eventID := uuid.NewString()
+			ev := StatusChangedEvent{
+				EventID:   eventID,
+				RequestID: id,
+				NewStatus: in.Status,
+				ChangedAt: changedAt,
+				ChangedBy: changedByAdmin,
+				Reason:    in.Reason,
+			}
 
-			// DynamoDB更新（存在しないIDなら404にしたいのでCondition入れる）
-			_, err := ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
+			// DynamoDB更新（存在しないIDなら404にしたいのでCondition入れる）。
+			// ALL_NEWで更新後のitemをそのまま返す（クライアントがGETし直さなくて済むように）
+			// 現在と同じステータスなら書かない（force=trueのときだけ再入として書く）
+			expr, values := statusUpdate(ev)
+			cond := "attribute_exists(PK) AND #st <> :s"
+			if in.Force {
+				cond = "attribute_exists(PK)"
+			}
+			upd, err := ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
 				TableName: aws.String("Requests"),
 				Key: map[string]types.AttributeValue{
 					"PK": &types.AttributeValueMemberS{Value: pk},
 				},
-				UpdateExpression: aws.String("SET #st = :s, statusUpdatedAt = :t"),
+				UpdateExpression: aws.String(expr),
 				ExpressionAttributeNames: map[string]string{
 					"#st": "status",
 				},
-				ExpressionAttributeValues: map[string]types.AttributeValue{
-					":s": &types.AttributeValueMemberS{Value: in.Status},
-					":t": &types.AttributeValueMemberS{Value: changedAt},
-				},
-				ConditionExpression: aws.String("attribute_exists(PK)"),
+				ExpressionAttributeValues: values,
+				ConditionExpression:       aws.String(cond),
+				ReturnValues:              types.ReturnValueAllNew,
+				// 条件失敗時に今の値を見て「存在しない」か「同じステータス」かを判別する
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 			})
+			requestCache.invalidate(id)
 			if err != nil {
-				var cfe *types.ConditionalCheckFailedException
-				if errors.As(err, &cfe) {
-					http.Error(w, "not found", http.StatusNotFound)
+				var ce *conditionError
+				if err = translateDynamoErr(err); errors.As(err, &ce) {
+					// ダブルクリック等で同じステータスを2回送った → 書き込みもイベントもなしで200
+					out := patchStatusOutputFromItem(ce.Item)
+					out.RequestID = id
+					out.NewStatus = out.Status
+					out.ChangedAt = out.StatusUpdatedAt
+					out.Noop = true
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.Header().Set("ETag", etagFor(out.Version))
+					writeJSON(w, r, out)
 					return
 				}
-				http.Error(w, "failed to update", http.StatusInternalServerError)
+				writeStoreError(w, r, err, "failed to update")
 				return
 			}
 
 			// SQSへイベント投入（workerが拾って履歴/通知済み等を更新する想定）
-			ev := StatusChangedEvent{
-				EventID:   eventID,
-				RequestID: id,
-				NewStatus: in.Status,
-				ChangedAt: changedAt,
-			}
-			body, _ := json.Marshal(ev)
-			_, err = sqsClient.SendMessage(r.Context(), &sqs.SendMessageInput{
-				QueueUrl:    aws.String(queueURL),
-				MessageBody: aws.String(string(body)),
-			})
+			// 送信失敗でもステータスは変わっているので500にはしない（pendingEventsに残して202）
+			pending, err := srv.enqueueOrMarkPending(r.Context(), ev)
 			if err != nil {
-				http.Error(w, "failed to enqueue", http.StatusInternalServerError)
+				httpError(w, r, "failed to enqueue", http.StatusInternalServerError)
 				return
 			}
 
+			out := patchStatusOutputFromItem(upd.Attributes)
+			out.RequestID = id
+			out.NewStatus = in.Status
+			out.ChangedAt = changedAt
+			out.EventID = eventID
+
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(PatchStatusOutput{
-				RequestID: id,
-				NewStatus: in.Status,
-				ChangedAt: changedAt,
-				EventID:   eventID,
-			})
+			w.Header().Set("ETag", etagFor(out.Version))
+			if pending {
+				out.Note = eventDelayedNote
+				w.WriteHeader(http.StatusAccepted)
+			}
+			writeJSON(w, r, out)
 			return
 		}
 
-		http.NotFound(w, r)
+		sub := ""
+		if len(parts) == 2 {
+			sub = parts[1]
+		}
+		if len(parts) > 2 {
+			notFound(w, r)
+			return
+		}
+		subrouteFallback(w, r, requestSubrouteMethods, sub)
 	})
 
-	addr := ":8080"
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/requests", srv.handleListRequests)
+	mux.HandleFunc("/admin/requests/bulk-status", srv.handleBulkStatus)
+	mux.HandleFunc("/admin/requests/mine", srv.handleMyRequests)
+	mux.HandleFunc("/admin/requests/overdue", srv.handleOverdueRequests)
+	mux.HandleFunc("/admin/requests/export", srv.handleExport)
+	mux.HandleFunc("/admin/requests/history/batch", srv.handleHistoryBatch)
+	mux.HandleFunc("/admin/requests/", srv.handleAdminRequest)
+	mux.HandleFunc("/admin/maintenance/purge-queue", srv.handlePurgeQueue)
+	mux.HandleFunc("/admin/queue/stats", srv.handleQueueStats)
+	mux.HandleFunc("/admin/config", srv.handleAdminConfig)
+
+	startPprofServer()
+
+	accessLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	shutdownTracing, err := initTracing(ctx, "backend")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	timeouts := handlerTimeouts{def: config.HandlerTimeout, routes: config.HandlerTimeouts}
+	disabledRoutes := make(map[string]bool, len(config.DisabledRoutes))
+	for _, name := range config.DisabledRoutes {
+		disabledRoutes[name] = true
+	}
+	handler := withTracing(withRequestID(withAccessLog(accessLogger, withForceHTTPS(withCORS(withGzip(withRouteFlags(disabledRoutes, mux,
+		withBodyLimit(withJSONContentType(withDependencyGuard(withHandlerTimeout(timeouts, mux)))))))))))
+
+	certFile, keyFile, useTLS, err := loadTLSFiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addr := config.ListenAddr
+	httpServer := &http.Server{Addr: addr, Handler: handler}
+	if useTLS {
+		// ListenAndServeTLS はALPNでHTTP/2も話す
+		log.Printf("listening on %s (TLS, HTTP/2)", addr)
+		log.Fatal(httpServer.ListenAndServeTLS(certFile, keyFile))
+	}
 	log.Printf("listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, mux))
+	log.Fatal(httpServer.ListenAndServe())
+}
+
+// POST /requests
+func (s *server) handleCreateRequest(w http.ResponseWriter, r *http.Request) {
+	var in CreateRequestInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	if in.Title == "" {
+		httpError(w, r, "title required", http.StatusBadRequest)
+		return
+	}
+	if err := validateTitle(in.Title); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.RequestID != "" && !validRequestID(in.RequestID) {
+		httpError(w, r, "invalid requestId (allowed: [A-Za-z0-9_-], 1-64 chars)", http.StatusBadRequest)
+		return
+	}
+	in.RequesterEmail = strings.TrimSpace(in.RequesterEmail)
+	if in.RequesterEmail != "" && !validRequesterEmail(in.RequesterEmail) {
+		httpError(w, r, "invalid requesterEmail", http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeTags(in.Tags)
+	if err != nil || len(tags) > maxTagsPerRequest {
+		httpError(w, r, "invalid tags (max 20, each 1-50 chars)", http.StatusBadRequest)
+		return
+	}
+	sort.Strings(tags)
+	ownerKey, err := ownerKeyFrom(r)
+	if err != nil {
+		httpError(w, r, "X-Requester-Key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// MAX_OPEN_REQUESTS_PER_REQUESTER（0=無制限）。GSIの件数なので同時作成で数件超えることはある
+	requesterKey := requesterKeyFrom(r)
+	if !createRateLimiter.allow(w, r, requesterKey, "too many requests created, slow down") {
+		return
+	}
+	if maxOpen := envInt("MAX_OPEN_REQUESTS_PER_REQUESTER", 0); maxOpen > 0 {
+		n, err := countOpenRequests(r.Context(), s.ddb, requesterKey)
+		if err != nil {
+			httpError(w, r, "failed to check open requests", http.StatusInternalServerError)
+			return
+		}
+		// 枠が空くのは既存の依頼が終端になったときなので、トークンバケットと違って補充時刻は計算できない。
+		// 目安として OPEN_REQUESTS_RETRY_AFTER（既定60s）
+		if n >= maxOpen {
+			tooManyRequests(w, r, fmt.Sprintf("too many open requests (max %d)", maxOpen),
+				envDuration("OPEN_REQUESTS_RETRY_AFTER", time.Minute))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	if in.RequestID == "" {
+		in.RequestID = uuid.NewString()
+	}
+	out := CreateRequestOutput{
+		RequestID: in.RequestID,
+		Title:     in.Title,
+		Status:    initialStatus,
+		Tags:      tags,
+		CreatedAt: createdAt,
+		Version:   1,
+	}
+
+	requesterToken := uuid.NewString()
+
+	out.TrackingURL = fmt.Sprintf("%s/requests/%s?t=%s", publicBaseURL(r), out.RequestID, requesterToken)
+	if envBool("RETURN_REQUESTER_TOKEN") {
+		out.RequesterToken = requesterToken
+	}
+
+	reqCtx := r.Context()
+
+	// DEDUP_WINDOW: 同じ依頼者・同じタイトルの二重送信は作成せず既存を200で返す
+	persisted := false
+	if window := dedupWindow(); window > 0 {
+		if identity := dedupIdentity(r); identity != "" {
+			pk := dedupSentinelPK(identity, out.Title)
+			existingID, err := claimDedup(reqCtx, s.ddb, pk, out.RequestID, window)
+			if err != nil {
+				writeStoreError(w, r, err, "failed to persist request")
+				return
+			}
+			if existingID != "" {
+				existing, err := dedupExistingOutput(reqCtx, s.ddb, r, existingID)
+				if errors.Is(err, errDedupInFlight) {
+					setRetryAfter(w, time.Second)
+					httpError(w, r, err.Error(), http.StatusConflict)
+					return
+				}
+				if err != nil {
+					writeStoreError(w, r, err, "failed to read")
+					return
+				}
+				w.Header().Set("Location", "/requests/"+existingID)
+				w.Header().Set("X-Deduplicated", "true")
+				w.Header().Set("ETag", etagFor(existing.Version))
+				writeJSON(w, r, existing)
+				return
+			}
+			defer func() {
+				if !persisted {
+					releaseDedup(context.WithoutCancel(reqCtx), s.ddb, pk, out.RequestID)
+				}
+			}()
+		}
+	}
+
+	// 二重送信で番号を無駄にしないよう、dedupの判定が済んでから採番する
+	if displayIDsEnabled() {
+		out.DisplayID, err = nextDisplayID(reqCtx, s.ddb)
+		if err != nil {
+			writeStoreError(w, r, err, "failed to allocate display id")
+			return
+		}
+	}
+
+	// ownerKey（依頼者キーがないとき）と空のtagsはomitemptyで属性ごと書かない
+	item, err := marshalRequestItem(requestItem{
+		PK:             requestPKPrefix + out.RequestID,
+		Title:          out.Title,
+		Status:         out.Status,
+		InitialStatus:  out.Status,
+		CreatedAt:      createdAt,
+		RequesterToken: requesterToken,
+		GSI1PK:         gsi1PKFor(createdAt),
+		RequesterKey:   requesterKey,
+		OwnerKey:       ownerKey,
+		RequesterEmail: in.RequesterEmail,
+		DisplayID:      out.DisplayID,
+		Tags:           tags,
+		Version:        out.Version,
+	})
+	if err != nil {
+		httpError(w, r, "failed to persist request", http.StatusInternalServerError)
+		return
+	}
+
+	if envBool("FORBID_DUPLICATE_TITLES") {
+		existingID, err := putRequestUniqueTitle(reqCtx, s.ddb, item, out.RequestID, out.Title)
+		if errors.Is(err, errDuplicateTitle) {
+			w.WriteHeader(http.StatusConflict)
+			writeJSON(w, r, map[string]string{
+				"error":             "an open request with this title already exists",
+				"existingRequestId": existingID,
+			})
+			return
+		}
+		if errors.Is(err, errRequestIDExists) {
+			httpError(w, r, "requestId already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			writeStoreError(w, r, err, "failed to persist request")
+			return
+		}
+	} else {
+		// クライアント指定のIDが既存と衝突したら上書きせずに409
+		_, err = s.ddb.PutItem(reqCtx, &dynamodb.PutItemInput{
+			TableName:           aws.String("Requests"),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		})
+		var cfe *types.ConditionalCheckFailedException
+		if errors.As(err, &cfe) {
+			httpError(w, r, "requestId already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			writeStoreError(w, r, err, "failed to persist request")
+			return
+		}
+	}
+	persisted = true
+	// 作成イベントは通知用なので、送れなくても作成自体は成功として返す
+	if envBool("EMIT_CREATED_EVENTS") {
+		cev := RequestCreatedEvent{
+			EventID:   uuid.NewString(),
+			RequestID: out.RequestID,
+			Status:    out.Status,
+			CreatedAt: createdAt,
+		}
+		if err := enqueueRequestCreated(reqCtx, s.sqs, s.queueURL, cev); err != nil {
+			observeDependencyError(sqsHealth, err)
+			log.Printf("enqueue created event error: %v requestId=%s", err, out.RequestID)
+		}
+	}
+	w.Header().Set("Location", "/requests/"+out.RequestID)
+	w.Header().Set("ETag", etagFor(out.Version))
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, out)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+type PurgeQueueOutput struct {
+	QueueURL string `json:"queueUrl"`
+	PurgedAt string `json:"purgedAt"`
+}
+
+// POST /admin/maintenance/purge-queue (admin scope)
+// Lab用のリセット。ALLOW_DESTRUCTIVE_OPS=true のときだけ有効
+func (s *server) handlePurgeQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	t, ok := requireScope(w, r, scopeAdmin)
+	if !ok {
+		return
+	}
+	if !envBool("ALLOW_DESTRUCTIVE_OPS") {
+		httpError(w, r, "destructive operations are disabled (set ALLOW_DESTRUCTIVE_OPS=true)", http.StatusForbidden)
+		return
+	}
+
+	_, err := s.sqs.PurgeQueue(r.Context(), &sqs.PurgeQueueInput{
+		QueueUrl: aws.String(s.queueURL),
+	})
+	if err != nil {
+		// SQSは60秒に1回しかPurgeできない
+		var inProgress *sqstypes.PurgeQueueInProgress
+		if errors.As(err, &inProgress) {
+			httpError(w, r, "purge already in progress (retry after 60s)", http.StatusConflict)
+			return
+		}
+		httpError(w, r, "failed to purge queue", http.StatusInternalServerError)
+		return
+	}
+
+	purgedAt := time.Now().UTC().Format(time.RFC3339)
+	log.Printf("audit: queue purged queue=%s by=%q requestId=%s", s.queueURL, t.Label, requestIDFrom(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, PurgeQueueOutput{
+		QueueURL: s.queueURL,
+		PurgedAt: purgedAt,
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type errorOutput struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	writeJSON(w, r, errorOutput{Error: message, Code: code})
+}
+
+// http.Error の代わり（エラーはすべてJSONで返す）。codeはステータスから決める（409 → "conflict"）
+func httpError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	writeJSONError(w, r, status, errorCode(status), message)
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusRequestEntityTooLarge:
+		return "body_too_large"
+	case http.StatusInternalServerError:
+		return "internal_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	if t := http.StatusText(status); t != "" {
+		return strings.ToLower(strings.ReplaceAll(t, " ", "_"))
+	}
+	return "error"
+}
+
+// どのルートにも一致しないパス
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, r, http.StatusNotFound, "not_found", "not found")
+}
+
+// パスは存在するがメソッドが違う。Allowに受け付けるメソッドを並べる
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSONError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+}
+
+// 429はすべてこれで返す（JSON本文 + Retry-After）。retryAfterは呼び出し側の制限ごとに決める
+func tooManyRequests(w http.ResponseWriter, r *http.Request, message string, retryAfter time.Duration) {
+	logRejection(r, rejectRateLimited)
+	setRetryAfter(w, retryAfter)
+	writeJSONError(w, r, http.StatusTooManyRequests, "rate_limited", message)
+}
+
+// /requests/{id}/... と /admin/requests/{id}/... のサブルートごとのメソッド
+var requestSubrouteMethods = map[string][]string{
+	"":         {http.MethodGet, http.MethodHead},
+	"history":  {http.MethodGet},
+	"cancel":   {http.MethodPost},
+	"tags":     {http.MethodPatch},
+	"assignee": {http.MethodPatch},
+	"priority": {http.MethodPatch},
+	"status":   {http.MethodPatch},
+}
+
+var adminSubrouteMethods = map[string][]string{
+	"replay":          {http.MethodPost},
+	"timeline":        {http.MethodGet},
+	"raw":             {http.MethodGet},
+	"rebuild-history": {http.MethodPost},
+}
+
+// サブルートが存在すれば405、なければ404
+func subrouteFallback(w http.ResponseWriter, r *http.Request, methods map[string][]string, sub string) {
+	if m, ok := methods[sub]; ok {
+		methodNotAllowed(w, r, m...)
+		return
+	}
+	notFound(w, r)
+}
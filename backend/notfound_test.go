@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeErrorBody(t *testing.T, w *httptest.ResponseRecorder) errorOutput {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var out errorOutput
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("body is not JSON: %q", w.Body.String())
+	}
+	return out
+}
+
+func TestUnknownPathsAndWrongMethods(t *testing.T) {
+	s := &server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/requests", s.handleListRequests)
+	mux.HandleFunc("/admin/requests/overdue", s.handleOverdueRequests)
+	mux.HandleFunc("/requests/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/requests/"), "/"), "/")
+		sub := ""
+		if len(parts) == 2 {
+			sub = parts[1]
+		}
+		subrouteFallback(w, r, requestSubrouteMethods, sub)
+	})
+
+	tests := []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantError string
+		wantAllow string
+	}{
+		{http.MethodGet, "/nope", http.StatusNotFound, "not_found", ""},
+		{http.MethodPost, "/admin/nothing/here", http.StatusNotFound, "not_found", ""},
+		{http.MethodGet, "/requests/r1/bogus", http.StatusNotFound, "not_found", ""},
+		{http.MethodGet, "/requests/r1/cancel", http.StatusMethodNotAllowed, "method_not_allowed", "POST"},
+		{http.MethodPost, "/requests/r1/status", http.StatusMethodNotAllowed, "method_not_allowed", "PATCH"},
+		{http.MethodDelete, "/requests/r1", http.StatusMethodNotAllowed, "method_not_allowed", "GET, HEAD"},
+		{http.MethodPost, "/admin/requests", http.StatusMethodNotAllowed, "method_not_allowed", "GET"},
+		{http.MethodDelete, "/admin/requests/overdue", http.StatusMethodNotAllowed, "method_not_allowed", "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := decodeErrorBody(t, w).Code; got != tt.wantError {
+				t.Errorf("code = %q, want %q", got, tt.wantError)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorIsJSON(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusBadRequest, "bad_request"},
+		{http.StatusUnauthorized, "unauthorized"},
+		{http.StatusForbidden, "forbidden"},
+		{http.StatusConflict, "conflict"},
+		{http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{http.StatusRequestEntityTooLarge, "body_too_large"},
+		{http.StatusInternalServerError, "internal_error"},
+		{http.StatusServiceUnavailable, "unavailable"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		httpError(w, httptest.NewRequest(http.MethodGet, "/", nil), "something failed", tt.status)
+		out := decodeErrorBody(t, w)
+		if w.Code != tt.status || out.Code != tt.code || out.Error != "something failed" {
+			t.Errorf("httpError(%d) = %d %+v, want code %q", tt.status, w.Code, out, tt.code)
+		}
+	}
+}
+
+func TestHandlerTimeoutIsJSON(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	h := withHandlerTimeout(handlerTimeouts{def: 10 * time.Millisecond, routes: map[string]time.Duration{}}, mux)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if got := decodeErrorBody(t, w).Code; got != "timeout" {
+		t.Errorf("code = %q, want timeout", got)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const requesterIndex = "requester-index"
+
+// 依頼者の識別子。REQUESTER_IDENTITY=api_key なら X-Requester-Key、なければ（既定）接続元IP。
+// IPやキーをそのまま保存しないようハッシュ化して item の requesterKey に入れる
+func requesterKeyFrom(r *http.Request) string {
+	if os.Getenv("REQUESTER_IDENTITY") == "api_key" {
+		if k := r.Header.Get("X-Requester-Key"); k != "" {
+			return hashedKey("key", k)
+		}
+	}
+	return hashedKey("ip", clientIP(r))
+}
+
+func hashedKey(kind, v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return kind + ":" + hex.EncodeToString(sum[:16])
+}
+
+// requester-index で同じrequesterKeyの未完了（終端以外）件数を数える。
+// 終端に遷移したものは数えないので、減算のための書き込みは不要
+func countOpenRequests(ctx context.Context, ddb *dynamodb.Client, requesterKey string) (int, error) {
+	var terminal []string
+	values := map[string]types.AttributeValue{
+		":k": &types.AttributeValueMemberS{Value: requesterKey},
+	}
+	for s := range allowedTransitions {
+		if isTerminalStatus(s) {
+			key := fmt.Sprintf(":t%d", len(terminal))
+			terminal = append(terminal, key)
+			values[key] = &types.AttributeValueMemberS{Value: s}
+		}
+	}
+
+	p := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:                 aws.String(requestsTable),
+		IndexName:                 aws.String(requesterIndex),
+		KeyConditionExpression:    aws.String("requesterKey = :k"),
+		FilterExpression:          aws.String("NOT #st IN (" + strings.Join(terminal, ", ") + ")"),
+		ExpressionAttributeNames:  map[string]string{"#st": "status"},
+		ExpressionAttributeValues: values,
+		Select:                    types.SelectCount,
+	})
+	n := 0
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		n += int(page.Count)
+	}
+	return n, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const eventDelayedNote = "status updated; event delivery delayed and will be republished"
+
+// SQS送信に失敗したイベントをitemの pendingEvents（イベントJSONのString Set）に残す。
+// 再送は reconcile 側で pendingEvents を持つitemを拾って行う想定
+func markPendingEvent(ctx context.Context, ddb *dynamodb.Client, ev StatusChangedEvent) error {
+	ev.SchemaVersion = eventSchemaVersion
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + ev.RequestID},
+		},
+		UpdateExpression: aws.String("ADD pendingEvents :ev"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ev": &types.AttributeValueMemberSS{Value: []string{string(body)}},
+		},
+	})
+	return err
+}
+
+// DynamoDB更新後のenqueue。失敗してもステータスは既に変わっているので、
+// pendingEventsに記録できれば pending=true（呼び出し側は202）。記録もできなければerr
+func (s *server) enqueueOrMarkPending(ctx context.Context, ev StatusChangedEvent) (pending bool, err error) {
+	qerr := enqueueStatusChanged(ctx, s.sqs, s.queueURL, ev)
+	if qerr == nil {
+		return false, nil
+	}
+	return s.markPendingAfterEnqueueError(ctx, ev, qerr)
+}
+
+// 送信に失敗したイベントを pendingEvents に残す（バッチ送信の失敗分もここを通す）
+func (s *server) markPendingAfterEnqueueError(ctx context.Context, ev StatusChangedEvent, qerr error) (pending bool, err error) {
+	log.Printf("enqueue error: %v eventId=%s requestId=%s (marking pending)", qerr, ev.EventID, ev.RequestID)
+	observeDependencyError(sqsHealth, qerr)
+	if err := markPendingEvent(ctx, s.ddb, ev); err != nil {
+		log.Printf("mark pending error: %v eventId=%s requestId=%s", err, ev.EventID, ev.RequestID)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsOverdue(t *testing.T) {
+	due := "2024-05-01T09:00:00Z"
+	dueAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		dueAt  string
+		status string
+		now    time.Time
+		grace  time.Duration
+		want   bool
+	}{
+		{name: "exactly at dueAt", dueAt: due, status: "PENDING", now: dueAt, want: false},
+		{name: "just after dueAt", dueAt: due, status: "PENDING", now: dueAt.Add(time.Nanosecond), want: true},
+		{name: "before dueAt", dueAt: due, status: "PENDING", now: dueAt.Add(-time.Second), want: false},
+		{name: "inside grace", dueAt: due, status: "PENDING", now: dueAt.Add(4 * time.Minute), grace: 5 * time.Minute, want: false},
+		{name: "exactly at grace end", dueAt: due, status: "PENDING", now: dueAt.Add(5 * time.Minute), grace: 5 * time.Minute, want: false},
+		{name: "just after grace end", dueAt: due, status: "PENDING", now: dueAt.Add(5*time.Minute + time.Nanosecond), grace: 5 * time.Minute, want: true},
+		{name: "terminal status", dueAt: due, status: "DONE", now: dueAt.Add(time.Hour), want: false},
+		{name: "no dueAt", status: "PENDING", now: dueAt, want: false},
+		{name: "unparsable dueAt", dueAt: "tomorrow", status: "PENDING", now: dueAt, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOverdue(tt.dueAt, tt.status, tt.now, tt.grace); got != tt.want {
+				t.Errorf("isOverdue(%q, %q, %v, %v) = %v, want %v", tt.dueAt, tt.status, tt.now, tt.grace, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	ownerIndex = "owner-index"
+	// キーを知っていれば誰でも一覧できるので、推測されにくい長さを下限にする
+	minRequesterKeyLen = 32
+	maxRequesterKeyLen = 256
+)
+
+var (
+	errRequesterKeyTooShort = fmt.Errorf("key too short (min %d chars)", minRequesterKeyLen)
+	errRequesterKeyTooLong  = errors.New("key too long")
+)
+
+// 作成時と一覧時で同じ長さの制限をかける。エラーの文言はそのまま400の本文にする
+func validateRequesterKey(key string) error {
+	switch {
+	case len(key) < minRequesterKeyLen:
+		return errRequesterKeyTooShort
+	case len(key) > maxRequesterKeyLen:
+		return errRequesterKeyTooLong
+	}
+	return nil
+}
+
+// X-Requester-Key（依頼者が自分で決めるAPIキー）のハッシュ。生のキーは保存しない
+func ownerKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// 作成時: X-Requester-Key があれば ownerKey を付ける（なければ空）。長さが範囲外ならエラー
+func ownerKeyFrom(r *http.Request) (string, error) {
+	k := r.Header.Get("X-Requester-Key")
+	if k == "" {
+		return "", nil
+	}
+	if err := validateRequesterKey(k); err != nil {
+		return "", err
+	}
+	return ownerKeyHash(k), nil
+}
+
+// GET /requests?key=...（または X-Requester-Key ヘッダ）
+// そのキーで作成した依頼を新しい順に返す。requesterTokenは含めない
+func (s *server) handleOwnerRequests(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("X-Requester-Key")
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	if key == "" {
+		httpError(w, r, "key required", http.StatusBadRequest)
+		return
+	}
+	if err := validateRequesterKey(key); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, ok := parseListLimit(r.URL.Query().Get("limit"))
+	if !ok {
+		httpError(w, r, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	p := dynamodb.NewQueryPaginator(s.ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(requestsTable),
+		IndexName:              aws.String(ownerIndex),
+		KeyConditionExpression: aws.String("ownerKey = :k"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":k": &types.AttributeValueMemberS{Value: ownerKeyHash(key)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(int32(min(limit, defaultListLimit))),
+	})
+	streamJSONArray(w, r, querySource(p), limit, func(item map[string]types.AttributeValue) any {
+		sum := summaryFromItem(item)
+		return GetRequestOutput{
+			RequestID: sum.RequestID,
+			Title:     sum.Title,
+			Status:    sum.Status,
+			Tags:      sum.Tags,
+			CreatedAt: sum.CreatedAt,
+		}
+	})
+}
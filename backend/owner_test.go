@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 依頼者キーは作成時も一覧時も 32〜256 文字。範囲外は DynamoDB に触る前に400
+func TestRequesterKeyLength(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want int // 一覧の期待ステータス（作成は400か、それ以外なら通る）
+	}{
+		{name: "too short", key: strings.Repeat("k", minRequesterKeyLen-1), want: http.StatusBadRequest},
+		{name: "shortest allowed", key: strings.Repeat("k", minRequesterKeyLen), want: http.StatusOK},
+		{name: "longest allowed", key: strings.Repeat("k", maxRequesterKeyLen), want: http.StatusOK},
+		{name: "too long", key: strings.Repeat("k", maxRequesterKeyLen+1), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, err := ownerKeyFrom(withRequesterKey(httptest.NewRequest(http.MethodPost, "/requests", nil), tt.key))
+			if (err != nil) != (tt.want == http.StatusBadRequest) {
+				t.Errorf("ownerKeyFrom: err = %v", err)
+			}
+			if err == nil && owner != ownerKeyHash(tt.key) {
+				t.Errorf("ownerKeyFrom = %q, want the key hash", owner)
+			}
+
+			for _, viaQuery := range []bool{false, true} {
+				fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+					return map[string]any{"Items": []any{}}, nil
+				}}
+				srv := &server{ddb: fake.dynamoClient()}
+				r := httptest.NewRequest(http.MethodGet, "/requests", nil)
+				if viaQuery {
+					r.URL.RawQuery = url.Values{"key": {tt.key}}.Encode()
+				} else {
+					r = withRequesterKey(r, tt.key)
+				}
+				rec := httptest.NewRecorder()
+				srv.handleOwnerRequests(rec, r)
+				if rec.Code != tt.want {
+					t.Fatalf("list (query=%v): status = %d, want %d: %s", viaQuery, rec.Code, tt.want, rec.Body.String())
+				}
+				if tt.want == http.StatusBadRequest && len(fake.calls) != 0 {
+					t.Errorf("list (query=%v): queried DynamoDB with a rejected key", viaQuery)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRejectsShortRequesterKey(t *testing.T) {
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		t.Errorf("unexpected %s", op)
+		return nil, nil
+	}}
+	srv := &server{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), queueURL: "http://fake/queue"}
+	r := withRequesterKey(httptest.NewRequest(http.MethodPost, "/requests", strings.NewReader(`{"title":"laptop"}`)), "short")
+	rec := httptest.NewRecorder()
+	srv.handleCreateRequest(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	if got := decodeErrorBody(t, rec).Error; !strings.Contains(got, "too short") {
+		t.Errorf("error = %q, want it to mention the minimum length", got)
+	}
+}
+
+func withRequesterKey(r *http.Request, key string) *http.Request {
+	r.Header.Set("X-Requester-Key", key)
+	return r
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+)
+
+const defaultPprofAddr = "localhost:6060"
+
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// ENABLE_PPROF=true のときだけ、公開用muxとは別ポート（PPROF_ADDR）でpprofを出す
+func startPprofServer() {
+	if !envBool("ENABLE_PPROF") {
+		return
+	}
+	addr := os.Getenv("PPROF_ADDR")
+	if addr == "" {
+		addr = defaultPprofAddr
+	}
+	mux := http.NewServeMux()
+	registerPprof(mux)
+	go func() {
+		log.Printf("pprof listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("pprof server error: %v", err)
+		}
+	}()
+}
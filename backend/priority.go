@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 1が最優先（/admin/requests/mine は昇順に並べる）
+const (
+	minPriority = 1
+	maxPriority = 5
+)
+
+// 省略したフィールドはそのまま、null で解除
+type PatchPriorityInput struct {
+	Priority json.RawMessage `json:"priority"`
+	DueAt    json.RawMessage `json:"dueAt"`
+}
+
+type PatchPriorityOutput struct {
+	RequestID string `json:"requestId"`
+	Priority  *int   `json:"priority,omitempty"`
+	DueAt     string `json:"dueAt,omitempty"`
+	Overdue   bool   `json:"overdue,omitempty"`
+	Version   int    `json:"version"`
+}
+
+func isJSONNull(v json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(v), []byte("null"))
+}
+
+// dueAt はUTCのRFC3339に揃えて保存する（並べ替えと期限判定を文字列比較で済ませるため）
+func parseDueAt(v string) (string, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(v))
+	if err != nil {
+		return "", fmt.Errorf("dueAt must be RFC3339")
+	}
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+// PATCH /requests/{id}/priority (admin only)。{"priority":1,"dueAt":"2024-05-01T09:00:00Z"}
+func (s *server) handlePatchPriority(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := requireScope(w, r, scopeWrite); !ok {
+		return
+	}
+	var in PatchPriorityInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	if in.Priority == nil && in.DueAt == nil {
+		httpError(w, r, "priority or dueAt required", http.StatusBadRequest)
+		return
+	}
+
+	var sets, removes []string
+	values := map[string]types.AttributeValue{
+		":one": &types.AttributeValueMemberN{Value: "1"},
+	}
+	switch {
+	case in.Priority == nil:
+	case isJSONNull(in.Priority):
+		removes = append(removes, "priority")
+	default:
+		var p int
+		if err := json.Unmarshal(in.Priority, &p); err != nil || p < minPriority || p > maxPriority {
+			httpError(w, r, fmt.Sprintf("priority must be an integer between %d and %d", minPriority, maxPriority), http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "priority = :p")
+		values[":p"] = &types.AttributeValueMemberN{Value: strconv.Itoa(p)}
+	}
+	switch {
+	case in.DueAt == nil:
+	case isJSONNull(in.DueAt):
+		removes = append(removes, "dueAt")
+	default:
+		var raw string
+		if err := json.Unmarshal(in.DueAt, &raw); err != nil {
+			httpError(w, r, "dueAt must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		due, err := parseDueAt(raw)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sets = append(sets, "dueAt = :d")
+		values[":d"] = &types.AttributeValueMemberS{Value: due}
+	}
+
+	expr := ""
+	if len(sets) > 0 {
+		expr += "SET " + strings.Join(sets, ", ") + " "
+	}
+	if len(removes) > 0 {
+		expr += "REMOVE " + strings.Join(removes, ", ") + " "
+	}
+	expr += "ADD version :one"
+
+	out, err := s.ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: requestPKPrefix + id},
+		},
+		UpdateExpression:          aws.String(expr),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	requestCache.invalidate(id)
+	if err != nil {
+		writeStoreError(w, r, translateDynamoErr(err), "failed to update")
+		return
+	}
+
+	it := decodeRequestItem(out.Attributes)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("ETag", etagFor(it.Version))
+	writeJSON(w, r, PatchPriorityOutput{
+		RequestID: id,
+		Priority:  it.Priority,
+		DueAt:     it.DueAt,
+		Overdue:   isOverdue(it.DueAt, it.Status, time.Now().UTC(), overdueGrace()),
+		Version:   it.Version,
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ApproximateAgeOfOldestMessage はCloudWatchのメトリクスでGetQueueAttributesでは取れないので含めない
+type QueueStatsOutput struct {
+	QueueURL   string `json:"queueUrl"`
+	Visible    int    `json:"approximateNumberOfMessages"`
+	NotVisible int    `json:"approximateNumberOfMessagesNotVisible"`
+	Delayed    int    `json:"approximateNumberOfMessagesDelayed"`
+	FetchedAt  string `json:"fetchedAt"`
+}
+
+// ダッシュボードの連続リロードでSQSを叩きすぎないよう、QUEUE_STATS_CACHE_TTL（既定5s）だけ使い回す
+type queueStatsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	fetched time.Time
+	out     QueueStatsOutput
+}
+
+func (c *queueStatsCache) get(ctx context.Context, s *server) (QueueStatsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetched.IsZero() && time.Since(c.fetched) < c.ttl {
+		return c.out, nil
+	}
+
+	res, err := s.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(s.queueURL),
+		AttributeNames: []sqstypes.QueueAttributeName{
+			sqstypes.QueueAttributeNameApproximateNumberOfMessages,
+			sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+			sqstypes.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		},
+	})
+	if err != nil {
+		return QueueStatsOutput{}, err
+	}
+	atoi := func(name sqstypes.QueueAttributeName) int {
+		n, _ := strconv.Atoi(res.Attributes[string(name)])
+		return n
+	}
+	c.fetched = time.Now()
+	c.out = QueueStatsOutput{
+		QueueURL:   s.queueURL,
+		Visible:    atoi(sqstypes.QueueAttributeNameApproximateNumberOfMessages),
+		NotVisible: atoi(sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+		Delayed:    atoi(sqstypes.QueueAttributeNameApproximateNumberOfMessagesDelayed),
+		FetchedAt:  c.fetched.UTC().Format(time.RFC3339),
+	}
+	return c.out, nil
+}
+
+// GET /admin/queue/stats (read scope)
+func (s *server) handleQueueStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeRead); !ok {
+		return
+	}
+	out, err := s.queueStats.get(r.Context(), s)
+	if err != nil {
+		httpError(w, r, "failed to read queue attributes", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, out)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// これを超えたら満タンに戻ったバケットを捨てる（しばらく来ていないキー）
+const maxRateLimitKeys = 10000
+
+// キーごとのトークンバケット（プロセス内のみ。インスタンスごとに別々に数える）。nilなら制限なし。
+// 空のときは、次の1トークンが貯まるまでの時間を Retry-After にする
+type rateLimiter struct {
+	rate  float64 // 1秒あたりに貯まるトークン
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// <prefix>_RATE_LIMIT（1分あたり、0で無効）と <prefix>_RATE_BURST（既定は RATE_LIMIT と同じ）
+func loadRateLimiter(prefix string) *rateLimiter {
+	perMinute := envInt(prefix+"_RATE_LIMIT", 0)
+	if perMinute <= 0 {
+		return nil
+	}
+	return newRateLimiter(perMinute, envInt(prefix+"_RATE_BURST", perMinute))
+}
+
+// トークンを1つ使う。足りなければ false と、1つ貯まるまでの時間
+func (l *rateLimiter) take(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitKeys {
+			l.evictFull(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// 満タンまで貯まったバケットは、新しく作るのと同じなので消してよい
+func (l *rateLimiter) evictFull(now time.Time) {
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// 超えていれば429（Retry-After はバケットから計算）を書いて false
+func (l *rateLimiter) allow(w http.ResponseWriter, r *http.Request, key, message string) bool {
+	ok, retryAfter := l.take(key)
+	if !ok {
+		tooManyRequests(w, r, message, retryAfter)
+	}
+	return ok
+}
+
+// 追跡リンクごとの GET/HEAD /requests/{id}（GET_RATE_LIMIT）と、依頼者ごとの POST /requests（CREATE_RATE_LIMIT）
+var (
+	getRateLimiter    *rateLimiter
+	createRateLimiter *rateLimiter
+)
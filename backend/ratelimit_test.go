@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRetryAfterDecreasesAsBucketRefills(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	l := newRateLimiter(6, 1) // 10秒に1トークン
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.take("k"); !ok {
+		t.Fatal("first request should be allowed")
+	}
+	tests := []struct {
+		elapsed        time.Duration
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{0, http.StatusTooManyRequests, "10"},
+		{4 * time.Second, http.StatusTooManyRequests, "6"},
+		{9*time.Second + 500*time.Millisecond, http.StatusTooManyRequests, "1"},
+		{10 * time.Second, http.StatusOK, ""},
+		{10 * time.Second, http.StatusTooManyRequests, "10"},
+	}
+	start := now
+	for _, tt := range tests {
+		now = start.Add(tt.elapsed)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/requests/x?t=k", nil)
+		if l.allow(w, r, "k", "slow down") {
+			w.WriteHeader(http.StatusOK)
+		}
+		if w.Code != tt.wantStatus {
+			t.Fatalf("at +%v: status = %d, want %d", tt.elapsed, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("at +%v: Retry-After = %q, want %q", tt.elapsed, got, tt.wantRetryAfter)
+		}
+	}
+}
+
+func TestRateLimiterBurstAndKeys(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.take("a"); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+	ok, wait := l.take("a")
+	if ok || wait != time.Second {
+		t.Errorf("take after burst = %v, %v; want false, 1s", ok, wait)
+	}
+	if ok, _ := l.take("b"); !ok {
+		t.Error("other key should have its own bucket")
+	}
+}
+
+func TestNilRateLimiterAllows(t *testing.T) {
+	var l *rateLimiter
+	w := httptest.NewRecorder()
+	if !l.allow(w, httptest.NewRequest(http.MethodGet, "/", nil), "k", "x") {
+		t.Error("nil limiter should allow")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// GET /admin/requests/{id}/raw (admin scope, ENABLE_DEBUG_ENDPOINTS=true のときだけ。それ以外は404)
+// 調査用にitemを属性そのまま（notifiedAt, lastEventId, processedEventIds なども）返す。requesterTokenだけは伏せる
+func (s *server) handleRawItem(w http.ResponseWriter, r *http.Request, id string) {
+	if !envBool("ENABLE_DEBUG_ENDPOINTS") {
+		notFound(w, r)
+		return
+	}
+	if _, ok := requireScope(w, r, scopeAdmin); !ok {
+		return
+	}
+
+	out, err := s.ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
+		TableName:      aws.String(requestsTable),
+		Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		writeStoreError(w, r, err, "failed to read")
+		return
+	}
+	if len(out.Item) == 0 {
+		notFound(w, r)
+		return
+	}
+
+	var raw map[string]any
+	if err := attributevalue.UnmarshalMap(out.Item, &raw); err != nil {
+		httpError(w, r, "failed to decode item", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := raw["requesterToken"]; ok {
+		raw["requesterToken"] = "[redacted]"
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, raw)
+}
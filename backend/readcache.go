@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	defaultReadCacheTTL  = 2 * time.Second
+	defaultReadCacheSize = 1000
+)
+
+// 依頼者向けGETのitemキャッシュ（ENABLE_READ_CACHE=true のときだけ。nilなら無効）。
+// キャッシュするのはitemだけで、トークンの照合は毎回行う。
+// このプロセスでの書き込みは invalidate で消すが、workerの書き込み（statusHistory等）や他インスタンスの書き込みは
+// READ_CACHE_TTL（既定2s）の間は見えない
+var requestCache *readCache
+
+type readCache struct {
+	ttl time.Duration
+	max int
+
+	mu    sync.Mutex
+	ll    *list.List // 先頭ほど最近使った
+	items map[string]*list.Element
+}
+
+type readCacheEntry struct {
+	id      string
+	item    map[string]types.AttributeValue
+	expires time.Time
+}
+
+func newReadCache(ttl time.Duration, max int) *readCache {
+	return &readCache{ttl: ttl, max: max, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func loadReadCache() *readCache {
+	if !envBool("ENABLE_READ_CACHE") {
+		return nil
+	}
+	return newReadCache(envDuration("READ_CACHE_TTL", defaultReadCacheTTL), envInt("READ_CACHE_SIZE", defaultReadCacheSize))
+}
+
+// 返したitemは他のリクエストと共有なので、呼び出し側で変更しない
+func (c *readCache) get(id string) (map[string]types.AttributeValue, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*readCacheEntry)
+	if time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, id)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.item, true
+}
+
+func (c *readCache) put(id string, item map[string]types.AttributeValue) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[id]; ok {
+		e := el.Value.(*readCacheEntry)
+		e.item, e.expires = item, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[id] = c.ll.PushFront(&readCacheEntry{id: id, item: item, expires: expires})
+	for c.ll.Len() > c.max {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*readCacheEntry).id)
+	}
+}
+
+// 書き込みの後に呼ぶ（前に呼ぶと、書き込み完了前の読み込みが古いitemを入れ直しうる）
+func (c *readCache) invalidate(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		c.ll.Remove(el)
+		delete(c.items, id)
+	}
+}
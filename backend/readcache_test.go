@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func cacheItem(title string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"title": &types.AttributeValueMemberS{Value: title}}
+}
+
+func TestReadCache(t *testing.T) {
+	type op struct {
+		kind string // put / get / invalidate / wait
+		id   string
+		want string // getで期待するtitle。空ならミス
+	}
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		max  int
+		ops  []op
+	}{
+		{name: "miss then hit", ttl: time.Minute, max: 10, ops: []op{
+			{kind: "get", id: "a"},
+			{kind: "put", id: "a", want: "A"},
+			{kind: "get", id: "a", want: "A"},
+		}},
+		{name: "put replaces", ttl: time.Minute, max: 10, ops: []op{
+			{kind: "put", id: "a", want: "A"},
+			{kind: "put", id: "a", want: "A2"},
+			{kind: "get", id: "a", want: "A2"},
+		}},
+		{name: "invalidate", ttl: time.Minute, max: 10, ops: []op{
+			{kind: "put", id: "a", want: "A"},
+			{kind: "put", id: "b", want: "B"},
+			{kind: "invalidate", id: "a"},
+			{kind: "get", id: "a"},
+			{kind: "get", id: "b", want: "B"},
+			{kind: "invalidate", id: "missing"},
+		}},
+		{name: "expired", ttl: time.Millisecond, max: 10, ops: []op{
+			{kind: "put", id: "a", want: "A"},
+			{kind: "wait"},
+			{kind: "get", id: "a"},
+		}},
+		{name: "evicts least recently used", ttl: time.Minute, max: 2, ops: []op{
+			{kind: "put", id: "a", want: "A"},
+			{kind: "put", id: "b", want: "B"},
+			{kind: "get", id: "a", want: "A"}, // aを使ったのでbが一番古い
+			{kind: "put", id: "c", want: "C"},
+			{kind: "get", id: "b"},
+			{kind: "get", id: "a", want: "A"},
+			{kind: "get", id: "c", want: "C"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newReadCache(tt.ttl, tt.max)
+			for i, o := range tt.ops {
+				switch o.kind {
+				case "put":
+					c.put(o.id, cacheItem(o.want))
+				case "invalidate":
+					c.invalidate(o.id)
+				case "wait":
+					time.Sleep(5 * time.Millisecond)
+				case "get":
+					item, ok := c.get(o.id)
+					got := ""
+					if ok {
+						got = decodeRequestItem(item).Title
+					}
+					if got != o.want {
+						t.Errorf("op %d: get(%s) = %q, want %q", i, o.id, got, o.want)
+					}
+				}
+			}
+			if c.ll.Len() != len(c.items) || c.ll.Len() > tt.max {
+				t.Errorf("list %d entries, map %d, max %d", c.ll.Len(), len(c.items), tt.max)
+			}
+		})
+	}
+}
+
+func TestNilReadCacheIsDisabled(t *testing.T) {
+	var c *readCache
+	c.put("a", cacheItem("A"))
+	c.invalidate("a")
+	if _, ok := c.get("a"); ok {
+		t.Error("nil cache returned an item")
+	}
+}
+
+// キャッシュしてもトークンの照合は毎回行う。書き込み後の invalidate で読み直す
+func TestRequesterReadsThroughCache(t *testing.T) {
+	const tok = "11111111-1111-4111-8111-111111111111"
+	title := "v1"
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		return map[string]any{"Item": map[string]any{
+			"PK":             map[string]any{"S": "REQ#r1"},
+			"title":          map[string]any{"S": title},
+			"requesterToken": map[string]any{"S": tok},
+		}}, nil
+	}}
+	ddb := fake.dynamoClient()
+	old := requestCache
+	requestCache = newReadCache(time.Minute, 10)
+	defer func() { requestCache = old }()
+	ctx := context.Background()
+
+	steps := []struct {
+		name      string
+		token     string
+		before    func()
+		wantErr   error
+		wantTitle string
+		wantReads int // ここまでのGetItem回数
+	}{
+		{name: "miss reads DynamoDB", token: tok, wantTitle: "v1", wantReads: 1},
+		{name: "hit", token: tok, wantTitle: "v1", wantReads: 1},
+		{name: "wrong token on a cached item", token: "99999999-9999-4999-8999-999999999999", wantErr: errTokenMismatch, wantReads: 1},
+		{name: "write without invalidate is still cached", token: tok, before: func() { title = "v2" }, wantTitle: "v1", wantReads: 1},
+		{name: "invalidate reads again", token: tok, before: func() { requestCache.invalidate("r1") }, wantTitle: "v2", wantReads: 2},
+	}
+	for _, s := range steps {
+		if s.before != nil {
+			s.before()
+		}
+		item, err := getRequesterItem(ctx, ddb, "r1", s.token, true)
+		if !errors.Is(err, s.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", s.name, err, s.wantErr)
+		}
+		if err == nil && decodeRequestItem(item).Title != s.wantTitle {
+			t.Errorf("%s: title = %q, want %q", s.name, decodeRequestItem(item).Title, s.wantTitle)
+		}
+		if got := len(fake.callsOf("GetItem")); got != s.wantReads {
+			t.Errorf("%s: GetItem calls = %d, want %d", s.name, got, s.wantReads)
+		}
+	}
+}
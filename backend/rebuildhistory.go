@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// workerの HISTORY_RETENTION と同じ既定（0で無制限）
+const defaultHistoryRetention = 50
+
+type RebuildHistoryOutput struct {
+	RequestID string `json:"requestId"`
+	Rebuilt   int    `json:"rebuilt"`           // statusHistory に書いた件数
+	Restored  int    `json:"restored"`          // RequestEvents から戻した（今の statusHistory になかった）件数
+	Dropped   int    `json:"dropped,omitempty"` // HISTORY_RETENTION を超えて書かなかった古い件数（RequestEvents には残る）
+}
+
+// POST /admin/requests/{id}/rebuild-history (admin scope, ALLOW_DESTRUCTIVE_OPS=true のときだけ)
+// RequestEvents のイベントitem（HISTORY_RETENTION_ARCHIVE で書き写されたもの）と今の statusHistory を
+// eventId で重複を除いてマージし、changedAt 順に並べ直して書き戻す。今の履歴にある分は消さない。
+// 読んでから書くまでに version（ステータス等）か statusHistory の件数（workerの追記）が変わっていたら409
+func (s *server) handleRebuildHistory(w http.ResponseWriter, r *http.Request, id string) {
+	t, ok := requireScope(w, r, scopeAdmin)
+	if !ok {
+		return
+	}
+	if !envBool("ALLOW_DESTRUCTIVE_OPS") {
+		httpError(w, r, "destructive operations are disabled (set ALLOW_DESTRUCTIVE_OPS=true)", http.StatusForbidden)
+		return
+	}
+	if historyItemsMode() {
+		httpError(w, r, "statusHistory is not used with HISTORY_STORAGE=items", http.StatusBadRequest)
+		return
+	}
+
+	key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: requestPKPrefix + id}}
+	cur, err := s.ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
+		TableName:            aws.String(requestsTable),
+		Key:                  key,
+		ProjectionExpression: aws.String("PK, version, statusHistory"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		writeStoreError(w, r, err, "failed to read")
+		return
+	}
+	if len(cur.Item) == 0 {
+		notFound(w, r)
+		return
+	}
+	version := decodeRequestItem(cur.Item).Version
+	current := decodeHistory(cur.Item)
+	// 条件に使うのは壊れた要素も含めたリストの長さ（workerの追記・削除で変わる）
+	rawLen := -1
+	if l, ok := cur.Item["statusHistory"].(*types.AttributeValueMemberL); ok {
+		rawLen = len(l.Value)
+	}
+
+	in := &dynamodb.QueryInput{
+		TableName:              aws.String(eventsTable),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: requestPKPrefix + id},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+	var archived []HistoryEntry
+	for {
+		out, err := s.ddb.Query(r.Context(), in)
+		if err != nil {
+			writeStoreError(w, r, err, "failed to read events")
+			return
+		}
+		for _, item := range out.Items {
+			archived = append(archived, historyEntryFromEventItem(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+	if len(archived) == 0 {
+		httpError(w, r, "no event records to rebuild from", http.StatusNotFound)
+		return
+	}
+
+	entries, restored := mergeHistory(current, archived)
+	res := RebuildHistoryOutput{RequestID: id, Restored: restored}
+	if keep := envIntAllowZero("HISTORY_RETENTION", defaultHistoryRetention); keep > 0 && len(entries) > keep {
+		res.Dropped = len(entries) - keep
+		entries = entries[res.Dropped:]
+	}
+	history, err := attributevalue.Marshal(entries)
+	if err != nil {
+		httpError(w, r, "failed to encode history", http.StatusInternalServerError)
+		return
+	}
+
+	conds := []string{"attribute_exists(PK)"}
+	values := map[string]types.AttributeValue{":h": history}
+	if version == 0 {
+		conds = append(conds, "attribute_not_exists(version)")
+	} else {
+		conds = append(conds, "version = :v")
+		values[":v"] = &types.AttributeValueMemberN{Value: strconv.Itoa(version)}
+	}
+	// workerの追記は version を上げないので、件数でも確認する
+	if rawLen < 0 {
+		conds = append(conds, "attribute_not_exists(statusHistory)")
+	} else {
+		conds = append(conds, "size(statusHistory) = :n")
+		values[":n"] = &types.AttributeValueMemberN{Value: strconv.Itoa(rawLen)}
+	}
+	_, err = s.ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(requestsTable),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET statusHistory = :h"),
+		ConditionExpression:       aws.String(strings.Join(conds, " AND ")),
+		ExpressionAttributeValues: values,
+		// 条件失敗時にitemがあれば409（なければ404）にするため
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	requestCache.invalidate(id)
+	if err != nil {
+		writeStoreError(w, r, translateDynamoErr(err), "failed to write history")
+		return
+	}
+	res.Rebuilt = len(entries)
+	log.Printf("audit: history rebuilt requestId=%s entries=%d restored=%d by=%q", id, res.Rebuilt, res.Restored, t.Label)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, res)
+}
+
+// 今の履歴に、そこにない eventId のイベントitemを足して changedAt 順に並べる。
+// 同じ eventId は今の履歴の方を残す。eventId のない（壊れた）要素はそのまま残す。
+// 戻り値の2つ目は足した件数
+func mergeHistory(current, archived []HistoryEntry) ([]HistoryEntry, int) {
+	seen := make(map[string]bool, len(current))
+	merged := make([]HistoryEntry, 0, len(current)+len(archived))
+	for _, e := range current {
+		if e.EventID != "" {
+			seen[e.EventID] = true
+		}
+		merged = append(merged, e)
+	}
+	restored := 0
+	for _, e := range archived {
+		if e.EventID == "" || seen[e.EventID] {
+			continue
+		}
+		seen[e.EventID] = true
+		merged = append(merged, e)
+		restored++
+	}
+	// changedAt はUTCのRFC3339なので文字列比較で時刻順。同時刻は元の順（今の履歴が先）
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].ChangedAt < merged[j].ChangedAt })
+	return merged, restored
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeHistory(t *testing.T) {
+	e := func(id, at string) HistoryEntry {
+		return HistoryEntry{EventID: id, NewStatus: "APPROVED", ChangedAt: at}
+	}
+	tests := []struct {
+		name         string
+		current      []HistoryEntry
+		archived     []HistoryEntry
+		want         []HistoryEntry
+		wantRestored int
+	}{
+		{
+			name:         "archived entries go before the live ones",
+			current:      []HistoryEntry{e("c", "2024-01-03T00:00:00Z"), e("d", "2024-01-04T00:00:00Z")},
+			archived:     []HistoryEntry{e("a", "2024-01-01T00:00:00Z"), e("b", "2024-01-02T00:00:00Z")},
+			want:         []HistoryEntry{e("a", "2024-01-01T00:00:00Z"), e("b", "2024-01-02T00:00:00Z"), e("c", "2024-01-03T00:00:00Z"), e("d", "2024-01-04T00:00:00Z")},
+			wantRestored: 2,
+		},
+		{
+			name:         "duplicates keep the live entry",
+			current:      []HistoryEntry{{EventID: "a", NewStatus: "REJECTED", ChangedAt: "2024-01-01T00:00:00Z"}},
+			archived:     []HistoryEntry{e("a", "2024-01-01T00:00:00Z")},
+			want:         []HistoryEntry{{EventID: "a", NewStatus: "REJECTED", ChangedAt: "2024-01-01T00:00:00Z"}},
+			wantRestored: 0,
+		},
+		{
+			name:         "empty history is filled from the archive",
+			archived:     []HistoryEntry{e("b", "2024-01-02T00:00:00Z"), e("a", "2024-01-01T00:00:00Z")},
+			want:         []HistoryEntry{e("a", "2024-01-01T00:00:00Z"), e("b", "2024-01-02T00:00:00Z")},
+			wantRestored: 2,
+		},
+		{
+			name:         "entries without eventId are kept but not restored",
+			current:      []HistoryEntry{{NewStatus: "DONE", ChangedAt: "2024-01-05T00:00:00Z"}},
+			archived:     []HistoryEntry{{NewStatus: "DONE", ChangedAt: "2024-01-01T00:00:00Z"}},
+			want:         []HistoryEntry{{NewStatus: "DONE", ChangedAt: "2024-01-05T00:00:00Z"}},
+			wantRestored: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, restored := mergeHistory(tt.current, tt.archived)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %+v, want %+v", got, tt.want)
+			}
+			if restored != tt.wantRestored {
+				t.Errorf("restored = %d, want %d", restored, tt.wantRestored)
+			}
+		})
+	}
+}
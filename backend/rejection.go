@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+)
+
+// 拒否理由コード（セキュリティダッシュボード用）
+const (
+	rejectTokenMissing      = "token_missing"
+	rejectTokenMalformed    = "token_malformed"
+	rejectTokenMismatch     = "token_mismatch"
+	rejectAuthMalformed     = "auth_header_malformed"
+	rejectAdminUnauthorized = "admin_unauthorized"
+	rejectAdminForbidden    = "admin_forbidden"
+	rejectRateLimited       = "rate_limited"
+	rejectBodyTooLarge      = "body_too_large"
+)
+
+// アクセスログとは別に、拒否したリクエストだけをwarnで出す。
+// REJECTION_LOG=false で無効（ローカルでうるさいとき）。トークン等の値は出さない
+var rejectionLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+func logRejection(r *http.Request, reason string) {
+	if !envBoolDefault("REJECTION_LOG", true) {
+		return
+	}
+	rejectionLogger.LogAttrs(r.Context(), slog.LevelWarn, "rejected",
+		slog.String("reason", reason),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("clientIp", clientIP(r)),
+		slog.String("requestId", requestIDFrom(r.Context())),
+	)
+}
+
+// TRUST_PROXY_HEADERS=true なら X-Forwarded-For の先頭、なければ接続元
+func clientIP(r *http.Request) string {
+	if envBool("TRUST_PROXY_HEADERS") {
+		if v := firstForwarded(r.Header.Get("X-Forwarded-For")); v != "" {
+			return v
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+const defaultMaxBodyBytes = 1 << 20
+
+// MAX_BODY_BYTES（既定1MiB）を超えるボディは413。Content-Lengthがない（chunked）場合も読み込み上限をかける
+func withBodyLimit(next http.Handler) http.Handler {
+	maxBytes := int64(envInt("MAX_BODY_BYTES", defaultMaxBodyBytes))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			logRejection(r, rejectBodyTooLarge)
+			httpError(w, r, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+type ReplayOutput struct {
+	RequestID string `json:"requestId"`
+	Status    string `json:"status"`
+	ChangedAt string `json:"changedAt"`
+	EventID   string `json:"eventId"`
+}
+
+// POST /admin/requests/{id}/replay (admin only)
+// 現在のstatusで新しいeventIdのイベントを再投入する（statusは変更しない）
+func (s *server) handleReplay(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := requireScope(w, r, scopeWrite); !ok {
+		return
+	}
+
+	out, err := s.ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
+		TableName:      aws.String(requestsTable),
+		Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		writeStoreError(w, r, err, "failed to read")
+		return
+	}
+	if len(out.Item) == 0 {
+		httpError(w, r, "not found", http.StatusNotFound)
+		return
+	}
+
+	it := decodeRequestItem(out.Item)
+	status := it.Status
+	changedAt := it.StatusUpdatedAt
+	if changedAt == "" {
+		changedAt = it.CreatedAt
+	}
+
+	// eventIdを新しくしないとworkerの重複チェックで捨てられる。
+	// Replay: workerは通知だけ行い、履歴やitemは更新しない（replayのたびに同じ遷移が履歴に増えないように）
+	ev := StatusChangedEvent{
+		EventID:   uuid.NewString(),
+		RequestID: id,
+		NewStatus: status,
+		ChangedAt: changedAt,
+		Reason:    it.StatusReason,
+		Replay:    true,
+	}
+	if err := enqueueStatusChanged(r.Context(), s.sqs, s.queueURL, ev); err != nil {
+		httpError(w, r, "failed to enqueue", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("replayed eventId=%s requestId=%s status=%s", ev.EventID, id, status)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, ReplayOutput{
+		RequestID: id,
+		Status:    status,
+		ChangedAt: changedAt,
+		EventID:   ev.EventID,
+	})
+}
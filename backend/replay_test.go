@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// replayのイベントは replay:true で投入し（workerは通知だけ行う）、読み込みの失敗はストアのエラーとして返す
+func TestHandleReplay(t *testing.T) {
+	item := map[string]any{
+		"PK":              map[string]any{"S": "REQ#r1"},
+		"requestId":       map[string]any{"S": "r1"},
+		"status":          map[string]any{"S": "DONE"},
+		"createdAt":       map[string]any{"S": "2024-05-01T08:00:00Z"},
+		"statusUpdatedAt": map[string]any{"S": "2024-05-01T09:00:00Z"},
+	}
+	tests := []struct {
+		name     string
+		getItem  func() (any, error)
+		wantCode int
+	}{
+		{name: "replayed", wantCode: http.StatusOK, getItem: func() (any, error) { return map[string]any{"Item": item}, nil }},
+		{name: "missing request", wantCode: http.StatusNotFound, getItem: func() (any, error) { return map[string]any{}, nil }},
+		{name: "dynamodb unreachable", wantCode: http.StatusServiceUnavailable, getItem: func() (any, error) { return nil, errConnRefused }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKENS", "")
+			resetDependencyState(t)
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+				if op == "GetItem" {
+					return tt.getItem()
+				}
+				return map[string]any{"MessageId": "m1"}, nil
+			}}
+			srv := &server{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), queueURL: "http://fake/queue"}
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/requests/r1/replay", nil)
+			r.Header.Set("Authorization", "Bearer dev-admin-token")
+			rec := httptest.NewRecorder()
+			srv.handleReplay(rec, r, "r1")
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			sends := fake.callsOf("SendMessage")
+			if tt.wantCode != http.StatusOK {
+				if len(sends) != 0 {
+					t.Errorf("enqueued %d event(s) on failure", len(sends))
+				}
+				return
+			}
+			if len(sends) != 1 {
+				t.Fatalf("SendMessage calls = %d, want 1", len(sends))
+			}
+			var ev StatusChangedEvent
+			if err := json.Unmarshal([]byte(sends[0].Input["MessageBody"].(string)), &ev); err != nil {
+				t.Fatal(err)
+			}
+			if !ev.Replay || ev.NewStatus != "DONE" || ev.ChangedAt != "2024-05-01T09:00:00Z" || ev.EventID == "" {
+				t.Errorf("replayed event = %+v, want replay of DONE at 09:00", ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+var (
+	errTokenMismatch = errors.New("requester token mismatch")
+	errCorruptItem   = errors.New("corrupt item")
+)
+
+// X-Requester-Token ヘッダを優先し、なければ共有用trackingUrlの ?t= を使う
+func requesterTokenFrom(r *http.Request) string {
+	if v := r.Header.Get("X-Requester-Token"); v != "" {
+		return v
+	}
+	return r.URL.Query().Get("t")
+}
+
+// requesterTokenはuuid.NewString()で発行しているので、UUIDとして読めないものはDBを引かずに400。
+// 「形式不正」と「不一致」でDynamoDBを読むかどうかが変わらないよう、読む前に弾く
+func requireRequesterToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	t := requesterTokenFrom(r)
+	if t == "" {
+		logRejection(r, rejectTokenMissing)
+		httpError(w, r, "token required", http.StatusBadRequest)
+		return "", false
+	}
+	if _, err := uuid.Parse(t); err != nil || len(t) != 36 {
+		logRejection(r, rejectTokenMalformed)
+		httpError(w, r, "malformed token", http.StatusBadRequest)
+		return "", false
+	}
+	return t, true
+}
+
+// 読み取り整合性。?consistent=true|false が最優先、なければ DYNAMODB_CONSISTENT_READS（既定 true）
+func wantConsistentRead(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("consistent")); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("DYNAMODB_CONSISTENT_READS")); err == nil {
+		return v
+	}
+	return true
+}
+
+// requesterTokenが一致する場合だけitemを返す。
+// 結果整合読み込みで見つからない場合は作成直後の可能性があるので強い整合性で読み直す。
+func getRequesterItem(ctx context.Context, ddb *dynamodb.Client, id, token string, consistent bool) (map[string]types.AttributeValue, error) {
+	return getRequesterItemProjected(ctx, ddb, id, token, consistent, nil)
+}
+
+// attrsを指定するとその属性だけ読む（照合用にrequesterTokenは常に含める）。
+// 読み取りキャッシュが有効なら、キャッシュできるようにitem全体を読む
+func getRequesterItemProjected(ctx context.Context, ddb *dynamodb.Client, id, token string, consistent bool, attrs []string) (map[string]types.AttributeValue, error) {
+	item, ok := requestCache.get(id)
+	if !ok {
+		in := &dynamodb.GetItemInput{
+			TableName:      aws.String(requestsTable),
+			Key:            map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+			ConsistentRead: aws.Bool(consistent),
+		}
+		if len(attrs) > 0 && requestCache == nil {
+			if !slices.Contains(attrs, "requesterToken") {
+				attrs = append([]string{"requesterToken"}, attrs...)
+			}
+			in.ProjectionExpression, in.ExpressionAttributeNames = projection(attrs)
+		}
+		out, err := ddb.GetItem(ctx, in)
+		if err == nil && len(out.Item) == 0 && !consistent {
+			in.ConsistentRead = aws.Bool(true)
+			out, err = ddb.GetItem(ctx, in)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Item) == 0 {
+			return nil, errRequestNotFound
+		}
+		item = out.Item
+		requestCache.put(id, item)
+	}
+
+	stored := decodeRequestItem(item).RequesterToken
+	if stored == "" {
+		return nil, errCorruptItem
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(token)) != 1 {
+		return nil, errTokenMismatch
+	}
+	return item, nil
+}
+
+func writeRequesterItemError(w http.ResponseWriter, r *http.Request, err error) {
+	writeStoreError(w, r, err, "failed to read")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireRequesterToken(t *testing.T) {
+	const valid = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	tests := []struct {
+		name   string
+		query  string
+		header string
+		want   string
+		wantOK bool
+	}{
+		{name: "query token", query: "?t=" + valid, want: valid, wantOK: true},
+		{name: "header wins over query", query: "?t=not-a-uuid", header: valid, want: valid, wantOK: true},
+		{name: "missing"},
+		{name: "not a uuid", query: "?t=abc"},
+		{name: "braced uuid", query: "?t={" + valid + "}"},
+		{name: "urn uuid", query: "?t=urn:uuid:" + valid},
+		{name: "uuid without hyphens", query: "?t=0f8fad5bd9cb469fa16570867728950e"},
+		{name: "malformed header", header: "x" + valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REJECTION_LOG", "false")
+			r := httptest.NewRequest(http.MethodGet, "/requests/r1"+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Requester-Token", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			got, ok := requireRequesterToken(rec, r)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if !ok && rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "net/mail"
+
+// RFC 5321 のアドレス長の上限
+const maxRequesterEmailLength = 254
+
+// "Name <a@example.com>" のような表示名付きは受け付けない（アドレスだけ）
+func validRequesterEmail(s string) bool {
+	if len(s) > maxRequesterEmailLength {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Name == "" && addr.Address == s
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+)
+
+var errRequestIDExists = errors.New("request id already exists")
+
+// クライアント指定のrequestId。URLのパスとキー（REQ#<id>）にそのまま入るので安全な文字だけ（UUIDもこれに含まれる）
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// /requests/batch-get と同じ名前は GET /requests/{id} で引けなくなるので使わせない
+func validRequestID(id string) bool {
+	return requestIDPattern.MatchString(id) && id != "batch-get"
+}
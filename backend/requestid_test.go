@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"0f8fad5b-d9cb-469f-a165-70867728950e", true},
+		{"ext_ORDER-42", true},
+		{"a", true},
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("a", 65), false},
+		{"", false},
+		{"has space", false},
+		{"slash/inside", false},
+		{"REQ#1", false},
+		{"dot.ted", false},
+		{"日本語", false},
+		{"batch-get", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+// 不正なIDはDynamoDBを叩かずに400、衝突は条件付き書き込みの失敗から409
+func TestCreateRequestClientSuppliedID(t *testing.T) {
+	collision := awsError{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException"}
+	txCollision := awsError{Status: http.StatusBadRequest, Code: "TransactionCanceledException", Reasons: []string{"None", "ConditionalCheckFailed"}}
+	tests := []struct {
+		name         string
+		requestID    string
+		uniqueTitles bool
+		storeErr     error
+		wantStatus   int
+		wantCalls    int
+	}{
+		{name: "valid id", requestID: "ext-42", wantStatus: http.StatusCreated, wantCalls: 1},
+		{name: "collision", requestID: "ext-42", storeErr: collision, wantStatus: http.StatusConflict, wantCalls: 1},
+		{name: "collision with unique titles", requestID: "ext-42", uniqueTitles: true, storeErr: txCollision, wantStatus: http.StatusConflict, wantCalls: 1},
+		{name: "invalid characters", requestID: "a/b", wantStatus: http.StatusBadRequest},
+		{name: "too long", requestID: strings.Repeat("x", 65), wantStatus: http.StatusBadRequest},
+		{name: "reserved name", requestID: "batch-get", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"DEDUP_WINDOW", "DISPLAY_IDS", "EMIT_CREATED_EVENTS", "MAX_OPEN_REQUESTS_PER_REQUESTER"} {
+				t.Setenv(k, "")
+			}
+			t.Setenv("FORBID_DUPLICATE_TITLES", strconv.FormatBool(tt.uniqueTitles))
+			fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+				return nil, tt.storeErr
+			}}
+			srv := &server{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), queueURL: "http://fake/queue"}
+
+			rec := httptest.NewRecorder()
+			body := `{"title":"laptop","requestId":"` + tt.requestID + `"}`
+			srv.handleCreateRequest(rec, httptest.NewRequest(http.MethodPost, "/requests", strings.NewReader(body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := len(fake.calls); got != tt.wantCalls {
+				t.Errorf("DynamoDB calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
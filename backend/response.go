@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ?case=snake または Accept: application/json; case=snake でsnake_caseのキーを返す
+func wantSnakeCase(r *http.Request) bool {
+	if r.URL.Query().Get("case") == "snake" {
+		return true
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && params["case"] == "snake" {
+			return true
+		}
+	}
+	return false
+}
+
+// ?pretty=true または Accept: application/json; pretty=true で2スペースのインデント付き（curlでの確認用）。既定はコンパクト
+func wantPretty(r *http.Request) bool {
+	if v, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return v
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && params["pretty"] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// 時刻を epoch ミリ秒（整数）で返すか。?timeFormat=epoch|rfc3339 か Accept の timeFormat パラメータ、
+// なければ TIME_FORMAT（既定 rfc3339）。DynamoDBにはRFC3339のまま保存し、変えるのは応答だけ
+func wantEpochTimes(r *http.Request) bool {
+	v := r.URL.Query().Get("timeFormat")
+	if v == "" {
+		for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+			if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && params["timeformat"] != "" {
+				v = params["timeformat"]
+				break
+			}
+		}
+	}
+	if v == "" {
+		v = os.Getenv("TIME_FORMAT")
+	}
+	return strings.EqualFold(v, "epoch")
+}
+
+// prefixは配列の要素として書くとき用（ストリーミング）
+func indentJSON(b []byte, prefix string) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, "  "); err != nil {
+		return b
+	}
+	return buf.Bytes()
+}
+
+// 構造体ごとにsnake_case版やepoch版を用意する代わりに、通常のJSONを作ってから変換する
+func marshalJSON(r *http.Request, v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	snake, epoch := wantSnakeCase(r), wantEpochTimes(r)
+	if err != nil || (!snake && !epoch) {
+		return b, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // 数値の精度を落とさない
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	// キー名（〜At）で判定するので、snake_caseにする前に変換する
+	if epoch {
+		tree = epochTimes(tree)
+	}
+	if snake {
+		tree = snakeKeys(tree)
+	}
+	return json.Marshal(tree)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	b, err := marshalJSON(r, v)
+	if err != nil {
+		httpError(w, r, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	if wantPretty(r) {
+		b = indentJSON(b, "")
+	}
+	_, _ = w.Write(append(b, '\n'))
+}
+
+func snakeKeys(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[toSnake(k)] = snakeKeys(val)
+		}
+		return out
+	case []any:
+		for i := range t {
+			t[i] = snakeKeys(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// createdAt / changedAt / statusUpdatedAt など "At" で終わるキーのRFC3339文字列をepochミリ秒にする。
+// 読めない値（空文字など）はそのまま
+func epochTimes(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if s, ok := val.(string); ok && strings.HasSuffix(k, "At") {
+				if ts, err := time.Parse(time.RFC3339, s); err == nil {
+					t[k] = json.Number(strconv.FormatInt(ts.UnixMilli(), 10))
+				}
+				continue
+			}
+			t[k] = epochTimes(val)
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = epochTimes(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// camelCaseのフィールド名だけを変換する。
+// 大文字で始まるキー（timeInStatusの "IN_PROGRESS" などデータとしてのキー）はそのまま。
+func toSnake(s string) string {
+	if s == "" || !unicode.IsLower(rune(s[0])) {
+		return s
+	}
+	var b strings.Builder
+	rs := []rune(s)
+	for i, c := range rs {
+		if unicode.IsUpper(c) {
+			// "requestID" のような連続大文字は1語として扱う
+			if i > 0 && (unicode.IsLower(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(c))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
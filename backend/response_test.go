@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONTimeFormat(t *testing.T) {
+	type history struct {
+		ChangedAt string `json:"changedAt"`
+	}
+	type payload struct {
+		RequestID string    `json:"requestId"`
+		CreatedAt string    `json:"createdAt"`
+		DueAt     string    `json:"dueAt"`
+		Title     string    `json:"title"`
+		History   []history `json:"history"`
+		Version   int       `json:"version"`
+	}
+	v := payload{
+		RequestID: "r1",
+		CreatedAt: "2024-05-01T09:00:00Z",
+		DueAt:     "",
+		Title:     "2024-05-01T09:00:00Z", // 〜At 以外のキーは変えない
+		History:   []history{{ChangedAt: "2024-05-01T18:00:01+09:00"}},
+		Version:   3,
+	}
+	const rfc = `{"requestId":"r1","createdAt":"2024-05-01T09:00:00Z","dueAt":"","title":"2024-05-01T09:00:00Z","history":[{"changedAt":"2024-05-01T18:00:01+09:00"}],"version":3}` + "\n"
+	const epoch = `{"createdAt":1714554000000,"dueAt":"","history":[{"changedAt":1714554001000}],"requestId":"r1","title":"2024-05-01T09:00:00Z","version":3}` + "\n"
+	const epochSnake = `{"created_at":1714554000000,"due_at":"","history":[{"changed_at":1714554001000}],"request_id":"r1","title":"2024-05-01T09:00:00Z","version":3}` + "\n"
+
+	tests := []struct {
+		name   string
+		env    string
+		query  string
+		accept string
+		want   string
+	}{
+		{name: "default rfc3339", want: rfc},
+		{name: "query epoch", query: "?timeFormat=epoch", want: epoch},
+		{name: "query is case-insensitive", query: "?timeFormat=EPOCH", want: epoch},
+		{name: "accept parameter", accept: "application/json; timeFormat=epoch", want: epoch},
+		{name: "env default", env: "epoch", want: epoch},
+		{name: "query overrides env", env: "epoch", query: "?timeFormat=rfc3339", want: rfc},
+		{name: "with snake case", query: "?timeFormat=epoch&case=snake", want: epochSnake},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIME_FORMAT", tt.env)
+			r := httptest.NewRequest(http.MethodGet, "/requests/r1"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			writeJSON(rec, r, v)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("body =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 無効化できるルート。methodが空ならメソッドを問わない。subは /requests/{id}/<sub> などの2段目
+type featureRoute struct {
+	method  string
+	pattern string
+	sub     string
+}
+
+var featureRoutes = map[string]featureRoute{
+	"create":         {http.MethodPost, "/requests", ""},
+	"owner-requests": {http.MethodGet, "/requests", ""},
+	"batch-get":      {"", "/requests/batch-get", ""},
+	"get-request":    {"", "/requests/", ""},
+	"history":        {"", "/requests/", "history"},
+	"cancel":         {"", "/requests/", "cancel"},
+	"tags":           {"", "/requests/", "tags"},
+	"assignee":       {"", "/requests/", "assignee"},
+	"priority":       {"", "/requests/", "priority"},
+	"update-status":  {"", "/requests/", "status"},
+	"admin-list":     {"", "/admin/requests", ""},
+	"bulk-status":    {"", "/admin/requests/bulk-status", ""},
+	"my-requests":    {"", "/admin/requests/mine", ""},
+	"overdue":        {"", "/admin/requests/overdue", ""},
+	"export":         {"", "/admin/requests/export", ""},
+	"history-batch":  {"", "/admin/requests/history/batch", ""},
+	"replay":         {"", "/admin/requests/", "replay"},
+	"timeline":       {"", "/admin/requests/", "timeline"},
+	"raw-item":       {"", "/admin/requests/", "raw"},
+	"rebuild":        {"", "/admin/requests/", "rebuild-history"},
+	"purge-queue":    {"", "/admin/maintenance/purge-queue", ""},
+	"queue-stats":    {"", "/admin/queue/stats", ""},
+	"admin-config":   {"", "/admin/config", ""},
+	"system-status":  {"", "/status", ""},
+}
+
+// DISABLED_ROUTES（カンマ区切りのルート名。既定は全部有効）。知らない名前は起動時にエラー
+func loadDisabledRoutes() (map[string]bool, error) {
+	disabled := map[string]bool{}
+	for _, name := range strings.Split(os.Getenv("DISABLED_ROUTES"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := featureRoutes[name]; !ok {
+			names := make([]string, 0, len(featureRoutes))
+			for n := range featureRoutes {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("DISABLED_ROUTES: unknown route %q (known: %s)", name, strings.Join(names, ", "))
+		}
+		disabled[name] = true
+	}
+	return disabled, nil
+}
+
+// muxのパターンとパスの2段目からルート名を引く（該当なしは空）
+func routeNameFor(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	sub := ""
+	if strings.HasSuffix(pattern, "/") && pattern != "/" {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, pattern), "/"), "/")
+		if len(parts) == 2 {
+			sub = parts[1]
+		}
+	}
+	for name, fr := range featureRoutes {
+		if fr.pattern == pattern && fr.sub == sub && (fr.method == "" || fr.method == r.Method) {
+			return name
+		}
+	}
+	return ""
+}
+
+// 無効化したルートは存在しないものとして404（未知のパスと同じ本文）
+func withRouteFlags(disabled map[string]bool, mux *http.ServeMux, next http.Handler) http.Handler {
+	if len(disabled) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if disabled[routeNameFor(mux, r)] {
+			notFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
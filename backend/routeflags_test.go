@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRouteFlags(t *testing.T) {
+	mux := http.NewServeMux()
+	registered := map[string]bool{}
+	for _, fr := range featureRoutes {
+		if !registered[fr.pattern] {
+			registered[fr.pattern] = true
+			mux.HandleFunc(fr.pattern, func(w http.ResponseWriter, r *http.Request) {})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		disabled string
+		method   string
+		path     string
+		want     int
+	}{
+		{name: "all enabled by default", method: http.MethodPost, path: "/requests", want: http.StatusOK},
+		{name: "disabled create", disabled: "create", method: http.MethodPost, path: "/requests", want: http.StatusNotFound},
+		{name: "create disabled keeps owner list", disabled: "create", method: http.MethodGet, path: "/requests", want: http.StatusOK},
+		{name: "disabled sub route", disabled: "history", method: http.MethodGet, path: "/requests/r1/history", want: http.StatusNotFound},
+		{name: "sub route disabled keeps the request", disabled: "history", method: http.MethodGet, path: "/requests/r1", want: http.StatusOK},
+		{name: "disabled get keeps sub routes", disabled: "get-request", method: http.MethodPatch, path: "/requests/r1/status", want: http.StatusOK},
+		{name: "disabled get", disabled: "get-request", method: http.MethodGet, path: "/requests/r1", want: http.StatusNotFound},
+		{name: "several", disabled: " replay , export", method: http.MethodPost, path: "/admin/requests/r1/replay", want: http.StatusNotFound},
+		{name: "several second", disabled: "replay,export", method: http.MethodGet, path: "/admin/requests/export", want: http.StatusNotFound},
+		{name: "unrelated route", disabled: "replay", method: http.MethodGet, path: "/status", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DISABLED_ROUTES", tt.disabled)
+			disabled, err := loadDisabledRoutes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			withRouteFlags(disabled, mux, mux).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+			if tt.want == http.StatusNotFound {
+				if got := decodeErrorBody(t, rec).Code; got != "not_found" {
+					t.Errorf("code = %q, want not_found like an unknown path", got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDisabledRoutesUnknownName(t *testing.T) {
+	t.Setenv("DISABLED_ROUTES", "create,nope")
+	if _, err := loadDisabledRoutes(); err == nil {
+		t.Error("want error for an unknown route name")
+	}
+}
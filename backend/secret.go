@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// <NAME>_FILE（Kubernetesのsecretマウント等）があればその中身、なければ環境変数 <NAME> の値。
+// ファイルが優先。SIGHUPで読み直せるように値はatomicに持つ
+type secretValue struct {
+	name string
+	v    atomic.Pointer[string]
+}
+
+func newSecret(name string) (*secretValue, error) {
+	s := &secretValue{name: name}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *secretValue) load() error {
+	v := os.Getenv(s.name)
+	if path := os.Getenv(s.name + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", s.name, err)
+		}
+		v = strings.TrimRight(string(b), "\r\n")
+	}
+	s.v.Store(&v)
+	return nil
+}
+
+func (s *secretValue) Get() string {
+	if s == nil {
+		return ""
+	}
+	if p := s.v.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// SIGHUPで *_FILE を読み直す（ローテーション用）。読めなかった場合は前の値のまま
+func reloadSecretsOnSIGHUP(secrets ...*secretValue) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			for _, s := range secrets {
+				if err := s.load(); err != nil {
+					log.Printf("secret reload failed: %v", err)
+					continue
+				}
+				log.Printf("secret reloaded: %s", s.name)
+			}
+		}
+	}()
+}
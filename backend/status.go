@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	changedByAdmin     = "admin"
+	changedByRequester = "requester"
+)
+
+// StatusChangedEventの形のバージョン（workerの currentEventSchemaVersion と合わせる）
+const eventSchemaVersion = 1
+
+var (
+	errRequestNotFound   = errors.New("request not found")
+	errInvalidTransition = errors.New("invalid status transition")
+)
+
+// 遷移可能なステータス（DONE/REJECTED/CANCELLEDは終端）。
+// TRIAGEは DEFAULT_STATUS=TRIAGE のときの受付直後の状態で、一度出たら戻らない
+var allowedTransitions = map[string][]string{
+	"TRIAGE":      {"PENDING", "IN_PROGRESS", "REJECTED", "CANCELLED"},
+	"PENDING":     {"IN_PROGRESS", "DONE", "REJECTED", "CANCELLED"},
+	"IN_PROGRESS": {"PENDING", "DONE", "REJECTED", "CANCELLED"},
+	"DONE":        {},
+	"REJECTED":    {},
+	"CANCELLED":   {},
+}
+
+// "done" や " Done " も受け付ける（判定前に必ず通す）
+func normalizeStatus(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// 作成時のステータス。mainで DEFAULT_STATUS から設定する
+var initialStatus = "PENDING"
+
+// DEFAULT_STATUS（既定 PENDING）。終端ステータスで作成しても意味がないので非終端のみ
+func loadDefaultStatus() (string, error) {
+	v := normalizeStatus(os.Getenv("DEFAULT_STATUS"))
+	if v == "" {
+		return "PENDING", nil
+	}
+	if !isValidStatus(v) || isTerminalStatus(v) {
+		return "", fmt.Errorf("DEFAULT_STATUS: %q is not a valid non-terminal status", v)
+	}
+	return v, nil
+}
+
+// ステータス変更の理由（任意）の上限（文字数）
+const maxStatusReasonLength = 500
+
+// REASON_REQUIRED_STATUSES（カンマ区切り。既定 REJECTED、空を明示すれば理由は常に任意）
+func reasonRequired(status string) bool {
+	v, ok := os.LookupEnv("REASON_REQUIRED_STATUSES")
+	if !ok {
+		v = "REJECTED"
+	}
+	for _, s := range strings.Split(v, ",") {
+		if normalizeStatus(s) == status {
+			return true
+		}
+	}
+	return false
+}
+
+func validateStatusReason(status, reason string) error {
+	if utf8.RuneCountInString(reason) > maxStatusReasonLength {
+		return fmt.Errorf("reason too long (max %d)", maxStatusReasonLength)
+	}
+	if reason == "" && reasonRequired(status) {
+		return fmt.Errorf("reason required for %s", status)
+	}
+	return nil
+}
+
+func isValidStatus(s string) bool {
+	_, ok := allowedTransitions[s]
+	return ok
+}
+
+// toへ遷移できる元ステータス一覧
+func transitionSources(to string) []string {
+	var from []string
+	for s, nexts := range allowedTransitions {
+		for _, n := range nexts {
+			if n == to {
+				from = append(from, s)
+			}
+		}
+	}
+	return from
+}
+
+// statusを更新するUpdateExpressionと値。versionは毎回+1。
+// API_WRITES_HISTORY=true なら同じUpdateItemでstatusHistoryへの追記と処理済みeventIdの登録も行う。
+// 履歴は同じitemの属性なので1回のUpdateItemで原子的に書ける（TransactWriteItemsは不要）。
+// HISTORY_STORAGE=items では履歴itemはworkerが書くので、ここでは処理済みの記録だけ。
+// workerは processedEventIds で重複と判定して追記をスキップし、通知だけ行う
+func statusUpdate(ev StatusChangedEvent) (string, map[string]types.AttributeValue) {
+	values := map[string]types.AttributeValue{
+		":s":   &types.AttributeValueMemberS{Value: ev.NewStatus},
+		":t":   &types.AttributeValueMemberS{Value: ev.ChangedAt},
+		":b":   &types.AttributeValueMemberS{Value: ev.ChangedBy},
+		":one": &types.AttributeValueMemberN{Value: "1"},
+	}
+	set := "SET #st = :s, statusUpdatedAt = :t, statusChangedBy = :b"
+	add := " ADD version :one"
+	// 理由は最新の遷移のものだけ持つ（理由なしの遷移では消す）
+	remove := " REMOVE statusReason"
+	if ev.Reason != "" {
+		values[":r"] = &types.AttributeValueMemberS{Value: ev.Reason}
+		set += ", statusReason = :r"
+		remove = ""
+	}
+	if envBool("API_WRITES_HISTORY") {
+		entry := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"eventId":   &types.AttributeValueMemberS{Value: ev.EventID},
+			"newStatus": &types.AttributeValueMemberS{Value: ev.NewStatus},
+			"changedAt": &types.AttributeValueMemberS{Value: ev.ChangedAt},
+			"changedBy": &types.AttributeValueMemberS{Value: ev.ChangedBy},
+			"handledAt": &types.AttributeValueMemberS{Value: ev.ChangedAt},
+		}}
+		if ev.Reason != "" {
+			entry.Value["reason"] = &types.AttributeValueMemberS{Value: ev.Reason}
+		}
+		values[":eid"] = &types.AttributeValueMemberS{Value: ev.EventID}
+		values[":eids"] = &types.AttributeValueMemberSS{Value: []string{ev.EventID}}
+		values[":eidl"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: ev.EventID}}}
+		values[":empty"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+		// processedEventOrder は集合の記録順（workerのトリムが古い順に削るのに使う）
+		set += ", lastEventId = :eid, lastAppliedChangedAt = :t" +
+			", processedEventOrder = list_append(if_not_exists(processedEventOrder, :empty), :eidl)"
+		add += ", processedEventIds :eids"
+		if !historyItemsMode() {
+			values[":h"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{entry}}
+			set += ", statusHistory = list_append(if_not_exists(statusHistory, :empty), :h)"
+		}
+	}
+	return set + remove + add, values
+}
+
+// 遷移ルールを満たす場合だけstatusを更新する。
+// 条件失敗時はALL_OLDの有無で「存在しない」か「不正な遷移」かを判別する。
+func transitionStatus(ctx context.Context, ddb *dynamodb.Client, ev StatusChangedEvent) error {
+	from := transitionSources(ev.NewStatus)
+	if len(from) == 0 {
+		return errInvalidTransition
+	}
+
+	expr, values := statusUpdate(ev)
+	placeholders := make([]string, 0, len(from))
+	for i, s := range from {
+		key := fmt.Sprintf(":f%d", i)
+		placeholders = append(placeholders, key)
+		values[key] = &types.AttributeValueMemberS{Value: s}
+	}
+
+	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(requestsTable),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "REQ#" + ev.RequestID},
+		},
+		UpdateExpression: aws.String(expr),
+		ExpressionAttributeNames: map[string]string{
+			"#st": "status",
+		},
+		ExpressionAttributeValues:           values,
+		ConditionExpression:                 aws.String("attribute_exists(PK) AND #st IN (" + strings.Join(placeholders, ", ") + ")"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	requestCache.invalidate(ev.RequestID)
+	err = translateDynamoErr(err)
+	if errors.Is(err, errConditionFailed) {
+		return errInvalidTransition
+	}
+	return err
+}
+
+// EVENT_MAX_AGE（既定0=期限なし）があれば 送信時刻 + EVENT_MAX_AGE。
+// キューに長く滞留したイベントが、後から古いステータスを適用しないように。
+// replayはchangedAtが古いままなので、changedAtではなく送信時刻から数える。
+// pendingEventsに残すイベントには入れない（reconcileで再送した時刻からworkerが数える）
+func eventDeadline(sentAt time.Time) string {
+	maxAge := envDuration("EVENT_MAX_AGE", 0)
+	if maxAge == 0 {
+		return ""
+	}
+	return sentAt.Add(maxAge).UTC().Format(time.RFC3339)
+}
+
+// 送信するメッセージ本文（schemaVersion と deadline を入れる）
+func statusChangedBody(ev StatusChangedEvent) (string, error) {
+	ev.SchemaVersion = eventSchemaVersion
+	ev.Deadline = eventDeadline(time.Now())
+	body, err := json.Marshal(ev)
+	return string(body), err
+}
+
+func enqueueStatusChanged(ctx context.Context, c *sqs.Client, queueURL string, ev StatusChangedEvent) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "sqs.SendMessage",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("eventId", ev.EventID), attribute.String("requestId", ev.RequestID)),
+	)
+	defer span.End()
+
+	body, err := statusChangedBody(ev)
+	if err != nil {
+		return err
+	}
+	_, err = c.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: eventMessageAttributes(ctx, eventTypeStatusChanged),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "send failed")
+	}
+	return err
+}
+
+// SendMessageBatch の1回あたりの上限
+const sqsMaxBatch = 10
+
+// 複数のイベントを10件ずつ SendMessageBatch で送る（メッセージ属性は1件ずつ送るときと同じ）。
+// 送れなかったイベントは eventId → エラー で返す。
+// Failed のうち送信側の誤りでないもの（スロットリング等）は1回だけ送り直す
+func enqueueStatusChangedBatch(ctx context.Context, c *sqs.Client, queueURL string, evs []StatusChangedEvent) map[string]error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "sqs.SendMessageBatch",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.Int("events", len(evs))),
+	)
+	defer span.End()
+
+	failed := map[string]error{}
+	for start := 0; start < len(evs); start += sqsMaxBatch {
+		chunk := evs[start:min(start+sqsMaxBatch, len(evs))]
+		entries := make([]sqstypes.SendMessageBatchRequestEntry, 0, len(chunk))
+		for i, ev := range chunk {
+			body, err := statusChangedBody(ev)
+			if err != nil {
+				failed[ev.EventID] = err
+				continue
+			}
+			entries = append(entries, sqstypes.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				MessageBody:       aws.String(body),
+				MessageAttributes: eventMessageAttributes(ctx, eventTypeStatusChanged),
+			})
+		}
+
+		for attempt := 1; len(entries) > 0; attempt++ {
+			out, err := c.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+				QueueUrl: aws.String(queueURL),
+				Entries:  entries,
+			})
+			if err != nil {
+				for _, e := range entries {
+					i, _ := strconv.Atoi(aws.ToString(e.Id))
+					failed[chunk[i].EventID] = err
+				}
+				break
+			}
+			byID := make(map[string]sqstypes.SendMessageBatchRequestEntry, len(entries))
+			for _, e := range entries {
+				byID[aws.ToString(e.Id)] = e
+			}
+			var retry []sqstypes.SendMessageBatchRequestEntry
+			for _, f := range out.Failed {
+				i, _ := strconv.Atoi(aws.ToString(f.Id))
+				if !f.SenderFault && attempt == 1 {
+					retry = append(retry, byID[aws.ToString(f.Id)])
+					continue
+				}
+				failed[chunk[i].EventID] = fmt.Errorf("%s: %s", aws.ToString(f.Code), aws.ToString(f.Message))
+			}
+			entries = retry
+		}
+	}
+	if len(failed) > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d not sent", len(failed), len(evs)))
+	}
+	return failed
+}
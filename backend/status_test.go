@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestEnqueueStatusChangedBatchPartialFailures(t *testing.T) {
+	evs := make([]StatusChangedEvent, 12)
+	for i := range evs {
+		evs[i] = StatusChangedEvent{EventID: fmt.Sprintf("e%02d", i), RequestID: fmt.Sprintf("r%02d", i), NewStatus: "DONE"}
+	}
+	// eventIdごとの失敗のさせ方。値は試行ごとの結果（"" = 成功、"throttle" = 送信側の誤りでない失敗、"invalid" = 送信側の誤り）
+	outcomes := map[string][]string{
+		"e03": {"throttle", ""},         // 1回目だけ失敗 → 再送で成功
+		"e05": {"invalid"},              // 送信側の誤りは再送しない
+		"e11": {"throttle", "throttle"}, // 再送は1回だけ
+	}
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		if op != "SendMessageBatch" {
+			return nil, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		entries := in["Entries"].([]any)
+		if len(entries) > sqsMaxBatch {
+			t.Errorf("batch of %d entries, max %d", len(entries), sqsMaxBatch)
+		}
+		var ok, failed []map[string]any
+		for _, raw := range entries {
+			e := raw.(map[string]any)
+			var ev StatusChangedEvent
+			if err := json.Unmarshal([]byte(e["MessageBody"].(string)), &ev); err != nil {
+				t.Fatalf("bad body: %v", err)
+			}
+			if attrs, _ := e["MessageAttributes"].(map[string]any); attrs[eventTypeAttr] == nil {
+				t.Errorf("%s: missing %s message attribute", ev.EventID, eventTypeAttr)
+			}
+			n := attempts[ev.EventID]
+			attempts[ev.EventID]++
+			result := ""
+			if o := outcomes[ev.EventID]; n < len(o) {
+				result = o[n]
+			}
+			switch result {
+			case "":
+				ok = append(ok, map[string]any{"Id": e["Id"], "MessageId": "m-" + ev.EventID, "MD5OfMessageBody": "x"})
+			case "throttle":
+				failed = append(failed, map[string]any{"Id": e["Id"], "SenderFault": false, "Code": "ThrottlingException", "Message": "slow down"})
+			case "invalid":
+				failed = append(failed, map[string]any{"Id": e["Id"], "SenderFault": true, "Code": "InvalidParameterValue", "Message": "bad"})
+			}
+		}
+		return map[string]any{"Successful": ok, "Failed": failed}, nil
+	}}
+
+	failed := enqueueStatusChangedBatch(context.Background(), fake.sqsClient(), "http://fake/queue", evs)
+
+	if len(failed) != 2 || failed["e05"] == nil || failed["e11"] == nil {
+		t.Fatalf("failed = %v, want e05 and e11", failed)
+	}
+	if !strings.Contains(failed["e05"].Error(), "InvalidParameterValue") || !strings.Contains(failed["e11"].Error(), "ThrottlingException") {
+		t.Errorf("errors should carry the SQS code: %v", failed)
+	}
+	wantAttempts := map[string]int{"e03": 2, "e05": 1, "e11": 2, "e00": 1, "e10": 1}
+	for id, want := range wantAttempts {
+		if attempts[id] != want {
+			t.Errorf("%s sent %d times, want %d", id, attempts[id], want)
+		}
+	}
+	// 10件 + 2件、それぞれ再送1回
+	if got := len(fake.callsOf("SendMessageBatch")); got != 4 {
+		t.Errorf("SendMessageBatch calls = %d, want 4", got)
+	}
+}
+
+func TestEnqueueStatusChangedBatchCallError(t *testing.T) {
+	evs := []StatusChangedEvent{{EventID: "e1", RequestID: "r1"}, {EventID: "e2", RequestID: "r2"}}
+	fake := &fakeAWS{t: t, handle: func(op string, in map[string]any) (any, error) {
+		return nil, awsError{Status: http.StatusInternalServerError, Code: "InternalError"}
+	}}
+	failed := enqueueStatusChangedBatch(context.Background(), fake.sqsClient(), "http://fake/queue", evs)
+	if len(failed) != 2 || failed["e1"] == nil || failed["e2"] == nil {
+		t.Errorf("failed = %v, want every event", failed)
+	}
+}
+
+func TestValidateStatusReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     *string // nil なら REASON_REQUIRED_STATUSES 未設定
+		status  string
+		reason  string
+		wantErr string
+	}{
+		{name: "rejected needs a reason by default", status: "REJECTED", wantErr: "reason required"},
+		{name: "rejected with reason", status: "REJECTED", reason: "out of budget"},
+		{name: "other status without reason", status: "APPROVED"},
+		{name: "configured list", env: aws.String("approved, rejected"), status: "APPROVED", wantErr: "reason required"},
+		{name: "configured list excludes rejected", env: aws.String("APPROVED"), status: "REJECTED"},
+		{name: "explicitly empty disables", env: aws.String(""), status: "REJECTED"},
+		{name: "too long", status: "APPROVED", reason: strings.Repeat("あ", maxStatusReasonLength+1), wantErr: "too long"},
+		{name: "max length in runes", status: "REJECTED", reason: strings.Repeat("あ", maxStatusReasonLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REASON_REQUIRED_STATUSES", "")
+			if tt.env == nil {
+				os.Unsetenv("REASON_REQUIRED_STATUSES")
+			} else {
+				t.Setenv("REASON_REQUIRED_STATUSES", *tt.env)
+			}
+			err := validateStatusReason(tt.status, tt.reason)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string
+		wantValid bool
+	}{
+		{"DONE", "DONE", true},
+		{"done", "DONE", true},
+		{" Done ", "DONE", true},
+		{"in_progress\n", "IN_PROGRESS", true},
+		{"\tCancelled", "CANCELLED", true},
+		{"finished", "FINISHED", false},
+		{"IN PROGRESS", "IN PROGRESS", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got := normalizeStatus(tt.in)
+		if got != tt.want || isValidStatus(got) != tt.wantValid {
+			t.Errorf("normalizeStatus(%q) = %q (valid %v), want %q (valid %v)", tt.in, got, isValidStatus(got), tt.want, tt.wantValid)
+		}
+	}
+}
+
+func TestStatusChangedBodySetsSchemaVersion(t *testing.T) {
+	t.Setenv("EVENT_MAX_AGE", "")
+	body, err := statusChangedBody(StatusChangedEvent{EventID: "e1", RequestID: "r1", NewStatus: "DONE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["schemaVersion"] != float64(eventSchemaVersion) {
+		t.Errorf("schemaVersion = %v, want %d", got["schemaVersion"], eventSchemaVersion)
+	}
+}
+
+func TestEventDeadline(t *testing.T) {
+	sentAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	tests := []struct {
+		maxAge string
+		want   string
+	}{
+		{maxAge: "", want: ""},
+		{maxAge: "0", want: ""},
+		{maxAge: "10m", want: "2024-05-01T00:10:00Z"},
+		{maxAge: "36h", want: "2024-05-02T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Setenv("EVENT_MAX_AGE", tt.maxAge)
+		if got := eventDeadline(sentAt); got != tt.want {
+			t.Errorf("EVENT_MAX_AGE=%q: eventDeadline() = %q, want %q", tt.maxAge, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBアクセスで返すエラー。ハンドラはSDKの型を見ずにこれらで分岐する。
+// errRequestNotFound / errInvalidTransition は status.go、errTokenMismatch / errCorruptItem は requester.go、
+// errUnprocessedKeys は historybatch.go
+var errConditionFailed = errors.New("condition check failed")
+
+// itemは存在するが条件式を満たさなかった。Itemは失敗時点の値（ALL_OLDを指定した場合のみ）
+type conditionError struct {
+	Item map[string]types.AttributeValue
+}
+
+func (e *conditionError) Error() string { return errConditionFailed.Error() }
+func (e *conditionError) Unwrap() error { return errConditionFailed }
+
+// ConditionalCheckFailedExceptionを errRequestNotFound / *conditionError に変換する。
+// 条件式は必ず attribute_exists(PK) を含むので、ALL_OLDのitemが空なら「存在しない」。
+// ALL_OLDを指定していない呼び出しでは常に errRequestNotFound になる
+func translateDynamoErr(err error) error {
+	var cfe *types.ConditionalCheckFailedException
+	if !errors.As(err, &cfe) {
+		return err
+	}
+	if len(cfe.Item) == 0 {
+		return errRequestNotFound
+	}
+	return &conditionError{Item: cfe.Item}
+}
+
+// エラー→HTTPステータスの対応はここだけで持つ。failMsgは想定外のエラーのときの本文
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, failMsg string) {
+	switch {
+	case errors.Is(err, errRequestNotFound):
+		httpError(w, r, "not found", http.StatusNotFound)
+	case errors.Is(err, errTokenMismatch):
+		logRejection(r, rejectTokenMismatch)
+		httpError(w, r, "forbidden", http.StatusForbidden)
+	case errors.Is(err, errInvalidTransition):
+		httpError(w, r, "invalid status transition", http.StatusConflict)
+	case errors.Is(err, errConditionFailed):
+		httpError(w, r, "conflict", http.StatusConflict)
+	case errors.Is(err, errCorruptItem):
+		httpError(w, r, "corrupt item", http.StatusInternalServerError)
+	case errors.Is(err, errUnprocessedKeys):
+		// スロットリングが再試行しても解けなかった。一時的なものなので503で再試行させる（degradedにはしない）
+		writeUnavailable(w, r, dynamoHealth, time.Second)
+	case observeDependencyError(dynamoHealth, err):
+		left, _ := dynamoHealth.degraded()
+		writeUnavailable(w, r, dynamoHealth, left)
+	default:
+		httpError(w, r, failMsg, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 1ページ分のitemを返す。done=trueで終端
+type pageSource func(ctx context.Context) (items []map[string]types.AttributeValue, done bool, err error)
+
+func scanSource(p *dynamodb.ScanPaginator) pageSource {
+	return func(ctx context.Context) ([]map[string]types.AttributeValue, bool, error) {
+		if !p.HasMorePages() {
+			return nil, true, nil
+		}
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		return page.Items, false, nil
+	}
+}
+
+func querySource(p *dynamodb.QueryPaginator) pageSource {
+	return func(ctx context.Context) ([]map[string]types.AttributeValue, bool, error) {
+		if !p.HasMorePages() {
+			return nil, true, nil
+		}
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		return page.Items, false, nil
+	}
+}
+
+// ページネータから読みながらJSON配列として書き出す（全件をメモリに載せない）。
+// 最初のページ取得に失敗した場合だけ500を返せる。途中で失敗したら配列を閉じずに打ち切る。
+func streamJSONArray(w http.ResponseWriter, r *http.Request, src pageSource, limit int, conv func(map[string]types.AttributeValue) any) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+
+	items, done, err := src(ctx)
+	if err != nil {
+		httpError(w, r, "failed to read", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write([]byte("["))
+
+	// ?pretty=true: 1要素ずつ改行してインデント
+	first, sep, elemPrefix := []byte{}, []byte(","), ""
+	if wantPretty(r) {
+		first, sep, elemPrefix = []byte("\n  "), []byte(",\n  "), "  "
+	}
+
+	written := 0
+	for {
+		for _, item := range items {
+			if written >= limit {
+				break
+			}
+			b, err := marshalJSON(r, conv(item))
+			if err != nil {
+				log.Printf("stream encode error: %v", err)
+				return
+			}
+			if elemPrefix != "" {
+				b = indentJSON(b, elemPrefix)
+			}
+			if written == 0 {
+				_, _ = w.Write(first)
+			} else {
+				_, _ = w.Write(sep)
+			}
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+			written++
+		}
+		if done || written >= limit {
+			break
+		}
+		// ページ単位でflushしてクライアントにすぐ届ける
+		_ = rc.Flush()
+
+		items, done, err = src(ctx)
+		if err != nil {
+			log.Printf("stream read error: %v (after %d items)", err, written)
+			return
+		}
+	}
+	if elemPrefix != "" && written > 0 {
+		_, _ = w.Write([]byte("\n"))
+	}
+	_, _ = w.Write([]byte("]\n"))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// pagesのとおりにページを返し、failAtページ目（0始まり、-1なら失敗しない）でエラーにする
+func fakePages(pages [][]string, failAt int) pageSource {
+	i := 0
+	return func(ctx context.Context) ([]map[string]types.AttributeValue, bool, error) {
+		if i == failAt {
+			return nil, false, errors.New("boom")
+		}
+		if i >= len(pages) {
+			return nil, true, nil
+		}
+		var items []map[string]types.AttributeValue
+		for _, id := range pages[i] {
+			items = append(items, map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: id}})
+		}
+		i++
+		return items, false, nil
+	}
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	tests := []struct {
+		name       string
+		pages      [][]string
+		failAt     int
+		limit      int
+		query      string
+		wantStatus int
+		want       []string // nil なら不正なJSON（途中で打ち切り）を期待
+	}{
+		{name: "multiple pages", pages: [][]string{{"a", "b"}, {"c"}, {"d", "e"}}, failAt: -1, limit: 100, wantStatus: 200, want: []string{"a", "b", "c", "d", "e"}},
+		{name: "empty pages in between", pages: [][]string{{"a"}, {}, {"b"}}, failAt: -1, limit: 100, wantStatus: 200, want: []string{"a", "b"}},
+		{name: "no items", failAt: -1, limit: 100, wantStatus: 200, want: []string{}},
+		{name: "limit inside a page", pages: [][]string{{"a", "b"}, {"c", "d"}}, failAt: -1, limit: 3, wantStatus: 200, want: []string{"a", "b", "c"}},
+		{name: "limit at page boundary", pages: [][]string{{"a", "b"}, {"c"}}, failAt: -1, limit: 2, wantStatus: 200, want: []string{"a", "b"}},
+		{name: "pretty", pages: [][]string{{"a"}, {"b"}}, failAt: -1, limit: 100, query: "?pretty=true", wantStatus: 200, want: []string{"a", "b"}},
+		{name: "first page fails", failAt: 0, limit: 100, wantStatus: http.StatusInternalServerError},
+		{name: "later page fails", pages: [][]string{{"a"}, {"b"}}, failAt: 1, limit: 100, wantStatus: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/admin/requests"+tt.query, nil)
+			streamJSONArray(rec, r, fakePages(tt.pages, tt.failAt), tt.limit, func(item map[string]types.AttributeValue) any {
+				return item["PK"].(*types.AttributeValueMemberS).Value
+			})
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got []string
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("truncated stream decoded as valid JSON: %s", rec.Body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// workerが定期的に書く稼働状況item（cmd/worker/heartbeat.go）
+const workerHeartbeatPK = "SYS#worker"
+
+const defaultWorkerHeartbeatStale = 60 * time.Second
+
+type workerHeartbeatItem struct {
+	Host             string `dynamodbav:"host"`
+	UpdatedAt        string `dynamodbav:"updatedAt"`
+	LastProcessedAt  string `dynamodbav:"lastProcessedAt"`
+	MessagesInFlight int    `dynamodbav:"messagesInFlight"`
+}
+
+type ComponentStatus struct {
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type WorkerStatus struct {
+	ComponentStatus
+	Host             string `json:"host,omitempty"`
+	LastHeartbeatAt  string `json:"lastHeartbeatAt,omitempty"`
+	LastProcessedAt  string `json:"lastProcessedAt,omitempty"`
+	MessagesInFlight int    `json:"messagesInFlight"`
+}
+
+type SystemStatus struct {
+	Healthy  bool            `json:"healthy"`
+	DynamoDB ComponentStatus `json:"dynamodb"`
+	SQS      ComponentStatus `json:"sqs"`
+	Worker   WorkerStatus    `json:"worker"`
+}
+
+func dependencyStatus(d *dependencyState) ComponentStatus {
+	if _, ok := d.degraded(); ok {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return ComponentStatus{Detail: d.lastErr}
+	}
+	return ComponentStatus{Healthy: true}
+}
+
+// GET /status
+// API側の依存の状態と、workerのheartbeat（WORKER_HEARTBEAT_STALE 既定60s より古ければ不調）をまとめて返す。
+// どれかが不調なら503（本文は同じ形）
+func (s *server) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	st := SystemStatus{
+		DynamoDB: dependencyStatus(dynamoHealth),
+		SQS:      dependencyStatus(sqsHealth),
+	}
+
+	// heartbeatの読み込み自体がDynamoDBの疎通確認を兼ねる
+	out, err := s.ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
+		TableName: aws.String(requestsTable),
+		Key:       map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: workerHeartbeatPK}},
+	})
+	switch {
+	case err != nil:
+		observeDependencyError(dynamoHealth, err)
+		st.DynamoDB = ComponentStatus{Detail: err.Error()}
+		st.Worker.Detail = "heartbeat unavailable"
+	case len(out.Item) == 0:
+		st.Worker.Detail = "no heartbeat yet"
+	default:
+		var hb workerHeartbeatItem
+		unmarshalItem(out.Item, &hb)
+		st.Worker.Host = hb.Host
+		st.Worker.LastHeartbeatAt = hb.UpdatedAt
+		st.Worker.LastProcessedAt = hb.LastProcessedAt
+		st.Worker.MessagesInFlight = hb.MessagesInFlight
+		stale := envDuration("WORKER_HEARTBEAT_STALE", defaultWorkerHeartbeatStale)
+		t, err := time.Parse(time.RFC3339, st.Worker.LastHeartbeatAt)
+		if err != nil || time.Since(t) > stale {
+			st.Worker.Detail = "heartbeat older than " + stale.String()
+		} else {
+			st.Worker.Healthy = true
+		}
+	}
+	st.Healthy = st.DynamoDB.Healthy && st.SQS.Healthy && st.Worker.Healthy
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if !st.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, r, st)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	maxTagsPerRequest = 20
+	maxTagLength      = 50
+)
+
+var errInvalidTags = errors.New("invalid tags")
+
+type PatchTagsInput struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// 空・長すぎるタグは不可。重複は除いて返す
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t == "" || utf8.RuneCountInString(t) > maxTagLength {
+			return nil, errInvalidTags
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// PATCH /requests/{id}/tags (admin only)
+// {"add":[...],"remove":[...]}。同じパスへのADDとDELETEは1つの式にできないので別々に更新する
+func (s *server) handlePatchTags(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := requireScope(w, r, scopeWrite); !ok {
+		return
+	}
+	var in PatchTagsInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpError(w, r, "bad json", http.StatusBadRequest)
+		return
+	}
+	add, errAdd := normalizeTags(in.Add)
+	remove, errRemove := normalizeTags(in.Remove)
+	if errAdd != nil || errRemove != nil {
+		httpError(w, r, "invalid tag (1-"+strconv.Itoa(maxTagLength)+" chars)", http.StatusBadRequest)
+		return
+	}
+	if len(add) > maxTagsPerRequest {
+		httpError(w, r, "too many tags (max "+strconv.Itoa(maxTagsPerRequest)+")", http.StatusBadRequest)
+		return
+	}
+	for _, t := range add {
+		for _, rm := range remove {
+			if t == rm {
+				httpError(w, r, "tag in both add and remove: "+t, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "REQ#" + id},
+	}
+	var attrs map[string]types.AttributeValue
+	// 片方だけ成功して返る場合もあるので、どの経路でも書き込みの後に消す
+	defer requestCache.invalidate(id)
+
+	if len(remove) > 0 {
+		out, err := s.ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
+			TableName:        aws.String(requestsTable),
+			Key:              key,
+			UpdateExpression: aws.String("DELETE tags :rm ADD version :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":rm":  &types.AttributeValueMemberSS{Value: remove},
+				":one": &types.AttributeValueMemberN{Value: "1"},
+			},
+			ConditionExpression: aws.String("attribute_exists(PK)"),
+			ReturnValues:        types.ReturnValueAllNew,
+		})
+		if err != nil {
+			writeTagsUpdateError(w, r, err)
+			return
+		}
+		attrs = out.Attributes
+	}
+
+	if len(add) > 0 {
+		// 追加後に上限を超えうる場合は弾く（既存タグとの重複分まで厳密には見ない）
+		out, err := s.ddb.UpdateItem(r.Context(), &dynamodb.UpdateItemInput{
+			TableName:        aws.String(requestsTable),
+			Key:              key,
+			UpdateExpression: aws.String("ADD tags :add, version :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":add": &types.AttributeValueMemberSS{Value: add},
+				":one": &types.AttributeValueMemberN{Value: "1"},
+				":lim": &types.AttributeValueMemberN{Value: strconv.Itoa(maxTagsPerRequest - len(add))},
+			},
+			ConditionExpression:                 aws.String("attribute_exists(PK) AND (attribute_not_exists(tags) OR size(tags) <= :lim)"),
+			ReturnValues:                        types.ReturnValueAllNew,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		})
+		if err != nil {
+			writeTagsUpdateError(w, r, err)
+			return
+		}
+		attrs = out.Attributes
+	}
+
+	if attrs == nil {
+		httpError(w, r, "add or remove required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, map[string]any{
+		"requestId": id,
+		"tags":      decodeRequestItem(attrs).sortedTags(),
+	})
+}
+
+// ADD側の条件失敗（itemはある）はタグ数の上限超過
+func writeTagsUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	err = translateDynamoErr(err)
+	if errors.Is(err, errConditionFailed) {
+		httpError(w, r, "too many tags (max "+strconv.Itoa(maxTagsPerRequest)+")", http.StatusBadRequest)
+		return
+	}
+	writeStoreError(w, r, err, "failed to update")
+}
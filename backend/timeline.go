@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type TimelineOutput struct {
+	RequestID    string             `json:"requestId"`
+	Status       string             `json:"status"`
+	CreatedAt    string             `json:"createdAt"`
+	AgeSeconds   float64            `json:"ageSeconds"`
+	TimeInStatus map[string]float64 `json:"timeInStatus"`
+}
+
+type statusChange struct {
+	status string
+	at     time.Time
+}
+
+// statusHistoryから各ステータスの滞在時間を計算する。
+// 履歴は到着順に追記されるので changedAt で並べ直し、時刻が読めない要素は捨てる。
+// workerが遅れていて最新の変更が履歴にない場合は item の status/statusUpdatedAt を末尾に補う。
+// 最後の区間は終端ステータスならその時点で止め、そうでなければ now まで。
+func computeTimeline(item map[string]types.AttributeValue, now time.Time) (TimelineOutput, bool) {
+	it := decodeRequestItem(item)
+	out := TimelineOutput{Status: it.Status, CreatedAt: it.CreatedAt}
+	created, err := time.Parse(time.RFC3339, out.CreatedAt)
+	if err != nil {
+		return out, false
+	}
+
+	var changes []statusChange
+	for _, e := range decodeHistory(item) {
+		at, err := time.Parse(time.RFC3339, e.ChangedAt)
+		if err != nil || e.NewStatus == "" {
+			continue
+		}
+		changes = append(changes, statusChange{status: e.NewStatus, at: at})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].at.Before(changes[j].at) })
+
+	if updated, err := time.Parse(time.RFC3339, it.StatusUpdatedAt); err == nil {
+		if len(changes) == 0 || changes[len(changes)-1].at.Before(updated) {
+			changes = append(changes, statusChange{status: out.Status, at: updated})
+		}
+	}
+
+	out.TimeInStatus = map[string]float64{}
+	// initialStatusがない古いitemはPENDINGで作成されている
+	first := it.InitialStatus
+	if first == "" {
+		first = "PENDING"
+	}
+	cur := statusChange{status: first, at: created}
+	end := now
+	for _, c := range changes {
+		if c.at.Before(cur.at) {
+			c.at = cur.at // createdAtより前の時刻などは区間0として扱う
+		}
+		out.TimeInStatus[cur.status] += c.at.Sub(cur.at).Seconds()
+		cur = c
+		if isTerminalStatus(c.status) {
+			end = c.at
+			break
+		}
+	}
+	if end.After(cur.at) {
+		out.TimeInStatus[cur.status] += end.Sub(cur.at).Seconds()
+	} else if _, ok := out.TimeInStatus[cur.status]; !ok {
+		out.TimeInStatus[cur.status] = 0
+	}
+	out.AgeSeconds = end.Sub(created).Seconds()
+	return out, true
+}
+
+// GET /admin/requests/{id}/timeline (admin only)
+func (s *server) handleTimeline(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := requireScope(w, r, scopeRead); !ok {
+		return
+	}
+	res, err := s.ddb.GetItem(r.Context(), &dynamodb.GetItemInput{
+		TableName: aws.String(requestsTable),
+		Key:       map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "REQ#" + id}},
+	})
+	if err != nil {
+		httpError(w, r, "failed to read", http.StatusInternalServerError)
+		return
+	}
+	if len(res.Item) == 0 {
+		httpError(w, r, "not found", http.StatusNotFound)
+		return
+	}
+
+	out, ok := computeTimeline(res.Item, time.Now().UTC())
+	if !ok {
+		httpError(w, r, "corrupt item", http.StatusInternalServerError)
+		return
+	}
+	out.RequestID = id
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeJSON(w, r, out)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultHandlerTimeout = 30 * time.Second
+
+// 少しずつ書き出すエンドポイント。TimeoutHandlerは応答をバッファしてFlushできないので対象外（切断で止まる）
+var streamingRoutes = map[string]bool{
+	"GET /requests":              true,
+	"GET /admin/requests":        true,
+	"GET /admin/requests/export": true,
+}
+
+// HANDLER_TIMEOUT（既定30s、0で無効）と、muxのパターンごとの上書き
+// HANDLER_TIMEOUTS="/admin/requests/bulk-status=2m,/requests/batch-get=5s"
+type handlerTimeouts struct {
+	def    time.Duration
+	routes map[string]time.Duration
+}
+
+func loadHandlerTimeouts() (handlerTimeouts, error) {
+	t := handlerTimeouts{def: defaultHandlerTimeout, routes: map[string]time.Duration{}}
+	if v := os.Getenv("HANDLER_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return t, fmt.Errorf("HANDLER_TIMEOUT: invalid duration %q", v)
+		}
+		t.def = d
+	}
+	for _, kv := range strings.Split(os.Getenv("HANDLER_TIMEOUTS"), ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		pattern, v, ok := strings.Cut(kv, "=")
+		d, err := time.ParseDuration(v)
+		if !ok || !strings.HasPrefix(pattern, "/") || err != nil || d < 0 {
+			return t, fmt.Errorf("HANDLER_TIMEOUTS: invalid entry %q (want /pattern=duration)", kv)
+		}
+		t.routes[pattern] = d
+	}
+	return t, nil
+}
+
+// 時間切れは503。リクエストのcontextもキャンセルされるので、実行中のAWS呼び出しはそこで打ち切られる
+// （その失敗は依存の不調として数えない。isUnavailableError 参照）
+func withHandlerTimeout(t handlerTimeouts, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		d, ok := t.routes[pattern]
+		if !ok {
+			d = t.def
+		}
+		if d == 0 || streamingRoutes[r.Method+" "+pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		http.TimeoutHandler(mux, d, timeoutBody).ServeHTTP(timeoutJSONWriter{w}, r)
+	})
+}
+
+// 時間切れの本文も他のエラーと同じJSON（TimeoutHandlerは固定の文字列しか書けない）
+const timeoutBody = `{"error":"request timed out","code":"timeout"}` + "\n"
+
+// TimeoutHandlerは時間切れのときContent-Typeを付けないので、503でまだ付いていなければJSONにする
+type timeoutJSONWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutJSONWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w timeoutJSONWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadHandlerTimeouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		def        string
+		routes     string
+		wantErr    bool
+		wantDef    time.Duration
+		wantRoutes map[string]time.Duration
+	}{
+		{name: "defaults", wantDef: defaultHandlerTimeout, wantRoutes: map[string]time.Duration{}},
+		{name: "disabled", def: "0", wantDef: 0, wantRoutes: map[string]time.Duration{}},
+		{name: "per route", def: "10s", routes: " /admin/requests/bulk-status=2m, /requests/batch-get=5s ,", wantDef: 10 * time.Second,
+			wantRoutes: map[string]time.Duration{"/admin/requests/bulk-status": 2 * time.Minute, "/requests/batch-get": 5 * time.Second}},
+		{name: "invalid default", def: "soon", wantErr: true},
+		{name: "negative default", def: "-1s", wantErr: true},
+		{name: "missing duration", routes: "/requests", wantErr: true},
+		{name: "pattern without slash", routes: "requests=5s", wantErr: true},
+		{name: "negative route", routes: "/requests=-5s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HANDLER_TIMEOUT", tt.def)
+			t.Setenv("HANDLER_TIMEOUTS", tt.routes)
+			got, err := loadHandlerTimeouts()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.def != tt.wantDef || len(got.routes) != len(tt.wantRoutes) {
+				t.Fatalf("got %+v, want def %v routes %v", got, tt.wantDef, tt.wantRoutes)
+			}
+			for k, v := range tt.wantRoutes {
+				if got.routes[k] != v {
+					t.Errorf("routes[%s] = %v, want %v", k, got.routes[k], v)
+				}
+			}
+		})
+	}
+}
+
+// わざと遅いハンドラで、時間切れ・ルートごとの上書き・ストリーミングの除外を確かめる
+func TestWithHandlerTimeoutSlowHandler(t *testing.T) {
+	const work = 50 * time.Millisecond
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		timeouts   handlerTimeouts
+		wantStatus int
+	}{
+		{name: "default timeout", method: http.MethodPost, path: "/slow", timeouts: handlerTimeouts{def: 10 * time.Millisecond}, wantStatus: http.StatusServiceUnavailable},
+		{name: "route override", method: http.MethodPost, path: "/slow", timeouts: handlerTimeouts{def: 10 * time.Millisecond, routes: map[string]time.Duration{"/slow": time.Second}}, wantStatus: http.StatusOK},
+		{name: "route override shorter", method: http.MethodPost, path: "/slow", timeouts: handlerTimeouts{def: time.Second, routes: map[string]time.Duration{"/slow": 10 * time.Millisecond}}, wantStatus: http.StatusServiceUnavailable},
+		{name: "disabled", method: http.MethodPost, path: "/slow", timeouts: handlerTimeouts{}, wantStatus: http.StatusOK},
+		{name: "streaming route exempt", method: http.MethodGet, path: "/requests", timeouts: handlerTimeouts{def: 10 * time.Millisecond}, wantStatus: http.StatusOK},
+		{name: "non-streaming method on streaming path", method: http.MethodPost, path: "/requests", timeouts: handlerTimeouts{def: 10 * time.Millisecond}, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canceled := make(chan bool, 1)
+			slow := func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(work):
+					canceled <- false
+					w.WriteHeader(http.StatusOK)
+				case <-r.Context().Done():
+					canceled <- true
+				}
+			}
+			mux := http.NewServeMux()
+			mux.HandleFunc("/slow", slow)
+			mux.HandleFunc("/requests", slow)
+
+			rec := httptest.NewRecorder()
+			withHandlerTimeout(tt.timeouts, mux).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			// 時間切れならハンドラのcontext（=AWS呼び出し）も打ち切られる
+			if got := <-canceled; got != (tt.wantStatus == http.StatusServiceUnavailable) {
+				t.Errorf("handler context canceled = %v", got)
+			}
+		})
+	}
+}
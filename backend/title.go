@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// タイトルの検査フック。エラーの文言はそのまま400の本文になる。
+// 外部のモデレーションなどを足すときはこれを実装して titleValidators に追加する
+type titleValidator interface {
+	ValidateTitle(title string) error
+}
+
+// 改行・タブを含む制御文字は常に拒否（ログやCSV、HTMLの表示が崩れる）
+type controlCharValidator struct{}
+
+func (controlCharValidator) ValidateTitle(title string) error {
+	for _, c := range title {
+		if unicode.IsControl(c) {
+			return errors.New("title must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// TITLE_DENYLIST（正規表現）に一致したら拒否。どのパターンに当たったかは返さない
+type denylistValidator struct {
+	re *regexp.Regexp
+}
+
+func (v denylistValidator) ValidateTitle(title string) error {
+	if v.re.MatchString(title) {
+		return errors.New("title contains disallowed content")
+	}
+	return nil
+}
+
+// TITLE_MIN_LEN（文字数。既定1=空でなければよい）
+type minLenValidator struct {
+	min int
+}
+
+func (v minLenValidator) ValidateTitle(title string) error {
+	if utf8.RuneCountInString(title) < v.min {
+		return fmt.Errorf("title too short (min %d characters)", v.min)
+	}
+	return nil
+}
+
+// TITLE_PATTERN（正規表現）に一致しなければ拒否。書式の指定なのでパターンは本文に含める
+type patternValidator struct {
+	re *regexp.Regexp
+}
+
+func (v patternValidator) ValidateTitle(title string) error {
+	if !v.re.MatchString(title) {
+		return fmt.Errorf("title must match pattern %s", v.re)
+	}
+	return nil
+}
+
+// mainで loadTitleValidators の結果を入れる
+var titleValidators = []titleValidator{controlCharValidator{}}
+
+// TITLE_DENYLIST / TITLE_PATTERN が正規表現として不正なら起動時にエラー
+func loadTitleValidators() ([]titleValidator, error) {
+	vs := []titleValidator{controlCharValidator{}}
+	if n := envInt("TITLE_MIN_LEN", 1); n > 1 {
+		vs = append(vs, minLenValidator{min: n})
+	}
+	if p := os.Getenv("TITLE_PATTERN"); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("TITLE_PATTERN: %w", err)
+		}
+		vs = append(vs, patternValidator{re: re})
+	}
+	if p := os.Getenv("TITLE_DENYLIST"); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("TITLE_DENYLIST: %w", err)
+		}
+		vs = append(vs, denylistValidator{re: re})
+	}
+	return vs, nil
+}
+
+func validateTitle(title string) error {
+	for _, v := range titleValidators {
+		if err := v.ValidateTitle(title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
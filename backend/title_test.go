@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestValidateTitleControlCharsAndDenylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		denylist string
+		title    string
+		wantErr  string
+	}{
+		{name: "plain", title: "Laptop for new hire"},
+		{name: "japanese", title: "ノートPCの貸し出し"},
+		{name: "newline", title: "Laptop\nfor new hire", wantErr: "title must not contain control characters"},
+		{name: "tab", title: "Laptop\tstand", wantErr: "title must not contain control characters"},
+		{name: "nul", title: "Laptop\x00", wantErr: "title must not contain control characters"},
+		{name: "c1 control", title: "Laptop\u0085", wantErr: "title must not contain control characters"},
+		{name: "denylist match", denylist: `(?i)\bdamn\b`, title: "Damn printer", wantErr: "title contains disallowed content"},
+		{name: "denylist no match", denylist: `(?i)\bdamn\b`, title: "Damned printer"},
+		{name: "control chars checked before denylist", denylist: "printer", title: "printer\r", wantErr: "title must not contain control characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TITLE_MIN_LEN", "")
+			t.Setenv("TITLE_PATTERN", "")
+			t.Setenv("TITLE_DENYLIST", tt.denylist)
+			vs, err := loadTitleValidators()
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := titleValidators
+			titleValidators = vs
+			defer func() { titleValidators = old }()
+
+			err = validateTitle(tt.title)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTitle(%q) = %v, want nil", tt.title, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateTitle(%q) = %v, want %q", tt.title, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTitleValidatorsInvalidDenylist(t *testing.T) {
+	t.Setenv("TITLE_PATTERN", "")
+	t.Setenv("TITLE_DENYLIST", "(unclosed")
+	if _, err := loadTitleValidators(); err == nil {
+		t.Error("want error for an invalid TITLE_DENYLIST")
+	}
+}
+
+func TestValidateTitleMinLenAndPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		minLen  string
+		pattern string
+		title   string
+		wantErr string
+	}{
+		{name: "default accepts one character", title: "x"},
+		{name: "too short", minLen: "5", title: "abcd", wantErr: "title too short (min 5 characters)"},
+		{name: "exactly min", minLen: "5", title: "abcde"},
+		{name: "min counts characters not bytes", minLen: "3", title: "ノート"},
+		{name: "pattern match", pattern: `^[A-Z]+-[0-9]+ `, title: "IT-42 laptop"},
+		{name: "pattern mismatch", pattern: `^[A-Z]+-[0-9]+ `, title: "laptop", wantErr: "title must match pattern ^[A-Z]+-[0-9]+ "},
+		{name: "min checked before pattern", minLen: "10", pattern: `^IT-`, title: "x", wantErr: "title too short (min 10 characters)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TITLE_MIN_LEN", tt.minLen)
+			t.Setenv("TITLE_PATTERN", tt.pattern)
+			t.Setenv("TITLE_DENYLIST", "")
+			vs, err := loadTitleValidators()
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := titleValidators
+			titleValidators = vs
+			defer func() { titleValidators = old }()
+
+			err = validateTitle(tt.title)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateTitle(%q) = %v, want nil", tt.title, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateTitle(%q) = %v, want %q", tt.title, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTitleValidatorsInvalidPattern(t *testing.T) {
+	t.Setenv("TITLE_DENYLIST", "")
+	t.Setenv("TITLE_PATTERN", "[")
+	if _, err := loadTitleValidators(); err == nil {
+		t.Error("want error for an invalid TITLE_PATTERN")
+	}
+}
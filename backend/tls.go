@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS_CERT_FILE / TLS_KEY_FILE が両方あればTLS（HTTP/2も有効になる）、両方なければ平文HTTP。
+// 片方だけ・読めない・ペアとして不正なら起動時にエラー
+func loadTLSFiles() (certFile, keyFile string, enabled bool, err error) {
+	certFile, keyFile = os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		return "", "", false, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return "", "", false, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return "", "", false, fmt.Errorf("TLS_CERT_FILE/TLS_KEY_FILE: %w", err)
+	}
+	return certFile, keyFile, true, nil
+}
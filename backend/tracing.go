@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const tracerName = "equipment-request/backend"
+
+// TRACING_ENABLED=true のときだけOTLP(HTTP)でトレースを送る。送信先は OTEL_EXPORTER_OTLP_ENDPOINT
+// （SDK標準の環境変数）。無効時は何もしない（グローバルはnoopのまま）
+func initTracing(ctx context.Context, service string) (func(context.Context) error, error) {
+	if !envBool("TRACING_ENABLED") {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// HTTPリクエストごとのspan（無効時はそのまま）
+func withTracing(next http.Handler) http.Handler {
+	if !envBool("TRACING_ENABLED") {
+		return next
+	}
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
+
+// trace contextをSQSのMessageAttributesに載せる（workerで取り出して同じtraceを続ける）
+func traceMessageAttributes(ctx context.Context) map[string]sqstypes.MessageAttributeValue {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	attrs := make(map[string]sqstypes.MessageAttributeValue, len(carrier))
+	for k, v := range carrier {
+		attrs[k] = sqstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	return attrs
+}
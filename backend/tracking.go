@@ -0,0 +1,73 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Acceptのq値でtext/htmlがapplication/jsonより高いときだけHTML。
+// */* は両方に効くので、curl（*/*）は同点でJSON、ブラウザ（text/html,...,*/*;q=0.8）はHTMLになる
+func prefersHTML(r *http.Request) bool {
+	htmlQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "*/*":
+			htmlQ = max(htmlQ, q)
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return htmlQ > jsonQ
+}
+
+// 外部アセットなしの1ページ（html/templateでエスケープ）
+var trackingPage = template.Must(template.New("tracking").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Request {{.RequestID}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+dt { color: #666; font-size: .85rem; margin-top: 1rem; }
+dd { margin: .25rem 0 0; font-size: 1.1rem; }
+.status { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>Status</dt><dd class="status">{{.Status}}</dd>
+<dt>Created</dt><dd><time datetime="{{.CreatedAt}}">{{.CreatedAt}}</time></dd>
+<dt>Request ID</dt><dd><code>{{.RequestID}}</code></dd>
+</dl>
+</body>
+</html>
+`))
+
+func renderTrackingPage(w http.ResponseWriter, out GetRequestOutput) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// トークン入りURLのページなので外部に送らない・キャッシュさせない
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if err := trackingPage.Execute(w, out); err != nil {
+		log.Printf("tracking page render error: %v", err)
+	}
+}
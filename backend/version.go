@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func etagFor(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func patchStatusOutputFromItem(item map[string]types.AttributeValue) PatchStatusOutput {
+	it := decodeRequestItem(item)
+	return PatchStatusOutput{
+		DisplayID:       it.DisplayID,
+		Title:           it.Title,
+		Status:          it.Status,
+		Tags:            it.sortedTags(),
+		Assignee:        it.Assignee,
+		Priority:        it.Priority,
+		DueAt:           it.DueAt,
+		Overdue:         isOverdue(it.DueAt, it.Status, time.Now().UTC(), overdueGrace()),
+		CreatedAt:       it.CreatedAt,
+		StatusUpdatedAt: it.StatusUpdatedAt,
+		StatusChangedBy: it.StatusChangedBy,
+		StatusReason:    it.StatusReason,
+		Version:         it.Version,
+	}
+}
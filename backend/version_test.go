@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 1件のrequestのversionだけを持つフェイク。PutItemの値で始まり、"ADD ... version :one" を含む更新で+1する
+type versionedItem struct {
+	mu      sync.Mutex
+	version int
+}
+
+func (v *versionedItem) handle(op string, in map[string]any) (any, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch op {
+	case "PutItem":
+		n, _ := strconv.Atoi(in["Item"].(map[string]any)["version"].(map[string]any)["N"].(string))
+		v.version = n
+	case "UpdateItem":
+		expr := in["UpdateExpression"].(string)
+		if i := strings.Index(expr, "ADD "); i >= 0 && strings.Contains(expr[i:], "version :one") {
+			v.version++
+		}
+		return map[string]any{"Attributes": map[string]any{
+			"PK":      map[string]any{"S": "REQ#r1"},
+			"status":  map[string]any{"S": "PENDING"},
+			"version": map[string]any{"N": strconv.Itoa(v.version)},
+		}}, nil
+	}
+	return nil, nil
+}
+
+// 作成で version 1 と ETag "1" を返し、ステータス・担当者・タグ・優先度の更新ごとに1ずつ増える
+func TestVersionIncrementsOnEveryUpdate(t *testing.T) {
+	for _, k := range []string{"DEDUP_WINDOW", "DISPLAY_IDS", "FORBID_DUPLICATE_TITLES", "EMIT_CREATED_EVENTS", "MAX_OPEN_REQUESTS_PER_REQUESTER", "ADMIN_TOKENS", "API_WRITES_HISTORY"} {
+		t.Setenv(k, "")
+	}
+	item := &versionedItem{}
+	fake := &fakeAWS{t: t, handle: item.handle}
+	srv := &server{ddb: fake.dynamoClient(), sqs: fake.sqsClient(), queueURL: "http://fake/queue"}
+
+	rec := httptest.NewRecorder()
+	srv.handleCreateRequest(rec, httptest.NewRequest(http.MethodPost, "/requests", strings.NewReader(`{"title":"laptop","requestId":"r1"}`)))
+	var created CreateRequestOutput
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Version != 1 || rec.Header().Get("ETag") != `"1"` || item.version != 1 {
+		t.Fatalf("create: version=%d ETag=%s stored=%d, want 1", created.Version, rec.Header().Get("ETag"), item.version)
+	}
+
+	admin := func(h func(http.ResponseWriter, *http.Request, string), body string) func() int {
+		return func() int {
+			r := httptest.NewRequest(http.MethodPatch, "/requests/r1/x", strings.NewReader(body))
+			r.Header.Set("Authorization", "Bearer dev-admin-token")
+			rec := httptest.NewRecorder()
+			h(rec, r, "r1")
+			return rec.Code
+		}
+	}
+	steps := []struct {
+		name  string
+		do    func() int
+		wantV int
+	}{
+		{name: "status", wantV: 2, do: func() int {
+			ev := StatusChangedEvent{EventID: "e1", RequestID: "r1", NewStatus: "IN_PROGRESS", ChangedAt: "2024-05-01T09:00:00Z"}
+			if err := transitionStatus(context.Background(), srv.ddb, ev); err != nil {
+				t.Fatal(err)
+			}
+			return http.StatusOK
+		}},
+		{name: "assignee", wantV: 3, do: admin(srv.handlePatchAssignee, `{"assignee":"alice"}`)},
+		{name: "unassign", wantV: 4, do: admin(srv.handlePatchAssignee, `{"assignee":""}`)},
+		{name: "add tag", wantV: 5, do: admin(srv.handlePatchTags, `{"add":["it"]}`)},
+		{name: "remove and add tags", wantV: 7, do: admin(srv.handlePatchTags, `{"add":["hw"],"remove":["it"]}`)},
+		{name: "priority", wantV: 8, do: admin(srv.handlePatchPriority, `{"priority":2}`)},
+	}
+	for _, s := range steps {
+		if code := s.do(); code != http.StatusOK {
+			t.Fatalf("%s: status %d", s.name, code)
+		}
+		if item.version != s.wantV {
+			t.Errorf("%s: version = %d, want %d", s.name, item.version, s.wantV)
+		}
+	}
+}
@@ -5,18 +5,29 @@ provider "aws" {
   skip_credentials_validation = true
   skip_metadata_api_check     = true
   skip_requesting_account_id  = true
+  s3_use_path_style           = true
 
   endpoints {
     dynamodb = var.localstack_endpoint
     sts      = var.localstack_endpoint
     sqs      = var.localstack_endpoint
+    s3       = var.localstack_endpoint
+    ses      = var.localstack_endpoint
   }
 }
 
 module "requests_table" {
   source = "../../modules/requests_table"
+
+  billing_mode   = var.dynamodb_billing_mode
+  read_capacity  = var.dynamodb_read_capacity
+  write_capacity = var.dynamodb_write_capacity
 }
 
 output "requests_table_name" {
   value = module.requests_table.table_name
 }
+
+output "request_events_table_name" {
+  value = module.requests_table.events_table_name
+}
@@ -0,0 +1,7 @@
+resource "aws_s3_bucket" "event_archive" {
+  bucket = "request-events-archive"
+}
+
+output "event_archive_bucket" {
+  value = aws_s3_bucket.event_archive.bucket
+}
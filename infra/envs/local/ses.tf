@@ -0,0 +1,9 @@
+# Sender identity for the worker's status-change emails (SES_SENDER).
+# LocalStack accepts it without a real verification mail
+resource "aws_ses_email_identity" "notifications" {
+  email = var.ses_sender
+}
+
+output "ses_sender" {
+  value = aws_ses_email_identity.notifications.email
+}
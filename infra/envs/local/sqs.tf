@@ -1,7 +1,21 @@
+resource "aws_sqs_queue" "request_events_dlq" {
+  name = "request-events-dlq"
+}
+
 resource "aws_sqs_queue" "request_events" {
   name = "request-events"
+
+  # Messages that keep failing are moved to the DLQ
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.request_events_dlq.arn
+    maxReceiveCount     = 5
+  })
 }
 
 output "request_events_queue_url" {
   value = aws_sqs_queue.request_events.url
-}
\ No newline at end of file
+}
+
+output "request_events_dlq_url" {
+  value = aws_sqs_queue.request_events_dlq.url
+}
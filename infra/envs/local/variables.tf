@@ -1,3 +1,24 @@
 variable "localstack_endpoint" {
   type = string
-}
\ No newline at end of file
+}
+
+# Requests table capacity: PAY_PER_REQUEST (default) or PROVISIONED with both capacities set
+variable "dynamodb_billing_mode" {
+  type    = string
+  default = "PAY_PER_REQUEST"
+}
+
+variable "dynamodb_read_capacity" {
+  type    = number
+  default = null
+}
+
+variable "dynamodb_write_capacity" {
+  type    = number
+  default = null
+}
+
+variable "ses_sender" {
+  type    = string
+  default = "notifications@example.com"
+}
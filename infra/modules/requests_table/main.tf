@@ -1,10 +1,118 @@
+locals {
+  provisioned    = var.billing_mode == "PROVISIONED"
+  read_capacity  = local.provisioned ? var.read_capacity : null
+  write_capacity = local.provisioned ? var.write_capacity : null
+}
+
 resource "aws_dynamodb_table" "requests" {
-  name         = "Requests"
-  billing_mode = "PAY_PER_REQUEST"
-  hash_key     = "PK"
+  name           = "Requests"
+  billing_mode   = var.billing_mode
+  hash_key       = "PK"
+  read_capacity  = local.read_capacity
+  write_capacity = local.write_capacity
 
   attribute {
     name = "PK"
     type = "S"
   }
+
+  attribute {
+    name = "assignee"
+    type = "S"
+  }
+
+  attribute {
+    name = "createdAt"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI1PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "requesterKey"
+    type = "S"
+  }
+
+  attribute {
+    name = "ownerKey"
+    type = "S"
+  }
+
+  # GET /admin/requests/mine
+  global_secondary_index {
+    name            = "assignee-index"
+    hash_key        = "assignee"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+    read_capacity   = local.read_capacity
+    write_capacity  = local.write_capacity
+  }
+
+  # GET /admin/requests?from=...&to=...
+  global_secondary_index {
+    name            = "createdAt-index"
+    hash_key        = "GSI1PK"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+    read_capacity   = local.read_capacity
+    write_capacity  = local.write_capacity
+  }
+
+  # MAX_OPEN_REQUESTS_PER_REQUESTER (open request count per requester)
+  global_secondary_index {
+    name               = "requester-index"
+    hash_key           = "requesterKey"
+    range_key          = "createdAt"
+    projection_type    = "INCLUDE"
+    non_key_attributes = ["status"]
+    read_capacity      = local.read_capacity
+    write_capacity     = local.write_capacity
+  }
+
+  # GET /requests?key=... (hashed X-Requester-Key)
+  global_secondary_index {
+    name            = "owner-index"
+    hash_key        = "ownerKey"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+    read_capacity   = local.read_capacity
+    write_capacity  = local.write_capacity
+  }
+
+  # DEDUP_WINDOW sentinel items (DEDUP#<hash>) expire on their own
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  lifecycle {
+    precondition {
+      condition     = local.provisioned ? (var.read_capacity != null && var.write_capacity != null) : (var.read_capacity == null && var.write_capacity == null)
+      error_message = "PROVISIONED needs read_capacity and write_capacity; PAY_PER_REQUEST must not set them."
+    }
+  }
+}
+
+# HISTORY_STORAGE=items: one item per status event (PK=REQ#<id>, SK=EVT#<changedAt>#<eventId>).
+# Separate table because Requests has no sort key
+resource "aws_dynamodb_table" "request_events" {
+  name           = "RequestEvents"
+  billing_mode   = var.billing_mode
+  hash_key       = "PK"
+  range_key      = "SK"
+  read_capacity  = local.read_capacity
+  write_capacity = local.write_capacity
+
+  attribute {
+    name = "PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "SK"
+    type = "S"
+  }
 }
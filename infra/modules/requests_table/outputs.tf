@@ -1,3 +1,6 @@
 output "table_name" {
   value = aws_dynamodb_table.requests.name
-}
\ No newline at end of file
+}
+output "events_table_name" {
+  value = aws_dynamodb_table.request_events.name
+}
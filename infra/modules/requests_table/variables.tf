@@ -0,0 +1,20 @@
+variable "billing_mode" {
+  type    = string
+  default = "PAY_PER_REQUEST"
+
+  validation {
+    condition     = contains(["PAY_PER_REQUEST", "PROVISIONED"], var.billing_mode)
+    error_message = "billing_mode must be PAY_PER_REQUEST or PROVISIONED."
+  }
+}
+
+# PROVISIONED only. Applied to the table and every GSI
+variable "read_capacity" {
+  type    = number
+  default = null
+}
+
+variable "write_capacity" {
+  type    = number
+  default = null
+}